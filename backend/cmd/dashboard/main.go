@@ -3,16 +3,23 @@ package main
 import (
 	"flag"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smartethnet/rustun-dashboard/internal/agent"
+	"github.com/smartethnet/rustun-dashboard/internal/clientconf"
+	"github.com/smartethnet/rustun-dashboard/internal/deploy"
 	"github.com/smartethnet/rustun-dashboard/internal/handler"
 	"github.com/smartethnet/rustun-dashboard/internal/ipadm"
+	"github.com/smartethnet/rustun-dashboard/internal/lint"
+	"github.com/smartethnet/rustun-dashboard/internal/logwatch"
 	"github.com/smartethnet/rustun-dashboard/internal/middleware"
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 	"github.com/smartethnet/rustun-dashboard/internal/repository"
+	"github.com/smartethnet/rustun-dashboard/internal/secret"
 	"github.com/smartethnet/rustun-dashboard/internal/service"
 	"github.com/smartethnet/rustun-dashboard/pkg/config"
 	"gorm.io/driver/mysql"
@@ -22,6 +29,11 @@ import (
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(lint.Run(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "./config.yaml", "Path to config file")
 	flag.Parse()
@@ -37,8 +49,20 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize repository based on storage type
+	// Initialize repositories based on storage type
 	var repo repository.RouteRepository
+	var auditRepo repository.AuditRepository
+	var revisionRepo repository.RevisionRepository
+	var snapshotRepo repository.SnapshotRepository
+	var trashRepo repository.TrashRepository
+	var keyRepo repository.KeyRepository
+	var enrollmentRepo repository.EnrollmentRepository
+	var presenceRepo repository.PresenceRepository
+	var eventRepo repository.EventRepository
+	var trafficRepo repository.TrafficRepository
+	var userRepo repository.UserRepository
+	var apiTokenRepo repository.APITokenRepository
+	var sessionRepo repository.SessionRepository
 
 	if cfg.Storage.Type == "database" {
 		// Initialize database connection
@@ -48,15 +72,68 @@ func main() {
 		}
 
 		// Auto-migrate schema
-		if err := db.AutoMigrate(&model.ClientDB{}); err != nil {
+		if err := db.AutoMigrate(&model.ClientDB{}, &model.AuditEntry{}, &model.Revision{}, &model.Snapshot{}, &model.TrashEntry{}, &model.ClusterKey{}, &model.EnrollmentToken{}, &model.Presence{}, &model.Event{}, &model.TrafficCounter{}, &model.TrafficSample{}, &model.User{}, &model.APIToken{}, &model.Session{}); err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
 
 		repo = repository.NewDatabaseRepository(db)
+		auditRepo = repository.NewDatabaseAuditRepository(db)
+		revisionRepo = repository.NewDatabaseRevisionRepository(db)
+		snapshotRepo = repository.NewDatabaseSnapshotRepository(db)
+		keyRepo = repository.NewDatabaseKeyRepository(db)
+		userRepo = repository.NewDatabaseUserRepository(db)
+		apiTokenRepo = repository.NewDatabaseAPITokenRepository(db)
+		sessionRepo = repository.NewDatabaseSessionRepository(db)
+		enrollmentRepo = repository.NewDatabaseEnrollmentRepository(db)
+		presenceRepo = repository.NewDatabasePresenceRepository(db)
+		eventRepo = repository.NewDatabaseEventRepository(db)
+		trafficRepo = repository.NewDatabaseTrafficRepository(db)
+		if cfg.Trash.Enabled {
+			trashRepo = repository.NewDatabaseTrashRepository(db)
+		}
 		log.Printf("Using database storage: %s", cfg.Storage.Database.Type)
 	} else {
 		// Use file storage (default)
 		repo = repository.NewFileRepository(cfg.Storage.File.RoutesFile)
+		if cfg.Storage.File.Git.Enabled {
+			gitRepo, err := repository.NewGitRepository(repo, cfg.Storage.File.RoutesFile, repository.GitOptions{
+				Remote:      cfg.Storage.File.Git.Remote,
+				Branch:      cfg.Storage.File.Git.Branch,
+				EmailDomain: cfg.Storage.File.Git.EmailDomain,
+				Timeout:     cfg.Storage.File.Git.Timeout,
+			})
+			if err != nil {
+				log.Fatalf("Failed to initialize git storage: %v", err)
+			}
+			repo = gitRepo
+			log.Printf("Committing route changes to git in %s", filepath.Dir(cfg.Storage.File.RoutesFile))
+		}
+		auditRepo = repository.NewFileAuditRepository(filepath.Join(cfg.Storage.File.DataDir, "audit.json"))
+		revisionRepo = repository.NewFileRevisionRepository(filepath.Join(cfg.Storage.File.DataDir, "revisions.json"))
+		snapshotRepo = repository.NewFileSnapshotRepository(filepath.Join(cfg.Storage.File.DataDir, "snapshots.json"))
+		keyRepo = repository.NewFileKeyRepository(filepath.Join(cfg.Storage.File.DataDir, "keys.json"))
+		userRepo = repository.NewFileUserRepository(filepath.Join(cfg.Storage.File.DataDir, "users.json"))
+		apiTokenRepo = repository.NewFileAPITokenRepository(filepath.Join(cfg.Storage.File.DataDir, "api_tokens.json"))
+		sessionRepo = repository.NewFileSessionRepository(filepath.Join(cfg.Storage.File.DataDir, "sessions.json"))
+		enrollmentRepo = repository.NewFileEnrollmentRepository(filepath.Join(cfg.Storage.File.DataDir, "enrollments.json"))
+		presenceRepo = repository.NewFilePresenceRepository(filepath.Join(cfg.Storage.File.DataDir, "presence.json"))
+		eventRepo = repository.NewFileEventRepository(filepath.Join(cfg.Storage.File.DataDir, "events.json"))
+		if cfg.Traffic.Enabled {
+			// Time series are too large for JSON files, so they are kept
+			// in an embedded SQLite database
+			trafficPath := filepath.Join(cfg.Storage.File.DataDir, "traffic.db")
+			trafficDB, err := gorm.Open(sqlite.Open(trafficPath), &gorm.Config{})
+			if err != nil {
+				log.Fatalf("Failed to open traffic database: %v", err)
+			}
+			if err := trafficDB.AutoMigrate(&model.TrafficCounter{}, &model.TrafficSample{}); err != nil {
+				log.Fatalf("Failed to migrate traffic database: %v", err)
+			}
+			trafficRepo = repository.NewDatabaseTrafficRepository(trafficDB)
+		}
+		if cfg.Trash.Enabled {
+			trashRepo = repository.NewFileTrashRepository(filepath.Join(cfg.Storage.File.DataDir, "trash.json"))
+		}
 		log.Printf("Using file storage: %s", cfg.Storage.File.RoutesFile)
 	}
 
@@ -71,27 +148,169 @@ func main() {
 	log.Printf("Initialized IP address manager: network=%s, gateway=%s, start=%s",
 		ipConfig.Network, ipConfig.Gateway, ipConfig.StartIP)
 
-	// Initialize from existing clients
-	existingClients, err := repo.GetAll()
-	if err == nil && len(existingClients) > 0 {
-		clientInfos := make([]struct {
-			Cluster   string
-			PrivateIP string
-		}, len(existingClients))
-		for i, client := range existingClients {
-			clientInfos[i].Cluster = client.Cluster
-			clientInfos[i].PrivateIP = client.PrivateIP
+	// Initialize services
+	routeService := service.NewRouteService(repo, ipManager, revisionRepo, snapshotRepo, trashRepo)
+	auditService := service.NewAuditService(auditRepo)
+	userService := service.NewUserService(userRepo, auditService)
+	if err := userService.EnsureDefaultUser(cfg.Auth.Username, cfg.Auth.Password); err != nil {
+		log.Fatalf("Failed to create default user: %v", err)
+	}
+	apiTokenService := service.NewAPITokenService(apiTokenRepo, userService, auditService)
+	serverConfigService := service.NewServerConfigService(
+		repository.NewFileServerConfigRepository(cfg.Storage.File.ServerConfig),
+		auditService,
+		cfg.Server.Mode == "debug",
+	)
+
+	// Cluster keys are encrypted with the master key
+	masterKey, err := secret.LoadMasterKey(cfg.Keys.MasterKey, cfg.Keys.MasterKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
+	box, err := secret.NewBox(masterKey)
+	if err != nil {
+		log.Fatalf("Failed to initialize master key: %v", err)
+	}
+	// Activated keys are written to server.toml unless the server key is
+	// managed by hand
+	var serverKeys service.ServerKeySetter
+	if cfg.Keys.ServerConfig {
+		serverKeys = serverConfigService
+	}
+	keyService := service.NewKeyService(keyRepo, box, auditService, serverKeys)
+
+	// Session access tokens are signed with a key derived from the master key
+	sessionService := service.NewSessionService(sessionRepo, userService, auditService,
+		secret.DeriveKey(masterKey, "session signing"), cfg.Auth.AccessTokenTTL, cfg.Auth.SessionTTL)
+	userService.OnDelete(func(user model.User) {
+		if err := apiTokenService.DeleteUserTokens(user.ID); err != nil {
+			log.Printf("Failed to delete api tokens of %s: %v", user.Username, err)
 		}
-		ipManager.InitFromExistingClients(clientInfos)
-		log.Printf("Initialized IP allocations from %d existing clients", len(existingClients))
+		if err := sessionService.DeleteUserSessions(user.ID); err != nil {
+			log.Printf("Failed to delete sessions of %s: %v", user.Username, err)
+		}
+	})
+
+	clientConfigService := service.NewClientConfigService(routeService, keyService, func(cluster string) model.ConnectSettings {
+		settings := cfg.Client.ForCluster(cluster)
+		return model.ConnectSettings{
+			Server:            settings.Server,
+			Crypto:            settings.Crypto,
+			EnableP2P:         settings.EnableP2P != nil && *settings.EnableP2P,
+			KeepaliveInterval: settings.KeepaliveInterval,
+			Binary:            settings.Binary,
+		}
+	})
+
+	presenceService := service.NewPresenceService(presenceRepo, routeService, cfg.Presence.OnlineThreshold, cfg.Presence.OfflineThreshold)
+	topologyService := service.NewTopologyService(routeService, presenceService)
+	enrollmentService := service.NewEnrollmentService(enrollmentRepo, routeService, clientConfigService, auditService, cfg.Enroll.DefaultTTL, cfg.Enroll.MaxTTL, cfg.Enroll.URL)
+
+	// Initialize from existing clients, including those in the trash
+	if count, err := routeService.InitIPAllocations(); err == nil && count > 0 {
+		log.Printf("Initialized IP allocations from %d existing clients", count)
 	}
 
-	// Initialize services
-	routeService := service.NewRouteService(repo, ipManager)
+	// Start the client expiry scheduler
+	if cfg.Expiry.Enabled {
+		expiryScheduler := service.NewExpiryScheduler(routeService, auditService, cfg.Expiry.Interval, cfg.Expiry.Action)
+		expiryScheduler.Start()
+		log.Printf("Client expiry scheduler started: interval=%s, action=%s", cfg.Expiry.Interval, cfg.Expiry.Action)
+	}
+
+	// Publish routes to the deployment targets after every change
+	var deployer *service.Deployer
+	if len(cfg.Deploy.Targets) > 0 {
+		targets := make([]service.DeployTarget, 0, len(cfg.Deploy.Targets))
+		for _, t := range cfg.Deploy.Targets {
+			target, err := deploy.NewTarget(deploy.Options{
+				Name:     t.Name,
+				Type:     t.Type,
+				Path:     t.Path,
+				Filename: t.Filename,
+				URL:      t.URL,
+				Headers:  t.Headers,
+				Command:  t.Command,
+			})
+			if err != nil {
+				log.Fatalf("Invalid deploy target: %v", err)
+			}
+			targets = append(targets, service.DeployTarget{Name: t.Name, Type: t.Type, Clusters: t.Clusters, Target: target})
+		}
+		deployer = service.NewDeployer(repo, auditService, targets, cfg.Deploy.Timeout, cfg.Deploy.Retries, cfg.Deploy.RetryInterval)
+		routeService.OnChange(deployer.Notify)
+		deployer.Start()
+		log.Printf("Publishing routes to %d deployment targets", len(targets))
+	}
+
+	// Follow the rustun server log for connection events
+	var eventService *service.EventService
+	if cfg.Events.Enabled {
+		rules := make([]logwatch.Rule, 0, len(cfg.Events.Patterns))
+		for _, p := range cfg.Events.Patterns {
+			rule, err := logwatch.NewRule(p.Type, p.Pattern)
+			if err != nil {
+				log.Fatalf("Invalid events pattern: %v", err)
+			}
+			rules = append(rules, rule)
+		}
+		rules = append(rules, logwatch.DefaultRules()...)
+
+		tailer := logwatch.NewTailer(cfg.Events.LogFile, cfg.Events.PollInterval, cfg.Events.FromStart)
+		eventService = service.NewEventService(eventRepo, routeService, tailer, logwatch.NewParser(rules), cfg.Events.Retention)
+		eventService.Start()
+		log.Printf("Following rustun server log: %s", cfg.Events.LogFile)
+	}
+
+	// Keep traffic time series
+	var trafficService *service.TrafficService
+	if cfg.Traffic.Enabled {
+		trafficService = service.NewTrafficService(trafficRepo, routeService, service.TrafficRetention{
+			Raw:        cfg.Traffic.Retention.Raw,
+			FiveMinute: cfg.Traffic.Retention.FiveMinute,
+			Hourly:     cfg.Traffic.Retention.Hourly,
+		})
+		trafficService.Start()
+		log.Printf("Traffic statistics enabled: retention raw=%s, 5m=%s, 1h=%s",
+			cfg.Traffic.Retention.Raw, cfg.Traffic.Retention.FiveMinute, cfg.Traffic.Retention.Hourly)
+	}
+
+	// Start purging old trash entries (a retention of 0 keeps them forever)
+	if cfg.Trash.Enabled && cfg.Trash.Retention > 0 {
+		trashPurger := service.NewTrashPurger(routeService, auditService, cfg.Trash.Interval, cfg.Trash.Retention)
+		trashPurger.Start()
+		log.Printf("Trash purger started: interval=%s, retention=%s", cfg.Trash.Interval, cfg.Trash.Retention)
+	}
 
 	// Initialize handlers
 	clusterHandler := handler.NewClusterHandler(routeService)
-	clientHandler := handler.NewClientHandler(routeService)
+	topologyHandler := handler.NewTopologyHandler(topologyService)
+	clientHandler := handler.NewClientHandler(routeService, presenceService, cfg.Expiry.Upcoming)
+	auditHandler := handler.NewAuditHandler(auditService)
+	exchangeHandler := handler.NewExchangeHandler(routeService)
+	validateHandler := handler.NewValidateHandler(routeService)
+	userHandler := handler.NewUserHandler(userService, sessionService)
+	sessionHandler := handler.NewSessionHandler(sessionService, userService)
+	apiTokenHandler := handler.NewAPITokenHandler(apiTokenService, userService)
+	historyHandler := handler.NewHistoryHandler(routeService)
+	snapshotHandler := handler.NewSnapshotHandler(routeService)
+	gitHandler := handler.NewGitHandler(routeService)
+	trashHandler := handler.NewTrashHandler(routeService, auditService)
+	serverConfigHandler := handler.NewServerConfigHandler(serverConfigService)
+	keyHandler := handler.NewKeyHandler(keyService)
+	qrLevel, err := clientconf.ParseQRLevel(cfg.Client.QR.Level)
+	if err != nil {
+		log.Fatalf("Invalid client_config.qr.level: %v", err)
+	}
+	if cfg.Client.QR.Size < clientconf.MinQRSize || cfg.Client.QR.Size > clientconf.MaxQRSize {
+		log.Fatalf("Invalid client_config.qr.size %d: must be between %d and %d", cfg.Client.QR.Size, clientconf.MinQRSize, clientconf.MaxQRSize)
+	}
+	qrOptions := clientconf.QROptions{
+		Size:  cfg.Client.QR.Size,
+		Level: qrLevel,
+	}
+	clientConfigHandler := handler.NewClientConfigHandler(clientConfigService, qrOptions)
+	enrollmentHandler := handler.NewEnrollmentHandler(enrollmentService, qrOptions)
 
 	// Initialize AI agent if enabled
 	var agentHandler *handler.AgentHandler
@@ -123,7 +342,7 @@ func main() {
 		}
 
 		if apiKey != "" {
-			aiAgent := agent.NewAgent(apiKey, cfg.Agent.Model, cfg.Agent.BaseURL, provider, routeService)
+			aiAgent := agent.NewAgent(apiKey, cfg.Agent.Model, cfg.Agent.BaseURL, provider, routeService, clientConfigService, presenceService)
 			agentHandler = handler.NewAgentHandler(aiAgent)
 			log.Printf("AI Agent enabled with provider: %s, model: %s", provider, cfg.Agent.Model)
 		} else {
@@ -145,31 +364,192 @@ func main() {
 		})
 	})
 
-	// API routes with Basic Auth
+	// Client self-registration (authenticated by the enrollment token). The
+	// enrollment URL handed out with tokens opens the page, which posts back.
+	if cfg.Enroll.Enabled {
+		r.GET("/api/enroll", enrollmentHandler.EnrollPage)
+		r.POST("/api/enroll", enrollmentHandler.Enroll)
+	}
+
+	// Interactive login (authenticated by the credentials or refresh token in
+	// the body)
+	r.POST("/api/auth/login", sessionHandler.Login)
+	r.POST("/api/auth/refresh", sessionHandler.Refresh)
+
+	// Role checks
+	viewer := func(scope middleware.Scope) gin.HandlerFunc { return middleware.Require(model.RoleViewer, scope) }
+	operator := func(scope middleware.Scope) gin.HandlerFunc { return middleware.Require(model.RoleOperator, scope) }
+	admin := func(scope middleware.Scope) gin.HandlerFunc { return middleware.Require(model.RoleAdmin, scope) }
+	clusterParam := middleware.ClusterParam("cluster")
+	nameParam := middleware.ClusterParam("name")
+	clusterQuery := middleware.ClusterQuery("cluster", middleware.AnyCluster)
+	clusterQueryOrGlobal := middleware.ClusterQuery("cluster", middleware.Global)
+
+	// Accounts are not managed with API tokens
+	noTokens := middleware.RejectAPITokens()
+
+	// API routes with a session access token, an API token or Basic Auth.
+	// Every route requires a role; routes scoped to any cluster check or
+	// filter the clusters they touch themselves.
 	api := r.Group("/api")
-	api.Use(middleware.BasicAuth(cfg.Auth.Username, cfg.Auth.Password))
+	api.Use(middleware.BearerAuth(sessionService, apiTokenService), middleware.BasicAuth(userService))
 	{
+		// Own account
+		api.GET("/auth/me", userHandler.Me)
+		api.PUT("/auth/password", noTokens, userHandler.ChangePassword)
+		api.POST("/auth/logout", sessionHandler.Logout)
+
+		// Own login sessions
+		sessions := api.Group("/auth/sessions", noTokens)
+		{
+			sessions.GET("", sessionHandler.ListOwnSessions)
+			sessions.DELETE("/:session_id", sessionHandler.RevokeOwnSession)
+		}
+
+		// Own API tokens
+		tokens := api.Group("/auth/tokens", noTokens)
+		{
+			tokens.GET("", apiTokenHandler.ListOwnTokens)
+			tokens.POST("", apiTokenHandler.CreateOwnToken)
+			tokens.DELETE("/:token_id", apiTokenHandler.RevokeOwnToken)
+		}
+
+		// User management
+		users := api.Group("/users", noTokens, admin(middleware.Global))
+		{
+			users.GET("", userHandler.ListUsers)
+			users.POST("", userHandler.CreateUser)
+			users.GET("/:id", userHandler.GetUser)
+			users.PUT("/:id", userHandler.UpdateUser)
+			users.DELETE("/:id", userHandler.DeleteUser)
+			users.GET("/:id/tokens", apiTokenHandler.ListUserTokens)
+			users.POST("/:id/tokens", apiTokenHandler.CreateUserToken)
+			users.DELETE("/:id/tokens/:token_id", apiTokenHandler.RevokeUserToken)
+			users.GET("/:id/sessions", sessionHandler.ListUserSessions)
+			users.DELETE("/:id/sessions/:session_id", sessionHandler.RevokeUserSession)
+		}
+
 		// Cluster routes
 		clusters := api.Group("/clusters")
 		{
-			clusters.GET("", clusterHandler.ListClusters)
-			clusters.GET("/:name", clusterHandler.GetCluster)
-			clusters.DELETE("/:name", clusterHandler.DeleteCluster)
+			clusters.GET("", viewer(middleware.AnyCluster), clusterHandler.ListClusters)
+			clusters.GET("/:name", viewer(nameParam), clusterHandler.GetCluster)
+			clusters.DELETE("/:name", admin(nameParam), clusterHandler.DeleteCluster)
+			clusters.PUT("/:name/desired-state", admin(nameParam), clusterHandler.ApplyDesiredState)
+			clusters.GET("/:name/topology", viewer(nameParam), topologyHandler.GetTopology)
+			clusters.GET("/:name/keys", viewer(nameParam), keyHandler.ListKeys)
+			clusters.POST("/:name/keys", admin(nameParam), keyHandler.GenerateKey)
+			clusters.POST("/:name/keys/activate", admin(nameParam), keyHandler.ActivateKey)
+			clusters.DELETE("/:name/keys/pending", admin(nameParam), keyHandler.DiscardPendingKey)
+			clusters.GET("/:name/keys/:id/secret", admin(nameParam), keyHandler.RevealKey)
 		}
 
 		// Client routes
 		clients := api.Group("/clients")
 		{
-			clients.GET("", clientHandler.ListClients)
-			clients.POST("", clientHandler.CreateClient)
-			clients.GET("/:cluster/:identity", clientHandler.GetClient)
-			clients.PUT("/:cluster/:identity", clientHandler.UpdateClient)
-			clients.DELETE("/:cluster/:identity", clientHandler.DeleteClient)
+			clients.GET("", viewer(clusterQuery), clientHandler.ListClients)
+			clients.POST("", operator(middleware.AnyCluster), clientHandler.CreateClient)
+			clients.POST("/batch", operator(middleware.AnyCluster), clientHandler.BatchClients) // Also served as /api/clients:batch
+			clients.GET("/expiring", viewer(middleware.AnyCluster), clientHandler.ListExpiringClients)
+			clients.GET("/:cluster/:identity", viewer(clusterParam), clientHandler.GetClient)
+			clients.PUT("/:cluster/:identity", operator(clusterParam), clientHandler.UpdateClient)
+			clients.DELETE("/:cluster/:identity", operator(clusterParam), clientHandler.DeleteClient)
+			clients.POST("/:cluster/:identity/enable", operator(clusterParam), clientHandler.EnableClient)
+			clients.POST("/:cluster/:identity/disable", operator(clusterParam), clientHandler.DisableClient)
+			clients.GET("/:cluster/:identity/history", viewer(clusterParam), historyHandler.GetClientHistory)
+			clients.POST("/:cluster/:identity/rollback", operator(clusterParam), historyHandler.RollbackClient)
+			// Client configurations contain the cluster key
+			clients.GET("/:cluster/:identity/config", operator(clusterParam), clientConfigHandler.GetClientConfig)
+			clients.GET("/:cluster/:identity/config.png", operator(clusterParam), clientConfigHandler.GetClientConfigPNG)
+			clients.GET("/:cluster/:identity/config.svg", operator(clusterParam), clientConfigHandler.GetClientConfigSVG)
+		}
+		// Heartbeats are authorized in the cluster of the client they name
+		api.POST("/heartbeat", clientHandler.Heartbeat)
+
+		// Enrollment token routes
+		if cfg.Enroll.Enabled {
+			enrollment := api.Group("/enrollment-tokens")
+			{
+				enrollment.GET("", viewer(clusterQuery), enrollmentHandler.ListTokens)
+				enrollment.POST("", operator(middleware.AnyCluster), enrollmentHandler.CreateToken)
+				enrollment.DELETE("/:id", operator(middleware.AnyCluster), enrollmentHandler.RevokeToken)
+			}
+		}
+
+		// Deployment target routes
+		if deployer != nil {
+			deployHandler := handler.NewDeployHandler(deployer)
+			api.GET("/deploy/targets", viewer(middleware.Global), deployHandler.ListTargets)
+			api.POST("/deploy/sync", admin(middleware.Global), deployHandler.Sync)
+		}
+
+		// Server events derived from the rustun log
+		if eventService != nil {
+			eventHandler := handler.NewEventHandler(eventService)
+			api.GET("/events", viewer(clusterQuery), eventHandler.ListEvents)
+			api.GET("/events/stream", viewer(clusterQuery), eventHandler.StreamEvents)
+		}
+
+		// Traffic statistics
+		if trafficService != nil {
+			trafficHandler := handler.NewTrafficHandler(trafficService)
+			api.POST("/traffic", operator(clusterQueryOrGlobal), trafficHandler.Ingest)
+			clients.GET("/:cluster/:identity/traffic", viewer(clusterParam), trafficHandler.GetClientTraffic)
+			clusters.GET("/:name/traffic", viewer(nameParam), trafficHandler.GetClusterTraffic)
+		}
+
+		// rustun server configuration
+		api.GET("/server/config", viewer(middleware.Global), serverConfigHandler.GetServerConfig)
+		api.PUT("/server/config", admin(middleware.Global), serverConfigHandler.UpdateServerConfig)
+
+		// Audit log
+		api.GET("/audit", viewer(middleware.Global), auditHandler.ListAudit)
+
+		api.GET("/history/diff", viewer(middleware.Global), historyHandler.DiffTimes)
+
+		// Snapshot routes; snapshots cover all clusters
+		snapshots := api.Group("/snapshots")
+		{
+			snapshots.GET("", viewer(middleware.Global), snapshotHandler.ListSnapshots)
+			snapshots.POST("", operator(middleware.Global), snapshotHandler.CreateSnapshot)
+			snapshots.GET("/:id", viewer(middleware.Global), snapshotHandler.GetSnapshot)
+			snapshots.DELETE("/:id", admin(middleware.Global), snapshotHandler.DeleteSnapshot)
+			snapshots.GET("/:id/diff", viewer(middleware.Global), snapshotHandler.DiffSnapshot)
+			snapshots.POST("/:id/restore", admin(middleware.Global), snapshotHandler.RestoreSnapshot)
+		}
+
+		// Trash routes (if deleted clients are kept)
+		if cfg.Trash.Enabled {
+			trash := api.Group("/trash")
+			{
+				trash.GET("", viewer(clusterQuery), trashHandler.ListTrash)
+				trash.DELETE("", admin(clusterQueryOrGlobal), trashHandler.EmptyTrash)
+				trash.POST("/restore", operator(clusterQueryOrGlobal), trashHandler.RestoreCluster)
+				trash.POST("/:id/restore", operator(middleware.AnyCluster), trashHandler.RestoreEntry)
+				trash.DELETE("/:id", admin(middleware.AnyCluster), trashHandler.PurgeEntry)
+			}
+		}
+
+		// Git history routes (if the routes file is kept in git)
+		if cfg.Storage.Type != "database" && cfg.Storage.File.Git.Enabled {
+			gitGroup := api.Group("/git")
+			{
+				gitGroup.GET("/log", viewer(middleware.Global), gitHandler.GetLog)
+				gitGroup.GET("/status", viewer(middleware.Global), gitHandler.GetStatus)
+				gitGroup.POST("/revert", admin(middleware.Global), gitHandler.Revert)
+				gitGroup.POST("/push", admin(middleware.Global), gitHandler.Push)
+			}
 		}
 
-		// Agent routes (if enabled)
+		// Import/export
+		api.GET("/export", viewer(clusterQueryOrGlobal), exchangeHandler.Export)
+		api.POST("/import", operator(clusterQueryOrGlobal), exchangeHandler.Import)
+		api.POST("/validate", viewer(middleware.AnyCluster), validateHandler.ValidateRoutes)
+
+		// Agent routes (if enabled); tool calls are checked against the
+		// caller's roles
 		if agentHandler != nil {
-			agentGroup := api.Group("/agent")
+			agentGroup := api.Group("/agent", middleware.RequireScope(model.ScopeAgent), viewer(middleware.AnyCluster))
 			{
 				agentGroup.POST("/chat", agentHandler.Chat)
 				agentGroup.POST("/chat/stream", agentHandler.StreamChat)
@@ -179,11 +559,24 @@ func main() {
 
 	// Start server
 	log.Printf("Starting Rustun Dashboard API server on %s", cfg.Server.Address())
-	if err := r.Run(cfg.Server.Address()); err != nil {
+	if err := http.ListenAndServe(cfg.Server.Address(), batchPath(r)); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// batchPath serves POST /api/clients:batch with the /api/clients/batch route.
+// gin takes the colon for the start of a path parameter, so the path is
+// rewritten before routing.
+func batchPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/clients:batch" {
+			req.URL.Path = "/api/clients/batch"
+			req.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
 // initDatabase initializes database connection based on config
 func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 	var dialector gorm.Dialector
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/smartethnet/rustun-dashboard/internal/model"
 	"github.com/smartethnet/rustun-dashboard/internal/service"
 )
 
@@ -22,19 +23,21 @@ type Agent struct {
 }
 
 // NewAgent creates a new agent instance
-func NewAgent(apiKey, model, baseURL, provider string, routeService *service.RouteService) *Agent {
+func NewAgent(apiKey, model, baseURL, provider string, routeService *service.RouteService, clientConfigService *service.ClientConfigService, presenceService *service.PresenceService) *Agent {
 	return &Agent{
 		llmClient:    NewLLMClient(apiKey, model, baseURL, provider),
-		toolExecutor: NewToolExecutor(routeService),
+		toolExecutor: NewToolExecutor(routeService, clientConfigService, presenceService),
 		systemPrompt: getSystemPrompt(),
 	}
 }
 
 // AgentChatRequest represents a chat request from the user
 type AgentChatRequest struct {
-	Message             string        `json:"message" binding:"required"`
-	ConversationID      string        `json:"conversation_id,omitempty"`
-	ConversationHistory []ChatMessage `json:"history,omitempty"`
+	Message             string           `json:"message" binding:"required"`
+	ConversationID      string           `json:"conversation_id,omitempty"`
+	ConversationHistory []ChatMessage    `json:"history,omitempty"`
+	User                string           `json:"-"` // Authenticated user the agent acts for
+	Access              model.Authorizer `json:"-"` // What the user may do, nil for everything
 }
 
 // AgentChatResponse represents the agent's response
@@ -83,8 +86,9 @@ func (a *Agent) Chat(req AgentChatRequest) (*AgentChatResponse, error) {
 		Content: req.Message,
 	})
 
-	// Get available tools
-	tools := a.toolExecutor.GetTools()
+	// Get available tools; changes are attributed to the requesting user
+	executor := a.toolExecutor.WithUser(req.User, req.Access)
+	tools := executor.GetTools()
 	log.Printf("[Agent] Chat: Registered %d tools", len(tools))
 
 	// Track tool calls for response
@@ -136,7 +140,7 @@ func (a *Agent) Chat(req AgentChatRequest) (*AgentChatResponse, error) {
 			arguments := toolCall.Function.Arguments
 
 			// Execute the tool
-			result, err := a.toolExecutor.ExecuteTool(functionName, arguments)
+			result, err := executor.ExecuteTool(functionName, arguments)
 			if err != nil {
 				result = fmt.Sprintf(`{"error": "%s"}`, err.Error())
 			}
@@ -179,7 +183,8 @@ func (a *Agent) ChatStream(ctx context.Context, req AgentChatRequest, streamChan
 		Content: req.Message,
 	})
 
-	tools := a.toolExecutor.GetTools()
+	executor := a.toolExecutor.WithUser(req.User, req.Access)
+	tools := executor.GetTools()
 	log.Printf("[Agent] ChatStream: Registered %d tools", len(tools))
 
 	var (
@@ -275,7 +280,7 @@ func (a *Agent) ChatStream(ctx context.Context, req AgentChatRequest, streamChan
 
 				log.Printf("[Agent] ChatStream: Calling tool: %s with args: %s", functionName, arguments)
 
-				result, err := a.toolExecutor.ExecuteTool(functionName, arguments)
+				result, err := executor.ExecuteTool(functionName, arguments)
 				if err != nil {
 					result = fmt.Sprintf(`{"error": "%s"}`, err.Error())
 				}
@@ -400,7 +405,40 @@ You are both an **Operations Assistant** and a **Technical Advisor**:
 - cluster
 - identity (UUID)
 
-**Safety**: Confirm before deletion, warn irreversible
+**Safety**: Confirm before deletion. If the trash is enabled (restore_client is available), deleted clients keep their IP and can be restored; otherwise warn that deletion is irreversible
+
+### list_trash / restore_client
+**Use when**: the user wants to undo a deletion or asks what was deleted
+
+**Rules**:
+1. Call list_trash first unless the cluster and identity are already known
+2. Restore by trash entry id, by cluster and identity, or a whole cluster by cluster only
+3. Restoring fails if a client with the same identity exists again; report the error instead of retrying
+
+### get_client_status
+**Use when**: the user asks which clients are connected, online or offline, or when a client was last seen
+
+**Rules**:
+1. Pass state to only get online, stale or offline clients
+2. online means a heartbeat arrived recently, stale that heartbeats stopped a while ago, offline that none arrived for longer or ever
+3. Report last_seen, endpoint and version when present
+
+### get_client_config
+**Required**:
+- cluster
+- identity (UUID)
+
+**Use when**: the user asks how to connect a client, for its command line, or for its config. Show the returned command in a code block and mention the env/sh/zip downloads at /api/clients/{cluster}/{identity}/config?format=... The crypto value is a secret: only show it to the user who asked
+
+### enable_client / disable_client
+**Required**:
+- cluster
+- identity (UUID)
+
+**Tip**: Prefer disable_client when a device should only be cut off temporarily; it keeps the identity, IP and routes so enable_client restores the exact previous config
+
+### batch_clients
+Use for three or more changes at once (e.g. onboarding an office). Runs all-or-nothing: if one operation fails nothing is applied, so report the failed item and its error
 
 ## Response Style Guide
 
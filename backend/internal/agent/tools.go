@@ -3,6 +3,7 @@ package agent
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 	"github.com/smartethnet/rustun-dashboard/internal/service"
@@ -10,19 +11,69 @@ import (
 
 // ToolExecutor handles tool execution
 type ToolExecutor struct {
-	routeService *service.RouteService
+	routeService        *service.RouteService
+	clientConfigService *service.ClientConfigService
+	presenceService     *service.PresenceService
+
+	// access limits the tools to what the user may do, nil allows everything
+	access model.Authorizer
 }
 
 // NewToolExecutor creates a new tool executor
-func NewToolExecutor(routeService *service.RouteService) *ToolExecutor {
+func NewToolExecutor(routeService *service.RouteService, clientConfigService *service.ClientConfigService, presenceService *service.PresenceService) *ToolExecutor {
+	return &ToolExecutor{
+		routeService:        routeService,
+		clientConfigService: clientConfigService,
+		presenceService:     presenceService,
+	}
+}
+
+// WithUser returns an executor whose changes are recorded as made by the
+// given user through the agent and that only does what access allows
+func (te *ToolExecutor) WithUser(user string, access model.Authorizer) *ToolExecutor {
 	return &ToolExecutor{
-		routeService: routeService,
+		routeService:        te.routeService.WithActor(model.Actor{Name: user, Source: model.SourceAgent}),
+		clientConfigService: te.clientConfigService,
+		presenceService:     te.presenceService,
+		access:              access,
 	}
 }
 
+// allows reports whether the user holds at least role in cluster
+func (te *ToolExecutor) allows(role, cluster string) bool {
+	return te.access == nil || te.access.Allows(role, cluster)
+}
+
+// authorize returns an error unless the user holds at least role in cluster
+func (te *ToolExecutor) authorize(role, cluster string) error {
+	if te.allows(role, cluster) {
+		return nil
+	}
+	if cluster == "" {
+		return fmt.Errorf("权限不足: 需要所有集群的 %s 角色", role)
+	}
+	return fmt.Errorf("权限不足: 需要集群 %s 的 %s 角色", cluster, role)
+}
+
+// visibleClients returns the clients in clusters the user may view
+func (te *ToolExecutor) visibleClients(clients []model.Client) []model.Client {
+	visible := make([]model.Client, 0, len(clients))
+	for _, client := range clients {
+		if te.allows(model.RoleViewer, client.Cluster) {
+			visible = append(visible, client)
+		}
+	}
+	return visible
+}
+
 // GetTools returns all available tools
 func (te *ToolExecutor) GetTools() []Tool {
-	return []Tool{
+	deleteDescription := "Delete specified client. After deletion, the IP address occupied by the client will be released"
+	if te.routeService.TrashEnabled() {
+		deleteDescription = "Delete specified client. The client is moved to the trash and keeps its IP address until the trash entry is purged, so it can be brought back with restore_client"
+	}
+
+	tools := []Tool{
 		{
 			Type: "function",
 			Function: FunctionDef{
@@ -94,6 +145,10 @@ func (te *ToolExecutor) GetTools() []Tool {
 								"type": "string",
 							},
 						},
+						"expires_at": map[string]interface{}{
+							"type":        "string",
+							"description": "Optional expiry time in RFC3339 format, e.g. 2025-12-31T23:59:59Z. Expired clients are deactivated automatically",
+						},
 					},
 					"required": []string{"cluster"},
 				},
@@ -126,6 +181,10 @@ func (te *ToolExecutor) GetTools() []Tool {
 								"type": "string",
 							},
 						},
+						"expires_at": map[string]interface{}{
+							"type":        "string",
+							"description": "New expiry time in RFC3339 format. Omit to keep the current value, use an empty string to remove the expiry",
+						},
 					},
 					"required": []string{"cluster", "identity"},
 				},
@@ -135,7 +194,74 @@ func (te *ToolExecutor) GetTools() []Tool {
 			Type: "function",
 			Function: FunctionDef{
 				Name:        "delete_client",
-				Description: "Delete specified client. After deletion, the IP address occupied by the client will be released",
+				Description: deleteDescription,
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"cluster": map[string]interface{}{
+							"type":        "string",
+							"description": "Cluster name where the client belongs",
+						},
+						"identity": map[string]interface{}{
+							"type":        "string",
+							"description": "Unique client identifier (UUID)",
+						},
+					},
+					"required": []string{"cluster", "identity"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        "get_client_status",
+				Description: "Get the connection status of clients from their heartbeats: online, stale or offline, with when they were last seen, their public endpoint, version and uptime",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"cluster": map[string]interface{}{
+							"type":        "string",
+							"description": "Only clients of this cluster (optional)",
+						},
+						"identity": map[string]interface{}{
+							"type":        "string",
+							"description": "Only this client (UUID, optional)",
+						},
+						"state": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"online", "stale", "offline"},
+							"description": "Only clients in this state (optional)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        "get_client_config",
+				Description: "Get the ready-to-run rustun client configuration of a client: server address, identity, crypto settings and the full command line to start the client",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"cluster": map[string]interface{}{
+							"type":        "string",
+							"description": "Cluster name where the client belongs",
+						},
+						"identity": map[string]interface{}{
+							"type":        "string",
+							"description": "Unique client identifier (UUID)",
+						},
+					},
+					"required": []string{"cluster", "identity"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        "enable_client",
+				Description: "Enable a disabled client. The client is published to the rustun routes again with exactly its previous identity, IP and CIDR routes",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -152,7 +278,122 @@ func (te *ToolExecutor) GetTools() []Tool {
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        "disable_client",
+				Description: "Disable a client without deleting it. The client is removed from the rustun routes so it can no longer connect, but its identity, IP reservation and CIDR routes are kept and can be restored with enable_client",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"cluster": map[string]interface{}{
+							"type":        "string",
+							"description": "Cluster name where the client belongs",
+						},
+						"identity": map[string]interface{}{
+							"type":        "string",
+							"description": "Unique client identifier (UUID)",
+						},
+					},
+					"required": []string{"cluster", "identity"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        "batch_clients",
+				Description: "Apply several create/update/delete client operations at once, all-or-nothing. If any operation fails, none are applied and allocated IPs are released. Use this when onboarding or cleaning up many clients",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"operations": map[string]interface{}{
+							"type":        "array",
+							"description": "Operations to apply in order",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"op": map[string]interface{}{
+										"type":        "string",
+										"enum":        []string{"create", "update", "delete"},
+										"description": "Operation type",
+									},
+									"cluster": map[string]interface{}{
+										"type":        "string",
+										"description": "Cluster name",
+									},
+									"identity": map[string]interface{}{
+										"type":        "string",
+										"description": "Client identity (UUID), required for update and delete",
+									},
+									"name": map[string]interface{}{
+										"type":        "string",
+										"description": "Friendly name for create, or new name for update",
+									},
+									"ciders": map[string]interface{}{
+										"type":        "array",
+										"description": "CIDR route list; for update, omit to keep current routes",
+										"items": map[string]interface{}{
+											"type": "string",
+										},
+									},
+								},
+								"required": []string{"op", "cluster"},
+							},
+						},
+					},
+					"required": []string{"operations"},
+				},
+			},
+		},
 	}
+
+	if te.routeService.TrashEnabled() {
+		tools = append(tools,
+			Tool{
+				Type: "function",
+				Function: FunctionDef{
+					Name:        "list_trash",
+					Description: "List deleted clients in the trash, most recently deleted first, with who deleted them and when. Each entry has an id usable with restore_client",
+					Parameters: map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"cluster": map[string]interface{}{
+								"type":        "string",
+								"description": "Only list deleted clients of this cluster (optional)",
+							},
+						},
+					},
+				},
+			},
+			Tool{
+				Type: "function",
+				Function: FunctionDef{
+					Name:        "restore_client",
+					Description: "Restore deleted clients from the trash with their previous identity, IP and CIDR routes. Pass a trash entry id, a cluster and identity (restores the most recently deleted version), or only a cluster to restore every deleted client of that cluster",
+					Parameters: map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"id": map[string]interface{}{
+								"type":        "integer",
+								"description": "Trash entry ID from list_trash",
+							},
+							"cluster": map[string]interface{}{
+								"type":        "string",
+								"description": "Cluster name of the deleted client(s)",
+							},
+							"identity": map[string]interface{}{
+								"type":        "string",
+								"description": "Unique client identifier (UUID) of the deleted client",
+							},
+						},
+					},
+				},
+			},
+		)
+	}
+
+	return tools
 }
 
 // ExecuteTool executes a tool function
@@ -170,6 +411,20 @@ func (te *ToolExecutor) ExecuteTool(name string, arguments string) (string, erro
 		return te.updateClient(arguments)
 	case "delete_client":
 		return te.deleteClient(arguments)
+	case "get_client_status":
+		return te.getClientStatus(arguments)
+	case "get_client_config":
+		return te.getClientConfig(arguments)
+	case "enable_client":
+		return te.setClientEnabled(arguments, true)
+	case "disable_client":
+		return te.setClientEnabled(arguments, false)
+	case "batch_clients":
+		return te.batchClients(arguments)
+	case "list_trash":
+		return te.listTrash(arguments)
+	case "restore_client":
+		return te.restoreClient(arguments)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
@@ -181,6 +436,14 @@ func (te *ToolExecutor) listClusters() (string, error) {
 		return "", fmt.Errorf("获取集群列表失败: %w", err)
 	}
 
+	visible := make([]model.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if te.allows(model.RoleViewer, cluster.Name) {
+			visible = append(visible, cluster)
+		}
+	}
+	clusters = visible
+
 	result, err := json.Marshal(clusters)
 	if err != nil {
 		return "", fmt.Errorf("序列化结果失败: %w", err)
@@ -212,6 +475,7 @@ func (te *ToolExecutor) listClients(arguments string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("获取客户端列表失败: %w", err)
 	}
+	clients = te.visibleClients(clients)
 
 	result, err := json.Marshal(clients)
 	if err != nil {
@@ -231,6 +495,10 @@ func (te *ToolExecutor) getClient(arguments string) (string, error) {
 		return "", fmt.Errorf("解析参数失败: %w", err)
 	}
 
+	if err := te.authorize(model.RoleViewer, args.Cluster); err != nil {
+		return "", err
+	}
+
 	client, err := te.routeService.GetClient(args.Cluster, args.Identity)
 	if err != nil {
 		return "", fmt.Errorf("获取客户端失败: %w", err)
@@ -251,12 +519,17 @@ func (te *ToolExecutor) createClient(arguments string) (string, error) {
 		return "", fmt.Errorf("解析参数失败: %w", err)
 	}
 
+	if err := te.authorize(model.RoleOperator, req.Cluster); err != nil {
+		return "", err
+	}
+
 	// Convert ClientCreateRequest to Client for service layer
 	// The service will handle identity and IP generation
 	client := model.Client{
-		Cluster: req.Cluster,
-		Name:    req.Name,
-		Ciders:  req.Ciders,
+		Cluster:   req.Cluster,
+		Name:      req.Name,
+		Ciders:    req.Ciders,
+		ExpiresAt: req.ExpiresAt,
 	}
 
 	createdClient, err := te.routeService.CreateClient(client)
@@ -274,16 +547,21 @@ func (te *ToolExecutor) createClient(arguments string) (string, error) {
 
 func (te *ToolExecutor) updateClient(arguments string) (string, error) {
 	var args struct {
-		Cluster  string   `json:"cluster"`
-		Identity string   `json:"identity"`
-		Name     string   `json:"name"`
-		Ciders   []string `json:"ciders"`
+		Cluster   string   `json:"cluster"`
+		Identity  string   `json:"identity"`
+		Name      string   `json:"name"`
+		Ciders    []string `json:"ciders"`
+		ExpiresAt *string  `json:"expires_at"`
 	}
 
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
 		return "", fmt.Errorf("解析参数失败: %w", err)
 	}
 
+	if err := te.authorize(model.RoleOperator, args.Cluster); err != nil {
+		return "", err
+	}
+
 	// Get existing client first to preserve IP config
 	existingClient, err := te.routeService.GetClient(args.Cluster, args.Identity)
 	if err != nil {
@@ -299,11 +577,29 @@ func (te *ToolExecutor) updateClient(arguments string) (string, error) {
 		Mask:      existingClient.Mask,
 		Gateway:   existingClient.Gateway,
 		Ciders:    args.Ciders,
+		ExpiresAt: existingClient.ExpiresAt,
+		Labels:    existingClient.Labels,
+	}
+
+	if args.ExpiresAt != nil {
+		if *args.ExpiresAt == "" {
+			// A zero time tells UpdateClient to remove the expiry
+			updatedClient.ExpiresAt = &time.Time{}
+		} else {
+			expiresAt, err := time.Parse(time.RFC3339, *args.ExpiresAt)
+			if err != nil {
+				return "", fmt.Errorf("过期时间格式无效: %w", err)
+			}
+			updatedClient.ExpiresAt = &expiresAt
+		}
 	}
 
 	if err := te.routeService.UpdateClient(args.Cluster, args.Identity, updatedClient); err != nil {
 		return "", fmt.Errorf("更新客户端失败: %w", err)
 	}
+	if updatedClient.ExpiresAt != nil && updatedClient.ExpiresAt.IsZero() {
+		updatedClient.ExpiresAt = nil
+	}
 
 	result, err := json.Marshal(updatedClient)
 	if err != nil {
@@ -323,10 +619,244 @@ func (te *ToolExecutor) deleteClient(arguments string) (string, error) {
 		return "", fmt.Errorf("解析参数失败: %w", err)
 	}
 
+	if err := te.authorize(model.RoleOperator, args.Cluster); err != nil {
+		return "", err
+	}
+
 	if err := te.routeService.DeleteClient(args.Cluster, args.Identity); err != nil {
 		return "", fmt.Errorf("删除客户端失败: %w", err)
 	}
 
+	if te.routeService.TrashEnabled() {
+		return `{"success": true, "message": "客户端已移至回收站，可通过 restore_client 恢复"}`, nil
+	}
 	return `{"success": true, "message": "客户端已成功删除"}`, nil
 }
 
+func (te *ToolExecutor) getClientStatus(arguments string) (string, error) {
+	var args struct {
+		Cluster  string `json:"cluster"`
+		Identity string `json:"identity"`
+		State    string `json:"state"`
+	}
+
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("解析参数失败: %w", err)
+		}
+	}
+
+	var clients []model.Client
+	var err error
+
+	if args.Cluster != "" {
+		clients, err = te.routeService.GetClientsByCluster(args.Cluster)
+	} else {
+		clients, err = te.routeService.GetAllClients()
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("获取客户端列表失败: %w", err)
+	}
+	clients = te.visibleClients(clients)
+
+	statuses, err := te.presenceService.WithStatus(clients)
+	if err != nil {
+		return "", fmt.Errorf("获取客户端状态失败: %w", err)
+	}
+
+	result := make([]model.ClientWithStatus, 0, len(statuses))
+	for _, client := range statuses {
+		if args.Identity != "" && client.Identity != args.Identity {
+			continue
+		}
+		if args.State != "" && client.Status.State != args.State {
+			continue
+		}
+		result = append(result, client)
+	}
+
+	if args.Identity != "" && len(result) == 0 && args.State == "" {
+		return "", fmt.Errorf("获取客户端状态失败: client not found")
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+
+	return string(data), nil
+}
+
+func (te *ToolExecutor) getClientConfig(arguments string) (string, error) {
+	var args struct {
+		Cluster  string `json:"cluster"`
+		Identity string `json:"identity"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+
+	if err := te.authorize(model.RoleOperator, args.Cluster); err != nil {
+		return "", err
+	}
+
+	cfg, err := te.clientConfigService.GetClientConfig(args.Cluster, args.Identity)
+	if err != nil {
+		return "", fmt.Errorf("获取客户端配置失败: %w", err)
+	}
+
+	result, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+
+	return string(result), nil
+}
+
+func (te *ToolExecutor) setClientEnabled(arguments string, enabled bool) (string, error) {
+	var args struct {
+		Cluster  string `json:"cluster"`
+		Identity string `json:"identity"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+
+	if err := te.authorize(model.RoleOperator, args.Cluster); err != nil {
+		return "", err
+	}
+
+	var client *model.Client
+	var err error
+	if enabled {
+		client, err = te.routeService.EnableClient(args.Cluster, args.Identity)
+	} else {
+		client, err = te.routeService.DisableClient(args.Cluster, args.Identity)
+	}
+	if err != nil {
+		if enabled {
+			return "", fmt.Errorf("启用客户端失败: %w", err)
+		}
+		return "", fmt.Errorf("禁用客户端失败: %w", err)
+	}
+
+	result, err := json.Marshal(client)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+
+	return string(result), nil
+}
+
+func (te *ToolExecutor) batchClients(arguments string) (string, error) {
+	var req model.BatchRequest
+
+	if err := json.Unmarshal([]byte(arguments), &req); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+
+	for _, op := range req.Operations {
+		if err := te.authorize(model.RoleOperator, op.Cluster); err != nil {
+			return "", err
+		}
+	}
+
+	batchResult, err := te.routeService.ApplyBatch(req.Operations)
+	if err != nil && batchResult == nil {
+		return "", fmt.Errorf("批量操作失败: %w", err)
+	}
+
+	// Return per-item results even when the batch was rolled back so the
+	// model can explain which operation failed
+	result, marshalErr := json.Marshal(batchResult)
+	if marshalErr != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", marshalErr)
+	}
+
+	return string(result), nil
+}
+
+func (te *ToolExecutor) listTrash(arguments string) (string, error) {
+	var args struct {
+		Cluster string `json:"cluster"`
+	}
+
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("解析参数失败: %w", err)
+		}
+	}
+
+	entries, err := te.routeService.ListTrash(args.Cluster)
+	if err != nil {
+		return "", fmt.Errorf("获取回收站失败: %w", err)
+	}
+
+	visible := make([]model.TrashEntry, 0, len(entries))
+	for _, entry := range entries {
+		if te.allows(model.RoleViewer, entry.Cluster) {
+			visible = append(visible, entry)
+		}
+	}
+	entries = visible
+
+	result, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+
+	return string(result), nil
+}
+
+func (te *ToolExecutor) restoreClient(arguments string) (string, error) {
+	var args struct {
+		ID       uint   `json:"id"`
+		Cluster  string `json:"cluster"`
+		Identity string `json:"identity"`
+	}
+
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+
+	// Check the cluster of the entry, or the one given to restore from
+	cluster := args.Cluster
+	if args.ID != 0 {
+		entry, err := te.routeService.GetTrashEntry(args.ID)
+		if err != nil {
+			return "", fmt.Errorf("恢复客户端失败: %w", err)
+		}
+		cluster = entry.Cluster
+	}
+	if cluster != "" {
+		if err := te.authorize(model.RoleOperator, cluster); err != nil {
+			return "", err
+		}
+	}
+
+	var restored interface{}
+	var err error
+	switch {
+	case args.ID != 0:
+		restored, err = te.routeService.RestoreFromTrash(args.ID)
+	case args.Cluster != "" && args.Identity != "":
+		restored, err = te.routeService.RestoreClientFromTrash(args.Cluster, args.Identity)
+	case args.Cluster != "":
+		restored, err = te.routeService.RestoreClusterFromTrash(args.Cluster)
+	default:
+		return "", fmt.Errorf("需要提供 id 或 cluster")
+	}
+	if err != nil {
+		return "", fmt.Errorf("恢复客户端失败: %w", err)
+	}
+
+	result, err := json.Marshal(restored)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,315 @@
+// Package clientconf renders rustun client configurations as the files
+// offered for download: an environment file, an install script and a zip
+// bundle with both plus a systemd unit.
+package clientconf
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Supported formats
+const (
+	FormatJSON   = "json"
+	FormatEnv    = "env"
+	FormatScript = "sh"
+	FormatZip    = "zip"
+)
+
+// ParseFormat normalizes a format name
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "env", "conf":
+		return FormatEnv, nil
+	case "sh", "script":
+		return FormatScript, nil
+	case "zip":
+		return FormatZip, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: must be json, env, sh or zip", format)
+	}
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatEnv:
+		return "text/plain; charset=utf-8"
+	case FormatScript:
+		return "text/x-shellscript; charset=utf-8"
+	case FormatZip:
+		return "application/zip"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// Filename returns the download name of a client configuration. The client
+// name is used if it is safe in a file name, the identity otherwise.
+func Filename(cfg *model.ClientConfig, format string) string {
+	base := cfg.Identity
+	if cfg.Name != "" && strings.IndexFunc(cfg.Name, unsafeFilenameRune) < 0 {
+		base = cfg.Name
+	}
+	base = strings.Map(func(r rune) rune {
+		if unsafeFilenameRune(r) {
+			return '_'
+		}
+		return r
+	}, "rustun-"+cfg.Cluster+"-"+base)
+
+	return base + "." + format
+}
+
+func unsafeFilenameRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+}
+
+// Args returns the client command line, starting with the binary
+func Args(cfg *model.ClientConfig) []string {
+	args := []string{cfg.Binary, "-s", cfg.Server, "-i", cfg.Identity}
+	if cfg.Crypto != "" {
+		args = append(args, "-c", cfg.Crypto)
+	}
+	if cfg.EnableP2P {
+		args = append(args, "--enable-p2p")
+	}
+	if cfg.KeepaliveInterval > 0 {
+		args = append(args, "--keepalive-interval", strconv.Itoa(cfg.KeepaliveInterval))
+	}
+	return args
+}
+
+// Command returns the client command line quoted for a POSIX shell
+func Command(cfg *model.ClientConfig) string {
+	args := Args(cfg)
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Render writes a client configuration in the given format
+func Render(w io.Writer, format string, cfg *model.ClientConfig) error {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case FormatEnv:
+		_, err := io.WriteString(w, envFile(cfg))
+		return err
+	case FormatScript:
+		_, err := io.WriteString(w, installScript(cfg))
+		return err
+	case FormatZip:
+		return writeZip(w, cfg)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// envPath and unitName are where the install script puts the client's files
+func envPath(cfg *model.ClientConfig) string {
+	return "/etc/rustun/client-" + cfg.Cluster + ".env"
+}
+
+func unitName(cfg *model.ClientConfig) string {
+	return "rustun-client-" + cfg.Cluster + ".service"
+}
+
+func header(cfg *model.ClientConfig) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# rustun client configuration for %s/%s", oneLine(cfg.Cluster), oneLine(cfg.Identity))
+	if cfg.Name != "" {
+		fmt.Fprintf(&b, " (%s)", oneLine(cfg.Name))
+	}
+	fmt.Fprintf(&b, "\n# Generated by rustun-dashboard at %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# Private IP: %s\n", oneLine(cfg.PrivateIP))
+	if !cfg.Enabled {
+		b.WriteString("# WARNING: this client is disabled and will be refused until it is enabled again\n")
+	}
+	return b.String()
+}
+
+// envFile renders the configuration as a systemd environment file
+func envFile(cfg *model.ClientConfig) string {
+	var b strings.Builder
+	b.WriteString(header(cfg))
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "RUSTUN_SERVER=%s\n", shellQuote(cfg.Server))
+	fmt.Fprintf(&b, "RUSTUN_IDENTITY=%s\n", shellQuote(cfg.Identity))
+	if cfg.Crypto != "" {
+		fmt.Fprintf(&b, "RUSTUN_CRYPTO=%s\n", shellQuote(cfg.Crypto))
+	}
+	if cfg.KeepaliveInterval > 0 {
+		fmt.Fprintf(&b, "RUSTUN_KEEPALIVE_INTERVAL=%d\n", cfg.KeepaliveInterval)
+	}
+	if cfg.NextCrypto != "" {
+		b.WriteString("\n# Pending key of the cluster; switch to it once it is activated\n")
+		fmt.Fprintf(&b, "# RUSTUN_CRYPTO=%s\n", oneLine(shellQuote(cfg.NextCrypto)))
+	}
+	return b.String()
+}
+
+// systemdUnit renders a service running the client with the settings from
+// the environment file, so the server and key can be changed there
+func systemdUnit(cfg *model.ClientConfig) string {
+	exec := []string{cfg.Binary, "-s", "${RUSTUN_SERVER}", "-i", "${RUSTUN_IDENTITY}"}
+	if cfg.Crypto != "" {
+		exec = append(exec, "-c", "${RUSTUN_CRYPTO}")
+	}
+	if cfg.EnableP2P {
+		exec = append(exec, "--enable-p2p")
+	}
+	if cfg.KeepaliveInterval > 0 {
+		exec = append(exec, "--keepalive-interval", "${RUSTUN_KEEPALIVE_INTERVAL}")
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=Rustun VPN client (%s/%s)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+EnvironmentFile=%s
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, oneLine(cfg.Cluster), oneLine(cfg.Identity), oneLine(envPath(cfg)), strings.Join(exec, " "))
+}
+
+// installScript renders a script that writes the environment file and
+// installs and starts the systemd unit, or prints the command line on
+// systems without systemd
+func installScript(cfg *model.ClientConfig) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(header(cfg))
+	fmt.Fprintf(&b, `set -e
+
+BINARY=%s
+if [ ! -x "$BINARY" ]; then
+  echo "rustun client not found at $BINARY" >&2
+  echo "Download it from https://github.com/smartethnet/rustun/releases/latest" >&2
+  exit 1
+fi
+
+if [ "$(id -u)" -ne 0 ]; then
+  echo "This script must be run as root" >&2
+  exit 1
+fi
+
+mkdir -p /etc/rustun
+cat > %s <<'RUSTUN_ENV'
+%sRUSTUN_ENV
+chmod 600 %s
+
+if command -v systemctl >/dev/null 2>&1; then
+  cat > %s <<'RUSTUN_UNIT'
+%sRUSTUN_UNIT
+  systemctl daemon-reload
+  systemctl enable --now %s
+  echo "rustun client started as "%s
+else
+  echo "systemd not found; start the client with:"
+  echo %s
+fi
+`,
+		shellQuote(cfg.Binary),
+		shellQuote(envPath(cfg)), heredoc(envFile(cfg), "RUSTUN_ENV"), shellQuote(envPath(cfg)),
+		shellQuote("/etc/systemd/system/"+unitName(cfg)), heredoc(systemdUnit(cfg), "RUSTUN_UNIT"),
+		shellQuote(unitName(cfg)), shellQuote(unitName(cfg)),
+		shellQuote(Command(cfg)),
+	)
+	return b.String()
+}
+
+// writeZip writes a bundle with the install script, environment file,
+// systemd unit and a README with the plain command line
+func writeZip(w io.Writer, cfg *model.ClientConfig) error {
+	readme := header(cfg) + "\nRun the client directly:\n\n  sudo " + Command(cfg) +
+		"\n\nOr install it as a systemd service:\n\n  sudo sh install.sh\n"
+
+	files := []struct {
+		name string
+		mode fs.FileMode
+		body string
+	}{
+		{"install.sh", 0o755, installScript(cfg)},
+		{path.Base(envPath(cfg)), 0o600, envFile(cfg)},
+		{unitName(cfg), 0o644, systemdUnit(cfg)},
+		{"README.txt", 0o644, readme},
+	}
+
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for _, file := range files {
+		fh := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now}
+		fh.SetMode(file.mode)
+		fw, err := zw.CreateHeader(fh)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// oneLine removes newlines and other control characters from a value
+// written into a comment, so it cannot start a new line of the file
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// heredoc drops lines of body that would end the here-document named
+// delimiter early. Values are quoted on a single line and comments go
+// through oneLine, so only a malformed setting can produce such a line.
+func heredoc(body, delimiter string) string {
+	lines := strings.SplitAfter(body, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.TrimRight(line, "\n") != delimiter {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// shellQuote quotes s for a POSIX shell if needed
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
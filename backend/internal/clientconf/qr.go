@@ -0,0 +1,120 @@
+package clientconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// QR code contents
+const (
+	QRContentJSON    = "json"    // Compact JSON with the connection settings
+	QRContentCommand = "command" // Client command line
+)
+
+// QR code image size limits in pixels
+const (
+	MinQRSize = 64
+	MaxQRSize = 2048
+)
+
+// QROptions control how QR codes are rendered
+type QROptions struct {
+	Size  int                  // Width and height in pixels
+	Level qrcode.RecoveryLevel // Error correction level
+}
+
+// ParseQRLevel parses an error correction level: L, M, Q or H, or low,
+// medium, high or highest
+func ParseQRLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "l", "low":
+		return qrcode.Low, nil
+	case "", "m", "medium":
+		return qrcode.Medium, nil
+	case "q", "high":
+		return qrcode.High, nil
+	case "h", "highest":
+		return qrcode.Highest, nil
+	default:
+		return 0, fmt.Errorf("unsupported QR level %q: must be L, M, Q or H", level)
+	}
+}
+
+// qrPayload is the JSON encoded in configuration QR codes
+type qrPayload struct {
+	Cluster           string `json:"cluster"`
+	Identity          string `json:"identity"`
+	Name              string `json:"name,omitempty"`
+	Server            string `json:"server"`
+	Crypto            string `json:"crypto,omitempty"`
+	EnableP2P         bool   `json:"enable_p2p,omitempty"`
+	KeepaliveInterval int    `json:"keepalive_interval,omitempty"`
+}
+
+// QRContent returns the text encoded in the QR code of a configuration
+func QRContent(cfg *model.ClientConfig, content string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(content)) {
+	case "", QRContentJSON:
+		data, err := json.Marshal(qrPayload{
+			Cluster:           cfg.Cluster,
+			Identity:          cfg.Identity,
+			Name:              cfg.Name,
+			Server:            cfg.Server,
+			Crypto:            cfg.Crypto,
+			EnableP2P:         cfg.EnableP2P,
+			KeepaliveInterval: cfg.KeepaliveInterval,
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case QRContentCommand:
+		return Command(cfg), nil
+	default:
+		return "", fmt.Errorf("unsupported QR content %q: must be json or command", content)
+	}
+}
+
+// QRPNG encodes text as a PNG QR code
+func QRPNG(text string, opts QROptions) ([]byte, error) {
+	return qrcode.Encode(text, opts.Level, opts.Size)
+}
+
+// QRSVG encodes text as an SVG QR code made of one path, scaled to the
+// requested size
+func QRSVG(text string, opts QROptions) ([]byte, error) {
+	code, err := qrcode.New(text, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// Draw each horizontal run of dark modules as one rectangle
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	svg := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+<rect width="100%%" height="100%%" fill="#ffffff"/>
+<path fill="#000000" d="%s"/>
+</svg>
+`, opts.Size, opts.Size, modules, modules, path.String())
+	return []byte(svg), nil
+}
@@ -0,0 +1,219 @@
+// Package deploy delivers rendered routes documents to the rustun servers
+// that read them: files in local directories, HTTP PUT endpoints and exec
+// hooks.
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Target types
+const (
+	TypeDir  = "dir"
+	TypeHTTP = "http"
+	TypeExec = "exec"
+)
+
+// DefaultFilename is the name of the routes file written to dir targets
+const DefaultFilename = "routes.json"
+
+// maxOutput caps how much of a failed hook's output or HTTP response is kept
+// in the error
+const maxOutput = 512
+
+// Target receives routes documents
+type Target interface {
+	// Deliver sends a routes document to the target
+	Deliver(ctx context.Context, routes []byte) error
+
+	// Destination describes where routes are delivered, e.g. a path or URL
+	Destination() string
+}
+
+// Options configure a target
+type Options struct {
+	Name     string
+	Type     string            // dir, http or exec
+	Path     string            // dir: directory the routes file is written to
+	Filename string            // dir: name of the routes file, defaults to routes.json
+	URL      string            // http: endpoint the routes are PUT to
+	Headers  map[string]string // http: extra request headers, e.g. Authorization
+	Command  []string          // exec: program and arguments; routes are passed on stdin
+}
+
+// NewTarget creates a target from its options
+func NewTarget(opts Options) (Target, error) {
+	switch opts.Type {
+	case TypeDir:
+		if opts.Path == "" {
+			return nil, fmt.Errorf("target %s: path is required", opts.Name)
+		}
+		filename := opts.Filename
+		if filename == "" {
+			filename = DefaultFilename
+		}
+		if filename != filepath.Base(filename) {
+			return nil, fmt.Errorf("target %s: filename must not contain a directory", opts.Name)
+		}
+		return &dirTarget{path: filepath.Join(opts.Path, filename)}, nil
+	case TypeHTTP:
+		if !strings.HasPrefix(opts.URL, "http://") && !strings.HasPrefix(opts.URL, "https://") {
+			return nil, fmt.Errorf("target %s: url must be an http or https URL", opts.Name)
+		}
+		return &httpTarget{url: opts.URL, headers: opts.Headers, client: &http.Client{}}, nil
+	case TypeExec:
+		if len(opts.Command) == 0 || opts.Command[0] == "" {
+			return nil, fmt.Errorf("target %s: command is required", opts.Name)
+		}
+		return &execTarget{name: opts.Name, command: opts.Command}, nil
+	default:
+		return nil, fmt.Errorf("target %s: unsupported type %q: must be dir, http or exec", opts.Name, opts.Type)
+	}
+}
+
+// Render builds the routes document for the enabled clients of the given
+// clusters, or of all clusters if none are given, in the format of the
+// routes file
+func Render(clients []model.Client, clusters []string) ([]byte, int, error) {
+	wanted := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		wanted[cluster] = true
+	}
+
+	routes := make(model.RouteConfig, 0, len(clients))
+	for _, client := range clients {
+		if !client.Enabled {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[client.Cluster] {
+			continue
+		}
+		routes = append(routes, client)
+	}
+
+	data, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal routes: %w", err)
+	}
+	return data, len(routes), nil
+}
+
+// dirTarget writes the routes file into a local directory, e.g. one shared
+// with a rustun server
+type dirTarget struct {
+	path string
+}
+
+func (t *dirTarget) Destination() string {
+	return t.path
+}
+
+// Deliver replaces the routes file atomically so the server never reads a
+// partial document
+func (t *dirTarget) Deliver(ctx context.Context, routes []byte) error {
+	dir := filepath.Dir(t.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(t.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(routes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", t.path, err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod %s: %w", t.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", t.path, err)
+	}
+
+	if err := os.Rename(tmpPath, t.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", t.path, err)
+	}
+	return nil
+}
+
+// httpTarget PUTs the routes document to an endpoint
+type httpTarget struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (t *httpTarget) Destination() string {
+	return t.url
+}
+
+// Deliver succeeds on any 2xx response
+func (t *httpTarget) Deliver(ctx context.Context, routes []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.url, bytes.NewReader(routes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// execTarget runs a hook with the routes document on stdin and the target
+// name in RUSTUN_DEPLOY_TARGET
+type execTarget struct {
+	name    string
+	command []string
+}
+
+func (t *execTarget) Destination() string {
+	return strings.Join(t.command, " ")
+}
+
+// Deliver succeeds if the hook exits with status 0
+func (t *execTarget) Deliver(ctx context.Context, routes []byte) error {
+	cmd := exec.CommandContext(ctx, t.command[0], t.command[1:]...)
+	cmd.Stdin = bytes.NewReader(routes)
+	cmd.Env = append(os.Environ(), "RUSTUN_DEPLOY_TARGET="+t.name)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		out := strings.TrimSpace(string(output))
+		if len(out) > maxOutput {
+			out = "..." + out[len(out)-maxOutput:]
+		}
+		if out == "" {
+			return err
+		}
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
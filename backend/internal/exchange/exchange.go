@@ -0,0 +1,369 @@
+// Package exchange converts client lists to and from the JSON, CSV and YAML
+// formats used by the import and export endpoints.
+package exchange
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+
+	// FormatRoutes is the routes.json document rustun reads: enabled clients only
+	FormatRoutes = "routes"
+)
+
+// csvHeader lists the CSV columns in export order
+var csvHeader = []string{"cluster", "identity", "name", "private_ip", "mask", "gateway", "ciders", "expires_at", "enabled", "labels"}
+
+// cidersSeparator joins multiple CIDRs inside a single CSV cell
+const cidersSeparator = ";"
+
+// ParseFormat normalizes a format name
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "routes":
+		return FormatRoutes, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: must be json, csv, yaml or routes", format)
+	}
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatYAML:
+		return "application/yaml; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// row is the interchange representation of a client
+type row struct {
+	Cluster   string   `json:"cluster" yaml:"cluster"`
+	Identity  string   `json:"identity,omitempty" yaml:"identity,omitempty"`
+	Name      string   `json:"name,omitempty" yaml:"name,omitempty"`
+	PrivateIP string   `json:"private_ip,omitempty" yaml:"private_ip,omitempty"`
+	Mask      string   `json:"mask,omitempty" yaml:"mask,omitempty"`
+	Gateway   string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Ciders    []string `json:"ciders" yaml:"ciders"`
+	ExpiresAt string   `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+func toRow(client model.Client) row {
+	r := row{
+		Cluster:   client.Cluster,
+		Identity:  client.Identity,
+		Name:      client.Name,
+		PrivateIP: client.PrivateIP,
+		Mask:      client.Mask,
+		Gateway:   client.Gateway,
+		Ciders:    client.Ciders,
+		Labels:    client.Labels,
+	}
+	if r.Ciders == nil {
+		r.Ciders = []string{}
+	}
+	if client.ExpiresAt != nil {
+		r.ExpiresAt = client.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	enabled := client.Enabled
+	r.Enabled = &enabled
+	return r
+}
+
+func (r row) toRecord(index int) model.ImportRecord {
+	record := model.ImportRecord{
+		Row: index,
+		Client: model.Client{
+			Cluster:   strings.TrimSpace(r.Cluster),
+			Identity:  strings.TrimSpace(r.Identity),
+			Name:      strings.TrimSpace(r.Name),
+			PrivateIP: strings.TrimSpace(r.PrivateIP),
+			Mask:      strings.TrimSpace(r.Mask),
+			Gateway:   strings.TrimSpace(r.Gateway),
+			Ciders:    make([]string, 0, len(r.Ciders)),
+			Enabled:   true,
+		},
+	}
+	for _, cidr := range r.Ciders {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			record.Client.Ciders = append(record.Client.Ciders, cidr)
+		}
+	}
+	if r.Enabled != nil {
+		record.Client.Enabled = *r.Enabled
+	}
+	for k, v := range r.Labels {
+		if k = strings.TrimSpace(k); k != "" {
+			if record.Client.Labels == nil {
+				record.Client.Labels = make(map[string]string, len(r.Labels))
+			}
+			record.Client.Labels[k] = strings.TrimSpace(v)
+		}
+	}
+	if expires := strings.TrimSpace(r.ExpiresAt); expires != "" {
+		t, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			record.Err = fmt.Sprintf("invalid expires_at %q: must be RFC3339", expires)
+		} else {
+			record.Client.ExpiresAt = &t
+		}
+	}
+	return record
+}
+
+// Filename returns the download file name for an export
+func Filename(base, format string) string {
+	if format == FormatRoutes {
+		return base + ".routes.json"
+	}
+	return base + "." + format
+}
+
+// Encode writes clients in the given format
+func Encode(w io.Writer, format string, clients []model.Client) error {
+	if format == FormatRoutes {
+		return encodeRoutes(w, clients)
+	}
+
+	rows := make([]row, len(clients))
+	for i, client := range clients {
+		rows[i] = toRow(client)
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(rows)
+
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(rows); err != nil {
+			return err
+		}
+		return encoder.Close()
+
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := writer.Write([]string{
+				r.Cluster,
+				r.Identity,
+				r.Name,
+				r.PrivateIP,
+				r.Mask,
+				r.Gateway,
+				strings.Join(r.Ciders, cidersSeparator),
+				r.ExpiresAt,
+				strconv.FormatBool(*r.Enabled),
+				formatLabels(r.Labels),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// Decode parses a document in the given format. Malformed documents return
+// an error; problems confined to a single row are reported on its record.
+func Decode(r io.Reader, format string) ([]model.ImportRecord, error) {
+	switch format {
+	case FormatJSON, FormatRoutes:
+		var rows []row
+		if err := json.NewDecoder(r).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return toRecords(rows), nil
+
+	case FormatYAML:
+		var rows []row
+		if err := yaml.NewDecoder(r).Decode(&rows); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		return toRecords(rows), nil
+
+	case FormatCSV:
+		return decodeCSV(r)
+
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// encodeRoutes writes the enabled clients exactly as the file repository
+// stores them in routes.json
+func encodeRoutes(w io.Writer, clients []model.Client) error {
+	enabled := make([]model.Client, 0, len(clients))
+	for _, client := range clients {
+		if client.Enabled {
+			enabled = append(enabled, client)
+		}
+	}
+
+	data, err := json.MarshalIndent(enabled, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func toRecords(rows []row) []model.ImportRecord {
+	records := make([]model.ImportRecord, len(rows))
+	for i, r := range rows {
+		records[i] = r.toRecord(i + 1)
+	}
+	return records
+}
+
+// decodeCSV parses a CSV document whose first line names the columns.
+// Only the cluster column is mandatory; columns may appear in any order.
+func decodeCSV(r io.Reader) ([]model.ImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []model.ImportRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["cluster"]; !ok {
+		return nil, fmt.Errorf("CSV header must contain a cluster column")
+	}
+
+	records := make([]model.ImportRecord, 0)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(fields) {
+				return fields[i]
+			}
+			return ""
+		}
+
+		r := row{
+			Cluster:   get("cluster"),
+			Identity:  get("identity"),
+			Name:      get("name"),
+			PrivateIP: get("private_ip"),
+			Mask:      get("mask"),
+			Gateway:   get("gateway"),
+			ExpiresAt: get("expires_at"),
+		}
+		if ciders := strings.TrimSpace(get("ciders")); ciders != "" {
+			r.Ciders = strings.Split(ciders, cidersSeparator)
+		}
+		labels, labelsErr := parseLabels(get("labels"))
+		r.Labels = labels
+
+		var enabledErr string
+		if raw := strings.TrimSpace(get("enabled")); raw != "" {
+			enabled, err := strconv.ParseBool(raw)
+			if err != nil {
+				enabledErr = fmt.Sprintf("invalid enabled %q: must be true or false", raw)
+			} else {
+				r.Enabled = &enabled
+			}
+		}
+
+		record := r.toRecord(line)
+		if record.Err == "" {
+			record.Err = enabledErr
+		}
+		if record.Err == "" {
+			record.Err = labelsErr
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// formatLabels writes labels as key=value pairs in key order, separated like
+// ciders
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, cidersSeparator)
+}
+
+// parseLabels reads labels written by formatLabels
+func parseLabels(raw string) (map[string]string, string) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, ""
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(raw, cidersSeparator) {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Sprintf("invalid label %q: must be key=value", pair)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels, ""
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/smartethnet/rustun-dashboard/internal/agent"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 
 	"github.com/gin-gonic/gin"
@@ -45,6 +46,8 @@ func (h *AgentHandler) Chat(c *gin.Context) {
 		))
 		return
 	}
+	req.User = c.GetString(middleware.UserKey)
+	req.Access = middleware.Authorizer(c)
 
 	resp, err := h.agent.Chat(req)
 	if err != nil {
@@ -79,6 +82,8 @@ func (h *AgentHandler) StreamChat(c *gin.Context) {
 		))
 		return
 	}
+	req.User = c.GetString(middleware.UserKey)
+	req.Access = middleware.Authorizer(c)
 
 	// Set headers for SSE
 	c.Header("Content-Type", "text/event-stream")
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type APITokenHandler struct {
+	tokenService *service.APITokenService
+	userService  *service.UserService
+}
+
+func NewAPITokenHandler(tokenService *service.APITokenService, userService *service.UserService) *APITokenHandler {
+	return &APITokenHandler{
+		tokenService: tokenService,
+		userService:  userService,
+	}
+}
+
+// ListOwnTokens godoc
+// @Summary List own API tokens
+// @Description List the API tokens of the authenticated user, newest first, without the token values
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.APIToken}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/tokens [get]
+func (h *APITokenHandler) ListOwnTokens(c *gin.Context) {
+	h.listTokens(c, middleware.Account(c))
+}
+
+// CreateOwnToken godoc
+// @Summary Create an own API token
+// @Description Issue an API token acting for the authenticated user, to be sent as "Authorization: Bearer". It can do what both the user's roles and its scopes allow, optionally only in some clusters. The token is only returned in this response.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body model.APITokenRequest true "Token name, scopes, clusters and lifetime"
+// @Success 201 {object} model.Response{data=model.APITokenCreated}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/tokens [post]
+func (h *APITokenHandler) CreateOwnToken(c *gin.Context) {
+	h.createToken(c, middleware.Account(c))
+}
+
+// RevokeOwnToken godoc
+// @Summary Revoke an own API token
+// @Description Make an API token of the authenticated user unusable
+// @Tags auth
+// @Produce json
+// @Param token_id path int true "Token ID"
+// @Success 200 {object} model.Response{data=model.APIToken}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/tokens/{token_id} [delete]
+func (h *APITokenHandler) RevokeOwnToken(c *gin.Context) {
+	h.revokeToken(c, middleware.Account(c))
+}
+
+// ListUserTokens godoc
+// @Summary List API tokens of a user
+// @Description List the API tokens of a dashboard account, newest first, without the token values
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} model.Response{data=[]model.APIToken}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id}/tokens [get]
+func (h *APITokenHandler) ListUserTokens(c *gin.Context) {
+	if user, ok := pathUser(c, h.userService); ok {
+		h.listTokens(c, user)
+	}
+}
+
+// CreateUserToken godoc
+// @Summary Create an API token for a user
+// @Description Issue an API token acting for a dashboard account, e.g. a service account for CI. The token is only returned in this response.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body model.APITokenRequest true "Token name, scopes, clusters and lifetime"
+// @Success 201 {object} model.Response{data=model.APITokenCreated}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id}/tokens [post]
+func (h *APITokenHandler) CreateUserToken(c *gin.Context) {
+	if user, ok := pathUser(c, h.userService); ok {
+		h.createToken(c, user)
+	}
+}
+
+// RevokeUserToken godoc
+// @Summary Revoke an API token of a user
+// @Description Make an API token of a dashboard account unusable
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Param token_id path int true "Token ID"
+// @Success 200 {object} model.Response{data=model.APIToken}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id}/tokens/{token_id} [delete]
+func (h *APITokenHandler) RevokeUserToken(c *gin.Context) {
+	if user, ok := pathUser(c, h.userService); ok {
+		h.revokeToken(c, user)
+	}
+}
+
+func (h *APITokenHandler) listTokens(c *gin.Context, owner *model.User) {
+	tokens, err := h.tokenService.ListTokens(owner.ID)
+	if err != nil {
+		apiTokenError(c, "Failed to list API tokens", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(tokens))
+}
+
+func (h *APITokenHandler) createToken(c *gin.Context, owner *model.User) {
+	var req model.APITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	token, err := h.tokenService.CreateToken(middleware.Actor(c).Name, owner, req)
+	if err != nil {
+		apiTokenError(c, "Failed to create API token", err)
+		return
+	}
+
+	// The token cannot be retrieved again, so keep it out of caches
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, model.SuccessResponse(token))
+}
+
+func (h *APITokenHandler) revokeToken(c *gin.Context, owner *model.User) {
+	id, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid token ID",
+			"token ID must be a positive integer",
+		))
+		return
+	}
+
+	token, err := h.tokenService.RevokeToken(middleware.Actor(c).Name, owner.ID, uint(id))
+	if err != nil {
+		apiTokenError(c, "Failed to revoke API token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(token))
+}
+
+func apiTokenError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "api token not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAudit godoc
+// @Summary List audit entries
+// @Description Get audit log entries, newest first
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param cluster query string false "Filter by cluster name"
+// @Param identity query string false "Filter by client identity"
+// @Param action query string false "Filter by action, e.g. client.expired"
+// @Param limit query int false "Maximum number of entries (default 100)"
+// @Success 200 {object} model.Response{data=[]model.AuditEntry}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/audit [get]
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid limit",
+				"limit must be a non-negative integer",
+			))
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := h.auditService.List(model.AuditFilter{
+		Cluster:  c.Query("cluster"),
+		Identity: c.Query("identity"),
+		Action:   c.Query("action"),
+		Limit:    limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get audit log",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(entries))
+}
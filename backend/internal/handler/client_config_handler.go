@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/clientconf"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type ClientConfigHandler struct {
+	clientConfigService *service.ClientConfigService
+	qrDefaults          clientconf.QROptions
+}
+
+func NewClientConfigHandler(clientConfigService *service.ClientConfigService, qrDefaults clientconf.QROptions) *ClientConfigHandler {
+	return &ClientConfigHandler{
+		clientConfigService: clientConfigService,
+		qrDefaults:          qrDefaults,
+	}
+}
+
+// GetClientConfig godoc
+// @Summary Get client configuration
+// @Description Render a ready-to-run rustun client configuration: JSON with the command line, a systemd environment file, an install script, or a zip bundle with all of them
+// @Tags clients
+// @Produce json
+// @Produce text/plain
+// @Produce text/x-shellscript
+// @Produce application/zip
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param format query string false "json (default), env, sh or zip"
+// @Success 200 {object} model.Response{data=model.ClientConfig}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/config [get]
+func (h *ClientConfigHandler) GetClientConfig(c *gin.Context) {
+	format, err := clientconf.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid format",
+			err.Error(),
+		))
+		return
+	}
+
+	cfg, err := h.clientConfigService.GetClientConfig(c.Param("cluster"), c.Param("identity"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "client not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to render client configuration",
+			err.Error(),
+		))
+		return
+	}
+
+	if format == clientconf.FormatJSON {
+		c.JSON(http.StatusOK, model.SuccessResponse(cfg))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := clientconf.Render(&buf, format, cfg); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to render client configuration",
+			err.Error(),
+		))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", clientconf.Filename(cfg, format)))
+	c.Data(http.StatusOK, clientconf.ContentType(format), buf.Bytes())
+}
+
+// GetClientConfigPNG godoc
+// @Summary Get client configuration QR code (PNG)
+// @Description Render the client's connection settings as a PNG QR code for enrolling devices from a phone
+// @Tags clients
+// @Produce image/png
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param content query string false "json (default, connection settings) or command (client command line)"
+// @Param size query int false "Width and height in pixels (64-2048)"
+// @Param level query string false "Error correction level: L, M, Q or H"
+// @Success 200 {file} file
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/config.png [get]
+func (h *ClientConfigHandler) GetClientConfigPNG(c *gin.Context) {
+	h.renderQR(c, "image/png", clientconf.QRPNG)
+}
+
+// GetClientConfigSVG godoc
+// @Summary Get client configuration QR code (SVG)
+// @Description Render the client's connection settings as an SVG QR code for enrolling devices from a phone
+// @Tags clients
+// @Produce image/svg+xml
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param content query string false "json (default, connection settings) or command (client command line)"
+// @Param size query int false "Width and height in pixels (64-2048)"
+// @Param level query string false "Error correction level: L, M, Q or H"
+// @Success 200 {file} file
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/config.svg [get]
+func (h *ClientConfigHandler) GetClientConfigSVG(c *gin.Context) {
+	h.renderQR(c, "image/svg+xml", clientconf.QRSVG)
+}
+
+func (h *ClientConfigHandler) renderQR(c *gin.Context, contentType string, encode func(string, clientconf.QROptions) ([]byte, error)) {
+	opts, ok := qrOptions(c, h.qrDefaults)
+	if !ok {
+		return
+	}
+
+	cfg, err := h.clientConfigService.GetClientConfig(c.Param("cluster"), c.Param("identity"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "client not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to render client configuration",
+			err.Error(),
+		))
+		return
+	}
+
+	text, err := clientconf.QRContent(cfg, c.Query("content"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid content",
+			err.Error(),
+		))
+		return
+	}
+
+	image, err := encode(text, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to render QR code",
+			err.Error(),
+		))
+		return
+	}
+
+	// The image carries the client's key, so keep it out of shared caches
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, image)
+}
+
+// qrOptions reads the size and level query parameters on top of defaults and
+// responds with 400 if they are invalid
+func qrOptions(c *gin.Context, defaults clientconf.QROptions) (clientconf.QROptions, bool) {
+	opts := defaults
+	if raw := c.Query("size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < clientconf.MinQRSize || size > clientconf.MaxQRSize {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid size",
+				fmt.Sprintf("size must be an integer between %d and %d", clientconf.MinQRSize, clientconf.MaxQRSize),
+			))
+			return opts, false
+		}
+		opts.Size = size
+	}
+	if raw := c.Query("level"); raw != "" {
+		level, err := clientconf.ParseQRLevel(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid level",
+				err.Error(),
+			))
+			return opts, false
+		}
+		opts.Level = level
+	}
+	return opts, true
+}
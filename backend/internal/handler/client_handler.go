@@ -1,43 +1,70 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 	"github.com/smartethnet/rustun-dashboard/internal/service"
 )
 
 type ClientHandler struct {
-	routeService *service.RouteService
+	routeService    *service.RouteService
+	presenceService *service.PresenceService
+	upcomingWindow  time.Duration
 }
 
-func NewClientHandler(routeService *service.RouteService) *ClientHandler {
+func NewClientHandler(routeService *service.RouteService, presenceService *service.PresenceService, upcomingWindow time.Duration) *ClientHandler {
 	return &ClientHandler{
-		routeService: routeService,
+		routeService:    routeService,
+		presenceService: presenceService,
+		upcomingWindow:  upcomingWindow,
 	}
 }
 
 // ListClients godoc
 // @Summary List all clients
-// @Description Get all clients across all clusters
+// @Description Get all clients across all clusters, with their connection status
 // @Tags clients
 // @Accept json
 // @Produce json
 // @Param cluster query string false "Filter by cluster name"
-// @Success 200 {object} model.Response{data=[]model.Client}
+// @Param status query string false "Filter by connection status: online, stale or offline"
+// @Param as_of query string false "Show the clients as they were at this time (RFC3339)"
+// @Success 200 {object} model.Response{data=[]model.ClientWithStatus}
+// @Failure 400 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /api/clients [get]
 func (h *ClientHandler) ListClients(c *gin.Context) {
 	clusterFilter := c.Query("cluster")
+	statusFilter := c.Query("status")
+	switch statusFilter {
+	case "", model.PresenceOnline, model.PresenceStale, model.PresenceOffline:
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid status",
+			"status must be online, stale or offline",
+		))
+		return
+	}
+
+	routeService, ok := asOfService(c, h.routeService)
+	if !ok {
+		return
+	}
 
 	var clients []model.Client
 	var err error
 
 	if clusterFilter != "" {
-		clients, err = h.routeService.GetClientsByCluster(clusterFilter)
+		clients, err = routeService.GetClientsByCluster(clusterFilter)
 	} else {
-		clients, err = h.routeService.GetAllClients()
+		clients, err = routeService.GetAllClients()
 	}
 
 	if err != nil {
@@ -49,18 +76,85 @@ func (h *ClientHandler) ListClients(c *gin.Context) {
 		return
 	}
 
+	clients = middleware.Visible(c, clients, func(client model.Client) string {
+		return client.Cluster
+	})
+
+	withStatus, err := h.presenceService.WithStatus(clients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get client status",
+			err.Error(),
+		))
+		return
+	}
+
+	if statusFilter != "" {
+		filtered := make([]model.ClientWithStatus, 0, len(withStatus))
+		for _, client := range withStatus {
+			if client.Status.State == statusFilter {
+				filtered = append(filtered, client)
+			}
+		}
+		withStatus = filtered
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(withStatus))
+}
+
+// ListExpiringClients godoc
+// @Summary List expiring clients
+// @Description Get clients that expire within the given window, including already expired ones, soonest first
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param within query string false "Look-ahead window as a Go duration, e.g. 72h (default from config)"
+// @Success 200 {object} model.Response{data=[]model.Client}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/expiring [get]
+func (h *ClientHandler) ListExpiringClients(c *gin.Context) {
+	within := h.upcomingWindow
+	if raw := c.Query("within"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid within",
+				"within must be a non-negative duration such as 72h",
+			))
+			return
+		}
+		within = parsed
+	}
+
+	clients, err := h.routeService.GetExpiringClients(within)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get expiring clients",
+			err.Error(),
+		))
+		return
+	}
+
+	clients = middleware.Visible(c, clients, func(client model.Client) string {
+		return client.Cluster
+	})
+
 	c.JSON(http.StatusOK, model.SuccessResponse(clients))
 }
 
 // GetClient godoc
 // @Summary Get a client
-// @Description Get a specific client by cluster and identity
+// @Description Get a specific client by cluster and identity, with its connection status
 // @Tags clients
 // @Accept json
 // @Produce json
 // @Param cluster path string true "Cluster name"
 // @Param identity path string true "Client identity"
-// @Success 200 {object} model.Response{data=model.Client}
+// @Success 200 {object} model.Response{data=model.ClientWithStatus}
 // @Failure 404 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /api/clients/{cluster}/{identity} [get]
@@ -78,7 +172,70 @@ func (h *ClientHandler) GetClient(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, model.SuccessResponse(client))
+	withStatus, err := h.presenceService.WithStatus([]model.Client{*client})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get client status",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(withStatus[0]))
+}
+
+// Heartbeat godoc
+// @Summary Record a client heartbeat
+// @Description Called periodically by rustun clients or a sidecar to report that a client is connected. The endpoint defaults to the address the heartbeat came from. Requires an API token with the clients:heartbeat scope or the operator role in the client's cluster.
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param request body model.HeartbeatRequest true "Heartbeat"
+// @Success 200 {object} model.Response{data=model.ClientStatus}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/heartbeat [post]
+func (h *ClientHandler) Heartbeat(c *gin.Context) {
+	var req model.HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	// The cluster may be left out of the request, so the caller is
+	// authorized against the cluster of the client it resolves to
+	status, err := h.presenceService.Heartbeat(req, c.ClientIP(), func(client *model.Client) error {
+		if !middleware.AuthorizeHeartbeat(c, client.Cluster) {
+			return errors.New("forbidden")
+		}
+		return nil
+	})
+	if err != nil {
+		if c.IsAborted() {
+			return
+		}
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		} else if err.Error() == "client not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to record heartbeat",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(status))
 }
 
 // CreateClient godoc
@@ -104,18 +261,26 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		return
 	}
 
+	if !middleware.Authorize(c, model.RoleOperator, req.Cluster) {
+		return
+	}
+
 	// Convert request to client model
 	// Identity, PrivateIP, Mask, and Gateway will be auto-generated
 	client := model.Client{
-		Cluster: req.Cluster,
-		Name:    req.Name,
-		Ciders:  req.Ciders,
+		Cluster:   req.Cluster,
+		Name:      req.Name,
+		Ciders:    req.Ciders,
+		ExpiresAt: req.ExpiresAt,
+		Labels:    req.Labels,
 	}
 
-	createdClient, err := h.routeService.CreateClient(client)
+	createdClient, err := h.routeService.WithActor(middleware.Actor(c)).CreateClient(client)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "client already exists" {
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		} else if err.Error() == "client already exists" {
 			statusCode = http.StatusConflict
 		}
 		c.JSON(statusCode, model.ErrorResponseWithCode(
@@ -129,9 +294,58 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 	c.JSON(http.StatusCreated, model.SuccessResponse(createdClient))
 }
 
+// BatchClients godoc
+// @Summary Apply batch client operations
+// @Description Run mixed create/update/delete operations all-or-nothing. If any operation fails the whole batch is rolled back, including IP allocations.
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param request body model.BatchRequest true "Batch operations"
+// @Success 200 {object} model.Response{data=model.BatchResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 422 {object} model.Response{data=model.BatchResult}
+// @Router /api/clients:batch [post]
+func (h *ClientHandler) BatchClients(c *gin.Context) {
+	var req model.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	for _, op := range req.Operations {
+		if !middleware.Authorize(c, model.RoleOperator, op.Cluster) {
+			return
+		}
+	}
+
+	result, err := h.routeService.WithActor(middleware.Actor(c)).ApplyBatch(req.Operations)
+	if err != nil {
+		if result == nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid batch",
+				err.Error(),
+			))
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, model.Response{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "Batch rolled back: " + err.Error(),
+			Data:    result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(result))
+}
+
 // UpdateClient godoc
 // @Summary Update a client
-// @Description Update an existing client configuration
+// @Description Update an existing client configuration. Omitting expires_at keeps the current expiry; "clear_expiry": true removes it
 // @Tags clients
 // @Accept json
 // @Produce json
@@ -148,7 +362,10 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 	identity := c.Param("identity")
 
 	var client model.Client
-	if err := c.ShouldBindJSON(&client); err != nil {
+	var options struct {
+		ClearExpiry bool `json:"clear_expiry"`
+	}
+	if err := c.ShouldBindBodyWith(&client, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
 			http.StatusBadRequest,
 			"Invalid request body",
@@ -156,11 +373,34 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		))
 		return
 	}
+	if err := c.ShouldBindBodyWith(&options, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	if options.ClearExpiry {
+		if client.ExpiresAt != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				"expires_at and clear_expiry cannot be combined",
+			))
+			return
+		}
+		// A zero time tells UpdateClient to remove the expiry
+		client.ExpiresAt = &time.Time{}
+	}
 
-	if err := h.routeService.UpdateClient(cluster, identity, client); err != nil {
+	if err := h.routeService.WithActor(middleware.Actor(c)).UpdateClient(cluster, identity, client); err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "client not found" {
 			statusCode = http.StatusNotFound
+		} else if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
 		}
 		c.JSON(statusCode, model.ErrorResponseWithCode(
 			statusCode,
@@ -170,12 +410,16 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		return
 	}
 
+	// Respond with the stored client, which includes the kept fields
+	if stored, err := h.routeService.GetClient(cluster, identity); err == nil {
+		client = *stored
+	}
 	c.JSON(http.StatusOK, model.SuccessResponse(client))
 }
 
 // DeleteClient godoc
 // @Summary Delete a client
-// @Description Remove a client from the configuration
+// @Description Remove a client from the configuration. It is moved to the trash, keeping its IP, unless the trash is disabled
 // @Tags clients
 // @Accept json
 // @Produce json
@@ -189,7 +433,7 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 	cluster := c.Param("cluster")
 	identity := c.Param("identity")
 
-	if err := h.routeService.DeleteClient(cluster, identity); err != nil {
+	if err := h.routeService.WithActor(middleware.Actor(c)).DeleteClient(cluster, identity); err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "client not found" {
 			statusCode = http.StatusNotFound
@@ -206,3 +450,69 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 		"message": "Client deleted successfully",
 	}))
 }
+
+// EnableClient godoc
+// @Summary Enable a client
+// @Description Publish a disabled client to rustun again with its previous configuration
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Success 200 {object} model.Response{data=model.Client}
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/enable [post]
+func (h *ClientHandler) EnableClient(c *gin.Context) {
+	cluster := c.Param("cluster")
+	identity := c.Param("identity")
+
+	client, err := h.routeService.WithActor(middleware.Actor(c)).EnableClient(cluster, identity)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "client not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to enable client",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(client))
+}
+
+// DisableClient godoc
+// @Summary Disable a client
+// @Description Remove a client from the routes published to rustun while keeping its identity, IP and routes
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Success 200 {object} model.Response{data=model.Client}
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/disable [post]
+func (h *ClientHandler) DisableClient(c *gin.Context) {
+	cluster := c.Param("cluster")
+	identity := c.Param("identity")
+
+	client, err := h.routeService.WithActor(middleware.Actor(c)).DisableClient(cluster, identity)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "client not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to disable client",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(client))
+}
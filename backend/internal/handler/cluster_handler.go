@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 	"github.com/smartethnet/rustun-dashboard/internal/service"
 )
@@ -24,11 +26,18 @@ func NewClusterHandler(routeService *service.RouteService) *ClusterHandler {
 // @Tags clusters
 // @Accept json
 // @Produce json
+// @Param as_of query string false "Show the clusters as they were at this time (RFC3339)"
 // @Success 200 {object} model.Response{data=[]model.Cluster}
+// @Failure 400 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /api/clusters [get]
 func (h *ClusterHandler) ListClusters(c *gin.Context) {
-	clusters, err := h.routeService.GetAllClusters()
+	routeService, ok := asOfService(c, h.routeService)
+	if !ok {
+		return
+	}
+
+	clusters, err := routeService.GetAllClusters()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
 			http.StatusInternalServerError,
@@ -38,6 +47,10 @@ func (h *ClusterHandler) ListClusters(c *gin.Context) {
 		return
 	}
 
+	clusters = middleware.Visible(c, clusters, func(cluster model.Cluster) string {
+		return cluster.Name
+	})
+
 	c.JSON(http.StatusOK, model.SuccessResponse(clusters))
 }
 
@@ -48,14 +61,21 @@ func (h *ClusterHandler) ListClusters(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param name path string true "Cluster name"
+// @Param as_of query string false "Show the cluster as it was at this time (RFC3339)"
 // @Success 200 {object} model.Response{data=object}
+// @Failure 400 {object} model.ErrorResponse
 // @Failure 404 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /api/clusters/{name} [get]
 func (h *ClusterHandler) GetCluster(c *gin.Context) {
 	clusterName := c.Param("name")
 
-	cluster, clients, err := h.routeService.GetCluster(clusterName)
+	routeService, ok := asOfService(c, h.routeService)
+	if !ok {
+		return
+	}
+
+	cluster, clients, err := routeService.GetCluster(clusterName)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.ErrorResponseWithCode(
 			http.StatusNotFound,
@@ -73,7 +93,7 @@ func (h *ClusterHandler) GetCluster(c *gin.Context) {
 
 // DeleteCluster godoc
 // @Summary Delete a cluster
-// @Description Delete a cluster and all its clients
+// @Description Delete a cluster and all its clients. Clients are moved to the trash, keeping their IPs, unless the trash is disabled
 // @Tags clusters
 // @Accept json
 // @Produce json
@@ -85,7 +105,7 @@ func (h *ClusterHandler) GetCluster(c *gin.Context) {
 func (h *ClusterHandler) DeleteCluster(c *gin.Context) {
 	clusterName := c.Param("name")
 
-	err := h.routeService.DeleteCluster(clusterName)
+	err := h.routeService.WithActor(middleware.Actor(c)).DeleteCluster(clusterName)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "cluster not found" {
@@ -103,3 +123,65 @@ func (h *ClusterHandler) DeleteCluster(c *gin.Context) {
 		"message": "Cluster deleted successfully",
 	}))
 }
+
+// ApplyDesiredState godoc
+// @Summary Apply desired cluster state
+// @Description Declaratively set the full client list of a cluster. Clients are matched by identity or name; the computed create/update/delete diff is returned and, unless dry_run is set, applied atomically. Applying an empty list deletes every client and requires allow_empty.
+// @Tags clusters
+// @Accept json
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Param dry_run query bool false "Only compute the diff"
+// @Param allow_empty query bool false "Allow an empty client list"
+// @Param request body model.DesiredStateRequest true "Desired client list"
+// @Success 200 {object} model.Response{data=model.ClusterPlan}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/desired-state [put]
+func (h *ClusterHandler) ApplyDesiredState(c *gin.Context) {
+	clusterName := c.Param("name")
+
+	var req model.DesiredStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	dryRun := req.DryRun || c.Query("dry_run") == "true"
+	allowEmpty := req.AllowEmpty || c.Query("allow_empty") == "true"
+	if len(req.Clients) == 0 && !dryRun && !allowEmpty {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Failed to apply desired state",
+			"an empty client list deletes every client in the cluster; set allow_empty to confirm",
+		))
+		return
+	}
+
+	var plan *model.ClusterPlan
+	var err error
+	if dryRun {
+		plan, err = h.routeService.PlanDesiredState(clusterName, req.Clients)
+	} else {
+		plan, err = h.routeService.WithActor(middleware.Actor(c)).ApplyDesiredState(clusterName, req.Clients)
+	}
+
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to apply desired state",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(plan))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type DeployHandler struct {
+	deployer *service.Deployer
+}
+
+func NewDeployHandler(deployer *service.Deployer) *DeployHandler {
+	return &DeployHandler{
+		deployer: deployer,
+	}
+}
+
+// ListTargets godoc
+// @Summary List deployment targets
+// @Description List the deployment targets the routes are published to, with their delivery status
+// @Tags deploy
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.DeployTargetStatus}
+// @Router /api/deploy/targets [get]
+func (h *DeployHandler) ListTargets(c *gin.Context) {
+	c.JSON(http.StatusOK, model.SuccessResponse(h.deployer.Status()))
+}
+
+// Sync godoc
+// @Summary Re-sync deployment targets
+// @Description Deliver the current routes to one or all deployment targets right away, even if they are unchanged, and return the resulting status
+// @Tags deploy
+// @Produce json
+// @Param target query string false "Only re-sync this target"
+// @Success 200 {object} model.Response{data=[]model.DeployTargetStatus}
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/deploy/sync [post]
+func (h *DeployHandler) Sync(c *gin.Context) {
+	statuses, err := h.deployer.Sync(middleware.Actor(c).Name, c.Query("target"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "deploy target not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to re-sync deployment targets",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(statuses))
+}
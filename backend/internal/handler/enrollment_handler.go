@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/clientconf"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type EnrollmentHandler struct {
+	enrollmentService *service.EnrollmentService
+	qrDefaults        clientconf.QROptions
+}
+
+func NewEnrollmentHandler(enrollmentService *service.EnrollmentService, qrDefaults clientconf.QROptions) *EnrollmentHandler {
+	return &EnrollmentHandler{
+		enrollmentService: enrollmentService,
+		qrDefaults:        qrDefaults,
+	}
+}
+
+// ListTokens godoc
+// @Summary List enrollment tokens
+// @Description List enrollment tokens, newest first, without the token values
+// @Tags enrollment
+// @Produce json
+// @Param cluster query string false "Only tokens of this cluster"
+// @Success 200 {object} model.Response{data=[]model.EnrollmentToken}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/enrollment-tokens [get]
+func (h *EnrollmentHandler) ListTokens(c *gin.Context) {
+	tokens, err := h.enrollmentService.ListTokens(c.Query("cluster"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to list enrollment tokens",
+			err.Error(),
+		))
+		return
+	}
+
+	tokens = middleware.Visible(c, tokens, func(token model.EnrollmentToken) string {
+		return token.Cluster
+	})
+
+	c.JSON(http.StatusOK, model.SuccessResponse(tokens))
+}
+
+// CreateToken godoc
+// @Summary Create an enrollment token
+// @Description Issue a single-use, expiring token that lets a new client register itself in a cluster. The token is only returned in this response, optionally as a QR code of its enrollment URL (or of the token if no URL is configured).
+// @Tags enrollment
+// @Accept json
+// @Produce json
+// @Produce image/png
+// @Produce image/svg+xml
+// @Param request body model.EnrollmentTokenRequest true "Token scope and presets"
+// @Param qr query string false "png or svg to respond with a QR code; the token ID is sent in X-Enrollment-Token-Id"
+// @Param size query int false "QR code width and height in pixels (64-2048)"
+// @Param level query string false "QR code error correction level: L, M, Q or H"
+// @Success 201 {object} model.Response{data=model.EnrollmentTokenCreated}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/enrollment-tokens [post]
+func (h *EnrollmentHandler) CreateToken(c *gin.Context) {
+	var encode func(string, clientconf.QROptions) ([]byte, error)
+	var contentType string
+	switch c.Query("qr") {
+	case "":
+	case "png":
+		encode, contentType = clientconf.QRPNG, "image/png"
+	case "svg":
+		encode, contentType = clientconf.QRSVG, "image/svg+xml"
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid qr",
+			"qr must be png or svg",
+		))
+		return
+	}
+	opts, ok := qrOptions(c, h.qrDefaults)
+	if !ok {
+		return
+	}
+
+	var req model.EnrollmentTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	if !middleware.Authorize(c, model.RoleOperator, req.Cluster) {
+		return
+	}
+
+	token, err := h.enrollmentService.CreateToken(middleware.Actor(c).Name, req)
+	if err != nil {
+		enrollmentError(c, "Failed to create enrollment token", err)
+		return
+	}
+
+	// The token cannot be retrieved again, so keep it out of caches
+	c.Header("Cache-Control", "no-store")
+	if encode == nil {
+		c.JSON(http.StatusCreated, model.SuccessResponse(token))
+		return
+	}
+
+	text := token.EnrollURL
+	if text == "" {
+		text = token.Token
+	}
+	image, err := encode(text, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to render QR code",
+			err.Error(),
+		))
+		return
+	}
+	c.Header("X-Enrollment-Token-Id", strconv.FormatUint(uint64(token.ID), 10))
+	c.Data(http.StatusCreated, contentType, image)
+}
+
+// RevokeToken godoc
+// @Summary Revoke an enrollment token
+// @Description Make an unused enrollment token unusable
+// @Tags enrollment
+// @Produce json
+// @Param id path int true "Token ID"
+// @Success 200 {object} model.Response{data=model.EnrollmentToken}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/enrollment-tokens/{id} [delete]
+func (h *EnrollmentHandler) RevokeToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid token ID",
+			"token ID must be a positive integer",
+		))
+		return
+	}
+
+	existing, err := h.enrollmentService.GetToken(uint(id))
+	if err != nil {
+		enrollmentError(c, "Failed to revoke enrollment token", err)
+		return
+	}
+	if !middleware.Authorize(c, model.RoleOperator, existing.Cluster) {
+		return
+	}
+
+	token, err := h.enrollmentService.RevokeToken(middleware.Actor(c).Name, uint(id))
+	if err != nil {
+		enrollmentError(c, "Failed to revoke enrollment token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(token))
+}
+
+// Enroll godoc
+// @Summary Enroll a client
+// @Description Trade an enrollment token for a new client with a generated identity and IP, and its configuration. No authentication besides the token is required.
+// @Tags enrollment
+// @Accept json
+// @Produce json
+// @Param request body model.EnrollRequest false "Enrollment token and client name"
+// @Param token query string false "Enrollment token, if not in the body"
+// @Success 201 {object} model.Response{data=model.EnrollResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/enroll [post]
+func (h *EnrollmentHandler) Enroll(c *gin.Context) {
+	var req model.EnrollRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+	}
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+	if req.Token == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request",
+			"token is required",
+		))
+		return
+	}
+
+	result, err := h.enrollmentService.Enroll(req, c.ClientIP())
+	if err != nil {
+		enrollmentError(c, "Enrollment failed", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, model.SuccessResponse(result))
+}
+
+// EnrollPage godoc
+// @Summary Enrollment page
+// @Description Page opened from an enrollment URL, e.g. by scanning its QR code. It asks for a client name and enrolls with a POST to /api/enroll; opening it does not use the token.
+// @Tags enrollment
+// @Produce html
+// @Param token query string true "Enrollment token"
+// @Success 200 {string} string "HTML page"
+// @Router /api/enroll [get]
+func (h *EnrollmentHandler) EnrollPage(c *gin.Context) {
+	// The page carries the token, so keep it out of caches and referrers
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := enrollPage.Execute(c.Writer, gin.H{"Token": c.Query("token")}); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+var enrollPage = template.Must(template.New("enroll").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Enroll rustun client</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+input, button { font-size: 1em; padding: .4em; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Enroll rustun client</h1>
+<form id="enroll">
+<input type="hidden" name="token" value="{{.Token}}">
+<p><label>Client name <input name="name" maxlength="64" autocomplete="off"></label></p>
+<p><button type="submit">Enroll</button></p>
+</form>
+<p id="status"></p>
+<pre id="result" hidden></pre>
+<script>
+document.getElementById('enroll').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const status = document.getElementById('status');
+  const result = document.getElementById('result');
+  form.querySelector('button').disabled = true;
+  status.className = '';
+  status.textContent = 'Enrolling...';
+  try {
+    const resp = await fetch(window.location.pathname, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token: form.token.value, name: form.name.value }),
+    });
+    const body = await resp.json();
+    if (!resp.ok) {
+      throw new Error(body.error || body.message || resp.statusText);
+    }
+    const data = body.data;
+    status.textContent = 'Enrolled as ' + data.client.identity + ' with IP ' + data.client.private_ip + '. Save this configuration, it is not shown again.';
+    result.textContent = JSON.stringify(data.config || data.client, null, 2);
+    result.hidden = false;
+    form.hidden = true;
+  } catch (err) {
+    status.className = 'error';
+    status.textContent = 'Enrollment failed: ' + err.message;
+    form.querySelector('button').disabled = false;
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+func enrollmentError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrInvalidEnrollment) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "enrollment token not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/logwatch"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+// streamKeepalive is how often an idle event stream gets a comment line so
+// proxies keep the connection open
+const streamKeepalive = 30 * time.Second
+
+type EventHandler struct {
+	eventService *service.EventService
+}
+
+func NewEventHandler(eventService *service.EventService) *EventHandler {
+	return &EventHandler{
+		eventService: eventService,
+	}
+}
+
+// ListEvents godoc
+// @Summary List server events
+// @Description Get connection, disconnection, route reload and error events derived from the rustun server log, newest first
+// @Tags events
+// @Produce json
+// @Param cluster query string false "Filter by cluster name"
+// @Param identity query string false "Filter by client identity"
+// @Param type query string false "Filter by type: connect, disconnect, reload or error"
+// @Param since query string false "Only events at or after this time (RFC3339)"
+// @Param until query string false "Only events before this time (RFC3339)"
+// @Param limit query int false "Maximum number of events (default 100)"
+// @Success 200 {object} model.Response{data=[]model.Event}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/events [get]
+func (h *EventHandler) ListEvents(c *gin.Context) {
+	filter, ok := eventFilter(c)
+	if !ok {
+		return
+	}
+
+	filter.Limit = 100
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid limit",
+				"limit must be a non-negative integer",
+			))
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	events, err := h.eventService.List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get events",
+			err.Error(),
+		))
+		return
+	}
+
+	events = middleware.Visible(c, events, func(event model.Event) string {
+		return event.Cluster
+	})
+
+	c.JSON(http.StatusOK, model.SuccessResponse(events))
+}
+
+// StreamEvents godoc
+// @Summary Stream server events
+// @Description Server-sent events feed of new events as they are read from the rustun server log. Each message carries the event type as its event name and the event as JSON data.
+// @Tags events
+// @Produce text/event-stream
+// @Param cluster query string false "Filter by cluster name"
+// @Param identity query string false "Filter by client identity"
+// @Param type query string false "Filter by type: connect, disconnect, reload or error"
+// @Success 200 {object} model.Event
+// @Failure 400 {object} model.ErrorResponse
+// @Router /api/events/stream [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	filter, ok := eventFilter(c)
+	if !ok {
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Streaming not supported",
+			"",
+		))
+		return
+	}
+
+	access := middleware.Authorizer(c)
+	events, unsubscribe := h.eventService.Subscribe()
+	defer unsubscribe()
+
+	// Set headers for SSE
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if !filter.Matches(event) || !access.Allows(model.RoleViewer, event.Cluster) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+			flusher.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// eventFilter reads the filter query parameters shared by the event
+// endpoints
+func eventFilter(c *gin.Context) (model.EventFilter, bool) {
+	filter := model.EventFilter{
+		Cluster:  c.Query("cluster"),
+		Identity: c.Query("identity"),
+		Type:     c.Query("type"),
+	}
+
+	switch filter.Type {
+	case "", logwatch.TypeConnect, logwatch.TypeDisconnect, logwatch.TypeReload, logwatch.TypeError:
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid type",
+			"type must be connect, disconnect, reload or error",
+		))
+		return filter, false
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid "+param.name,
+				param.name+" must be an RFC3339 timestamp",
+			))
+			return filter, false
+		}
+		*param.target = &parsed
+	}
+
+	return filter, true
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/exchange"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type ExchangeHandler struct {
+	routeService *service.RouteService
+}
+
+func NewExchangeHandler(routeService *service.RouteService) *ExchangeHandler {
+	return &ExchangeHandler{
+		routeService: routeService,
+	}
+}
+
+// Export godoc
+// @Summary Export clients
+// @Description Download clients as JSON, CSV, YAML or routes.json, optionally limited to one cluster or as of a past time
+// @Tags exchange
+// @Produce json
+// @Produce text/csv
+// @Produce application/yaml
+// @Param format query string false "json (default), csv, yaml or routes (the routes.json document rustun reads)"
+// @Param cluster query string false "Only export this cluster"
+// @Param as_of query string false "Export the configuration as it was at this time (RFC3339)"
+// @Success 200 {file} file
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/export [get]
+func (h *ExchangeHandler) Export(c *gin.Context) {
+	format, err := exchange.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid format",
+			err.Error(),
+		))
+		return
+	}
+
+	routeService, ok := asOfService(c, h.routeService)
+	if !ok {
+		return
+	}
+
+	cluster := c.Query("cluster")
+	clients, err := routeService.ExportClients(cluster)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to export clients",
+			err.Error(),
+		))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exchange.Encode(&buf, format, clients); err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to encode clients",
+			err.Error(),
+		))
+		return
+	}
+
+	filename := "rustun-clients"
+	if cluster != "" {
+		filename += "-" + cluster
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exchange.Filename(filename, format)))
+	c.Data(http.StatusOK, exchange.ContentType(format), buf.Bytes())
+}
+
+// Import godoc
+// @Summary Import clients
+// @Description Import clients from JSON, CSV or YAML, sent as the request body or as a multipart "file" field. All rows are validated first; if any row is invalid nothing is imported. Rows without a private_ip get one allocated.
+// @Tags exchange
+// @Accept json
+// @Accept text/csv
+// @Accept application/yaml
+// @Accept multipart/form-data
+// @Produce json
+// @Param format query string false "json, csv or yaml (detected from the file name or Content-Type if omitted)"
+// @Param conflict query string false "What to do with existing clients: skip, overwrite or fail (default)"
+// @Param cluster query string false "Only import this cluster; rows without a cluster are assigned to it"
+// @Param dry_run query bool false "Only preview the changes"
+// @Success 200 {object} model.Response{data=model.ImportResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 422 {object} model.Response{data=model.ImportResult}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/import [post]
+func (h *ExchangeHandler) Import(c *gin.Context) {
+	body, filename, err := readImportBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+	defer body.Close()
+
+	format, err := exchange.ParseFormat(detectFormat(c, filename))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid format",
+			err.Error(),
+		))
+		return
+	}
+
+	records, err := exchange.Decode(body, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Failed to parse import",
+			err.Error(),
+		))
+		return
+	}
+
+	result, err := h.routeService.WithActor(middleware.Actor(c)).ImportClients(records, model.ImportOptions{
+		Policy:  c.Query("conflict"),
+		Cluster: c.Query("cluster"),
+		DryRun:  c.Query("dry_run") == "true",
+	})
+	if err != nil {
+		if result != nil && errors.Is(err, service.ErrValidation) {
+			c.JSON(http.StatusUnprocessableEntity, model.Response{
+				Code:    http.StatusUnprocessableEntity,
+				Message: "Import rejected: " + err.Error(),
+				Data:    result,
+			})
+			return
+		}
+
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to import clients",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(result))
+}
+
+// readImportBody returns the uploaded file of a multipart request, or the raw
+// request body otherwise, along with the uploaded file name if there is one
+func readImportBody(c *gin.Context) (io.ReadCloser, string, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, "", nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", fmt.Errorf("missing file field: %w", err)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return file, header.Filename, nil
+}
+
+// detectFormat picks the import format from the format query parameter, the
+// uploaded file extension or the Content-Type, in that order
+func detectFormat(c *gin.Context, filename string) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(filename), "."); ext != "" {
+		return ext
+	}
+
+	contentType := c.ContentType()
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return exchange.FormatCSV
+	case strings.Contains(contentType, "yaml"):
+		return exchange.FormatYAML
+	default:
+		return exchange.FormatJSON
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type GitHandler struct {
+	routeService *service.RouteService
+}
+
+func NewGitHandler(routeService *service.RouteService) *GitHandler {
+	return &GitHandler{
+		routeService: routeService,
+	}
+}
+
+// GetLog godoc
+// @Summary Get git log
+// @Description Get the commits of the git-backed routes configuration, newest first
+// @Tags git
+// @Produce json
+// @Param limit query int false "Maximum number of commits (default 50)"
+// @Success 200 {object} model.Response{data=[]model.GitCommit}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/git/log [get]
+func (h *GitHandler) GetLog(c *gin.Context) {
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid limit",
+				"limit must be a non-negative integer",
+			))
+			return
+		}
+		limit = parsed
+	}
+
+	commits, err := h.routeService.GitLog(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get git log",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(commits))
+}
+
+// GetStatus godoc
+// @Summary Get git status
+// @Description Get the current commit, remote and the outcome of the last push
+// @Tags git
+// @Produce json
+// @Success 200 {object} model.Response{data=model.GitStatus}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/git/status [get]
+func (h *GitHandler) GetStatus(c *gin.Context) {
+	status, err := h.routeService.GitStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get git status",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(status))
+}
+
+// Revert godoc
+// @Summary Revert a commit
+// @Description Undo a commit of the routes configuration with a new commit authored by the current user
+// @Tags git
+// @Accept json
+// @Produce json
+// @Param request body model.GitRevertRequest true "Commit to revert"
+// @Success 200 {object} model.Response{data=model.GitRevertResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/git/revert [post]
+func (h *GitHandler) Revert(c *gin.Context) {
+	var req model.GitRevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	result, err := h.routeService.WithActor(middleware.Actor(c)).RevertGitCommit(req.Commit)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to revert commit",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(result))
+}
+
+// Push godoc
+// @Summary Push to the git remote
+// @Description Push the routes configuration to the configured remote
+// @Tags git
+// @Produce json
+// @Success 200 {object} model.Response{data=model.GitStatus}
+// @Failure 502 {object} model.ErrorResponse
+// @Router /api/git/push [post]
+func (h *GitHandler) Push(c *gin.Context) {
+	if err := h.routeService.GitPush(); err != nil {
+		c.JSON(http.StatusBadGateway, model.ErrorResponseWithCode(
+			http.StatusBadGateway,
+			"Failed to push",
+			err.Error(),
+		))
+		return
+	}
+
+	status, _ := h.routeService.GitStatus()
+	c.JSON(http.StatusOK, model.SuccessResponse(status))
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type HistoryHandler struct {
+	routeService *service.RouteService
+}
+
+func NewHistoryHandler(routeService *service.RouteService) *HistoryHandler {
+	return &HistoryHandler{
+		routeService: routeService,
+	}
+}
+
+// GetClientHistory godoc
+// @Summary Get client history
+// @Description Get the revisions of a client, newest first, with before/after snapshots and field-level changes
+// @Tags history
+// @Produce json
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param limit query int false "Maximum number of revisions (default 50)"
+// @Success 200 {object} model.Response{data=[]model.Revision}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/history [get]
+func (h *HistoryHandler) GetClientHistory(c *gin.Context) {
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid limit",
+				"limit must be a non-negative integer",
+			))
+			return
+		}
+		limit = parsed
+	}
+
+	revisions, err := h.routeService.GetClientHistory(c.Param("cluster"), c.Param("identity"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to get client history",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(revisions))
+}
+
+// RollbackClient godoc
+// @Summary Roll back a client
+// @Description Restore a client to the state recorded right after the given revision. Deleted clients are recreated.
+// @Tags history
+// @Accept json
+// @Produce json
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param request body model.RollbackRequest true "Revision to restore"
+// @Success 200 {object} model.Response{data=model.Client}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/rollback [post]
+func (h *HistoryHandler) RollbackClient(c *gin.Context) {
+	var req model.RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	client, err := h.routeService.WithActor(middleware.Actor(c)).RollbackClient(c.Param("cluster"), c.Param("identity"), req.Revision)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		} else if err.Error() == "revision not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to roll back client",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(client))
+}
+
+// DiffTimes godoc
+// @Summary Diff two points in time
+// @Description List the client changes between two timestamps, e.g. the start and end of an incident
+// @Tags history
+// @Produce json
+// @Param from query string true "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339, default now)"
+// @Success 200 {object} model.Response{data=model.TimeDiff}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/history/diff [get]
+func (h *HistoryHandler) DiffTimes(c *gin.Context) {
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid from",
+			"from must be an RFC3339 timestamp",
+		))
+		return
+	}
+
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid to",
+				"to must be an RFC3339 timestamp",
+			))
+			return
+		}
+	}
+
+	diff, err := h.routeService.DiffTimes(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to diff",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(diff))
+}
+
+// asOfService returns the service to read from: routeService itself, or a
+// view of the past if the request has an as_of parameter. On failure it
+// writes the error response and returns false.
+func asOfService(c *gin.Context, routeService *service.RouteService) (*service.RouteService, bool) {
+	raw := c.Query("as_of")
+	if raw == "" {
+		return routeService, true
+	}
+
+	asOf, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid as_of",
+			"as_of must be an RFC3339 timestamp",
+		))
+		return nil, false
+	}
+
+	view, err := routeService.AsOf(asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to reconstruct configuration",
+			err.Error(),
+		))
+		return nil, false
+	}
+	return view, true
+}
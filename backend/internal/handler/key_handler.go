@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type KeyHandler struct {
+	keyService *service.KeyService
+}
+
+func NewKeyHandler(keyService *service.KeyService) *KeyHandler {
+	return &KeyHandler{
+		keyService: keyService,
+	}
+}
+
+// ListKeys godoc
+// @Summary List cluster keys
+// @Description List the crypto keys of a cluster, newest first, without the secrets
+// @Tags keys
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Success 200 {object} model.Response{data=[]model.ClusterKey}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/keys [get]
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.keyService.ListKeys(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to list keys",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(keys))
+}
+
+// GenerateKey godoc
+// @Summary Generate a cluster key
+// @Description Generate a random key for a cluster. The first key is active right away; later keys are pending until activated.
+// @Tags keys
+// @Accept json
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Param request body model.KeyGenerateRequest false "Cipher"
+// @Success 201 {object} model.Response{data=model.ClusterKey}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/keys [post]
+func (h *KeyHandler) GenerateKey(c *gin.Context) {
+	var req model.KeyGenerateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	key, err := h.keyService.GenerateKey(middleware.Actor(c).Name, c.Param("name"), req.Cipher)
+	if err != nil {
+		keyError(c, "Failed to generate key", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.SuccessResponse(key))
+}
+
+// ActivateKey godoc
+// @Summary Activate the pending cluster key
+// @Description Make the pending key of a cluster active and retire the previously active key. The key is written to the rustun server config unless keys.server_config is off, in which case server_updated must confirm that the server already uses it.
+// @Tags keys
+// @Accept json
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Param request body model.KeyActivateRequest false "Confirmation"
+// @Success 200 {object} model.Response{data=model.ClusterKey}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/keys/activate [post]
+func (h *KeyHandler) ActivateKey(c *gin.Context) {
+	var req model.KeyActivateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	key, err := h.keyService.ActivateKey(middleware.Actor(c).Name, c.Param("name"), req.ServerUpdated)
+	if err != nil {
+		keyError(c, "Failed to activate key", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(key))
+}
+
+// DiscardPendingKey godoc
+// @Summary Discard the pending cluster key
+// @Description Retire the pending key of a cluster without activating it
+// @Tags keys
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Success 200 {object} model.Response{data=model.ClusterKey}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/keys/pending [delete]
+func (h *KeyHandler) DiscardPendingKey(c *gin.Context) {
+	key, err := h.keyService.DiscardPendingKey(middleware.Actor(c).Name, c.Param("name"))
+	if err != nil {
+		keyError(c, "Failed to discard key", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(key))
+}
+
+// RevealKey godoc
+// @Summary Reveal a cluster key
+// @Description Decrypt a key of a cluster, e.g. to configure the rustun server. Every reveal is recorded in the audit log.
+// @Tags keys
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Param id path int true "Key ID"
+// @Success 200 {object} model.Response{data=model.KeySecret}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/keys/{id}/secret [get]
+func (h *KeyHandler) RevealKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid key ID",
+			"key ID must be a positive integer",
+		))
+		return
+	}
+
+	key, err := h.keyService.RevealKey(middleware.Actor(c).Name, c.Param("name"), uint(id))
+	if err != nil {
+		keyError(c, "Failed to reveal key", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, model.SuccessResponse(key))
+}
+
+func keyError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "key not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
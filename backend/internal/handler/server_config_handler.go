@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type ServerConfigHandler struct {
+	serverConfigService *service.ServerConfigService
+}
+
+func NewServerConfigHandler(serverConfigService *service.ServerConfigService) *ServerConfigHandler {
+	return &ServerConfigHandler{
+		serverConfigService: serverConfigService,
+	}
+}
+
+// GetServerConfig godoc
+// @Summary Get the rustun server configuration
+// @Description Get the listen address, crypto mode and routes file of the rustun server. The crypto key is masked.
+// @Tags server
+// @Produce json
+// @Success 200 {object} model.Response{data=model.ServerConfig}
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/server/config [get]
+func (h *ServerConfigHandler) GetServerConfig(c *gin.Context) {
+	cfg, err := h.serverConfigService.GetConfig()
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "server config not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to get server config",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(cfg))
+}
+
+// UpdateServerConfig godoc
+// @Summary Update the rustun server configuration
+// @Description Validate and atomically rewrite the rustun server config file. xor and plain crypto are refused outside debug mode. Omit crypto_key or send the masked value to keep the current key.
+// @Tags server
+// @Accept json
+// @Produce json
+// @Param config body model.ServerConfig true "Server configuration"
+// @Success 200 {object} model.Response{data=model.ServerConfig}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/server/config [put]
+func (h *ServerConfigHandler) UpdateServerConfig(c *gin.Context) {
+	var cfg model.ServerConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	updated, err := h.serverConfigService.UpdateConfig(middleware.Actor(c).Name, cfg)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrValidation) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to update server config",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(updated))
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type SessionHandler struct {
+	sessionService *service.SessionService
+	userService    *service.UserService
+}
+
+func NewSessionHandler(sessionService *service.SessionService, userService *service.UserService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		userService:    userService,
+	}
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Check a username and password and start a session. The access token authenticates requests as "Authorization: Bearer" until it expires; the refresh token then trades for new tokens. If must_change_password is set, the account can only change its password until it does.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body model.LoginRequest true "Credentials"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Router /api/auth/login [post]
+func (h *SessionHandler) Login(c *gin.Context) {
+	var req model.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	login, err := h.sessionService.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		sessionError(c, "Login failed", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, model.SuccessResponse(login))
+}
+
+// Refresh godoc
+// @Summary Refresh a session
+// @Description Trade a refresh token for a new access token and refresh token and extend the session. Each refresh token can only be used once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body model.RefreshRequest true "Refresh token"
+// @Success 200 {object} model.Response{data=model.LoginResponse}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Router /api/auth/refresh [post]
+func (h *SessionHandler) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	login, err := h.sessionService.Refresh(req.RefreshToken)
+	if err != nil {
+		sessionError(c, "Refresh failed", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, model.SuccessResponse(login))
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description End the session the request is authenticated with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/logout [post]
+func (h *SessionHandler) Logout(c *gin.Context) {
+	session := middleware.Session(c)
+	if session == nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Not logged in",
+			"the request is not authenticated with a session",
+		))
+		return
+	}
+
+	if err := h.sessionService.Logout(middleware.Actor(c).Name, session); err != nil {
+		sessionError(c, "Failed to log out", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(gin.H{
+		"message": "Logged out successfully",
+	}))
+}
+
+// ListOwnSessions godoc
+// @Summary List own sessions
+// @Description List the active sessions of the authenticated user, newest first. The session of the request is marked as current.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.Session}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/sessions [get]
+func (h *SessionHandler) ListOwnSessions(c *gin.Context) {
+	h.listSessions(c, middleware.Account(c))
+}
+
+// RevokeOwnSession godoc
+// @Summary Revoke an own session
+// @Description End a session of the authenticated user, e.g. on a lost device
+// @Tags auth
+// @Produce json
+// @Param session_id path int true "Session ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeOwnSession(c *gin.Context) {
+	h.revokeSession(c, middleware.Account(c))
+}
+
+// ListUserSessions godoc
+// @Summary List sessions of a user
+// @Description List the active sessions of a dashboard account, newest first
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} model.Response{data=[]model.Session}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id}/sessions [get]
+func (h *SessionHandler) ListUserSessions(c *gin.Context) {
+	if user, ok := pathUser(c, h.userService); ok {
+		h.listSessions(c, user)
+	}
+}
+
+// RevokeUserSession godoc
+// @Summary Revoke a session of a user
+// @Description End a session of a dashboard account
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Param session_id path int true "Session ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id}/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	if user, ok := pathUser(c, h.userService); ok {
+		h.revokeSession(c, user)
+	}
+}
+
+func (h *SessionHandler) listSessions(c *gin.Context, user *model.User) {
+	var current uint
+	if session := middleware.Session(c); session != nil {
+		current = session.ID
+	}
+
+	sessions, err := h.sessionService.ListSessions(user.ID, current)
+	if err != nil {
+		sessionError(c, "Failed to list sessions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(sessions))
+}
+
+func (h *SessionHandler) revokeSession(c *gin.Context, user *model.User) {
+	id, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid session ID",
+			"session ID must be a positive integer",
+		))
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(middleware.Actor(c).Name, user, uint(id)); err != nil {
+		sessionError(c, "Failed to revoke session", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(gin.H{
+		"message": "Session revoked successfully",
+	}))
+}
+
+func sessionError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrInvalidSession) {
+		statusCode = http.StatusUnauthorized
+	} else if err.Error() == "session not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
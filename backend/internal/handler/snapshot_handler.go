@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type SnapshotHandler struct {
+	routeService *service.RouteService
+}
+
+func NewSnapshotHandler(routeService *service.RouteService) *SnapshotHandler {
+	return &SnapshotHandler{
+		routeService: routeService,
+	}
+}
+
+// ListSnapshots godoc
+// @Summary List snapshots
+// @Description Get all configuration snapshots without their clients, newest first
+// @Tags snapshots
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.Snapshot}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/snapshots [get]
+func (h *SnapshotHandler) ListSnapshots(c *gin.Context) {
+	snapshots, err := h.routeService.ListSnapshots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to list snapshots",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(snapshots))
+}
+
+// CreateSnapshot godoc
+// @Summary Create a snapshot
+// @Description Save a copy of the clients of all clusters
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Param request body model.SnapshotCreateRequest false "Snapshot name and description"
+// @Success 201 {object} model.Response{data=model.Snapshot}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/snapshots [post]
+func (h *SnapshotHandler) CreateSnapshot(c *gin.Context) {
+	var req model.SnapshotCreateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	snapshot, err := h.routeService.WithActor(middleware.Actor(c)).CreateSnapshot(req.Name, req.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to create snapshot",
+			err.Error(),
+		))
+		return
+	}
+
+	snapshot.Clients = nil
+	c.JSON(http.StatusCreated, model.SuccessResponse(snapshot))
+}
+
+// GetSnapshot godoc
+// @Summary Get a snapshot
+// @Description Get a snapshot including its clients
+// @Tags snapshots
+// @Produce json
+// @Param id path int true "Snapshot ID"
+// @Success 200 {object} model.Response{data=model.Snapshot}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /api/snapshots/{id} [get]
+func (h *SnapshotHandler) GetSnapshot(c *gin.Context) {
+	id, ok := snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	snapshot, err := h.routeService.GetSnapshot(id)
+	if err != nil {
+		snapshotError(c, "Failed to get snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(snapshot))
+}
+
+// DeleteSnapshot godoc
+// @Summary Delete a snapshot
+// @Tags snapshots
+// @Produce json
+// @Param id path int true "Snapshot ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /api/snapshots/{id} [delete]
+func (h *SnapshotHandler) DeleteSnapshot(c *gin.Context) {
+	id, ok := snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := h.routeService.DeleteSnapshot(id); err != nil {
+		snapshotError(c, "Failed to delete snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(gin.H{
+		"message": "Snapshot deleted successfully",
+	}))
+}
+
+// DiffSnapshot godoc
+// @Summary Diff a snapshot
+// @Description Compare a snapshot with the current configuration or with another snapshot
+// @Tags snapshots
+// @Produce json
+// @Param id path int true "Snapshot ID"
+// @Param against query string false "Snapshot ID to compare with (default: current configuration)"
+// @Success 200 {object} model.Response{data=model.SnapshotDiff}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /api/snapshots/{id}/diff [get]
+func (h *SnapshotHandler) DiffSnapshot(c *gin.Context) {
+	id, ok := snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var against uint
+	if raw := c.Query("against"); raw != "" && raw != "current" {
+		if against, ok = snapshotID(c, raw); !ok {
+			return
+		}
+	}
+
+	diff, err := h.routeService.DiffSnapshot(id, against)
+	if err != nil {
+		snapshotError(c, "Failed to diff snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(diff))
+}
+
+// RestoreSnapshot godoc
+// @Summary Restore a snapshot
+// @Description Replace the configuration of all clusters with the snapshot and re-seed IP allocation. Fails if a restored client would take the address of a client in the trash. The current configuration is snapshotted first.
+// @Tags snapshots
+// @Produce json
+// @Param id path int true "Snapshot ID"
+// @Success 200 {object} model.Response{data=model.SnapshotRestoreResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/snapshots/{id}/restore [post]
+func (h *SnapshotHandler) RestoreSnapshot(c *gin.Context) {
+	id, ok := snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	result, err := h.routeService.WithActor(middleware.Actor(c)).RestoreSnapshot(id)
+	if err != nil {
+		snapshotError(c, "Failed to restore snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(result))
+}
+
+// snapshotID parses a snapshot ID, writing a 400 response if it is invalid
+func snapshotID(c *gin.Context, raw string) (uint, bool) {
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid snapshot ID",
+			"snapshot ID must be a positive integer",
+		))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func snapshotError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if err.Error() == "snapshot not found" {
+		statusCode = http.StatusNotFound
+	} else if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+	"github.com/smartethnet/rustun-dashboard/internal/topology"
+)
+
+type TopologyHandler struct {
+	topologyService *service.TopologyService
+}
+
+func NewTopologyHandler(topologyService *service.TopologyService) *TopologyHandler {
+	return &TopologyHandler{
+		topologyService: topologyService,
+	}
+}
+
+// GetTopology godoc
+// @Summary Get a cluster topology
+// @Description Get the network graph of a cluster: the gateway, clients and advertised LAN subnets as nodes, tunnels and routes as edges. Clients carry their connection status, edges their reachability, and overlapping routes are listed.
+// @Tags clusters
+// @Produce json
+// @Produce text/vnd.graphviz
+// @Produce plain
+// @Param name path string true "Cluster name"
+// @Param format query string false "json (default), dot or mermaid"
+// @Success 200 {object} model.Response{data=model.Topology}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/topology [get]
+func (h *TopologyHandler) GetTopology(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	switch format {
+	case "json", "dot", "mermaid":
+	default:
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid format",
+			"format must be json, dot or mermaid",
+		))
+		return
+	}
+
+	topo, err := h.topologyService.GetTopology(c.Param("name"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "cluster not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, model.ErrorResponseWithCode(
+			statusCode,
+			"Failed to get topology",
+			err.Error(),
+		))
+		return
+	}
+
+	switch format {
+	case "dot":
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(topology.DOT(topo)))
+	case "mermaid":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(topology.Mermaid(topo)))
+	default:
+		c.JSON(http.StatusOK, model.SuccessResponse(topo))
+	}
+}
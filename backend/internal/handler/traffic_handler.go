@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+	"github.com/smartethnet/rustun-dashboard/internal/trafficstats"
+)
+
+// maxTrafficBody caps the size of an ingested batch
+const maxTrafficBody = 8 << 20
+
+type TrafficHandler struct {
+	trafficService *service.TrafficService
+}
+
+func NewTrafficHandler(trafficService *service.TrafficService) *TrafficHandler {
+	return &TrafficHandler{
+		trafficService: trafficService,
+	}
+}
+
+// Ingest godoc
+// @Summary Ingest traffic counters
+// @Description Record cumulative rx/tx byte counters per client, as JSON or as rustun stats output (text/plain). Each reading is accepted or rejected on its own.
+// @Tags traffic
+// @Accept json
+// @Accept plain
+// @Produce json
+// @Param request body model.TrafficIngestRequest true "Counter readings"
+// @Param cluster query string false "Cluster of the readings; readings of other clusters are rejected"
+// @Success 200 {object} model.Response{data=model.TrafficIngestResult}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/traffic [post]
+func (h *TrafficHandler) Ingest(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTrafficBody)
+
+	var reports []model.TrafficReport
+	if strings.HasPrefix(c.ContentType(), "text/") {
+		parsed, err := trafficstats.Parse(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid stats output",
+				err.Error(),
+			))
+			return
+		}
+		reports = parsed
+	} else {
+		var req model.TrafficIngestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+		reports = req.Reports
+	}
+
+	result, err := h.trafficService.Ingest(reports, c.Query("cluster"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to record traffic",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(result))
+}
+
+// GetClientTraffic godoc
+// @Summary Get client traffic
+// @Description Get the traffic of a client over time. Series are built from raw, 5-minute or hourly samples depending on the step.
+// @Tags traffic
+// @Produce json
+// @Param cluster path string true "Cluster name"
+// @Param identity path string true "Client identity"
+// @Param from query string false "Start (RFC3339, default 24 hours before to)"
+// @Param to query string false "End (RFC3339, default now)"
+// @Param step query string false "Step as a Go duration, e.g. 5m (default about 300 points)"
+// @Success 200 {object} model.Response{data=model.TrafficSeries}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clients/{cluster}/{identity}/traffic [get]
+func (h *TrafficHandler) GetClientTraffic(c *gin.Context) {
+	from, to, step, ok := trafficRange(c)
+	if !ok {
+		return
+	}
+
+	series, err := h.trafficService.ClientSeries(c.Param("cluster"), c.Param("identity"), from, to, step)
+	if err != nil {
+		trafficError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(series))
+}
+
+// GetClusterTraffic godoc
+// @Summary Get cluster traffic
+// @Description Get the combined traffic of all clients of a cluster over time
+// @Tags traffic
+// @Produce json
+// @Param name path string true "Cluster name"
+// @Param from query string false "Start (RFC3339, default 24 hours before to)"
+// @Param to query string false "End (RFC3339, default now)"
+// @Param step query string false "Step as a Go duration, e.g. 1h (default about 300 points)"
+// @Success 200 {object} model.Response{data=model.TrafficSeries}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/clusters/{name}/traffic [get]
+func (h *TrafficHandler) GetClusterTraffic(c *gin.Context) {
+	from, to, step, ok := trafficRange(c)
+	if !ok {
+		return
+	}
+
+	series, err := h.trafficService.ClusterSeries(c.Param("name"), from, to, step)
+	if err != nil {
+		trafficError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(series))
+}
+
+// trafficRange reads the from, to and step query parameters
+func trafficRange(c *gin.Context) (time.Time, time.Time, time.Duration, bool) {
+	invalid := func(name, message string) (time.Time, time.Time, time.Duration, bool) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid "+name,
+			message,
+		))
+		return time.Time{}, time.Time{}, 0, false
+	}
+
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return invalid("to", "to must be an RFC3339 timestamp")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return invalid("from", "from must be an RFC3339 timestamp")
+		}
+		from = parsed
+	}
+
+	var step time.Duration
+	if raw := c.Query("step"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return invalid("step", "step must be a positive duration such as 5m")
+		}
+		step = parsed
+	}
+
+	return from, to, step, true
+}
+
+func trafficError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "client not found" || err.Error() == "cluster not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		"Failed to get traffic",
+		err.Error(),
+	))
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type TrashHandler struct {
+	routeService *service.RouteService
+	auditService *service.AuditService
+}
+
+func NewTrashHandler(routeService *service.RouteService, auditService *service.AuditService) *TrashHandler {
+	return &TrashHandler{
+		routeService: routeService,
+		auditService: auditService,
+	}
+}
+
+// ListTrash godoc
+// @Summary List deleted clients
+// @Description List the clients in the trash, most recently deleted first. Their IPs stay reserved until they are purged.
+// @Tags trash
+// @Produce json
+// @Param cluster query string false "Only list clients of this cluster"
+// @Success 200 {object} model.Response{data=[]model.TrashEntry}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/trash [get]
+func (h *TrashHandler) ListTrash(c *gin.Context) {
+	entries, err := h.routeService.ListTrash(c.Query("cluster"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to list trash",
+			err.Error(),
+		))
+		return
+	}
+
+	entries = middleware.Visible(c, entries, func(entry model.TrashEntry) string {
+		return entry.Cluster
+	})
+
+	c.JSON(http.StatusOK, model.SuccessResponse(entries))
+}
+
+// RestoreEntry godoc
+// @Summary Restore a deleted client
+// @Description Recreate a client from the trash with its previous identity and IP
+// @Tags trash
+// @Produce json
+// @Param id path int true "Trash entry ID"
+// @Success 200 {object} model.Response{data=model.Client}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/trash/{id}/restore [post]
+func (h *TrashHandler) RestoreEntry(c *gin.Context) {
+	id, ok := trashID(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeEntry(c, model.RoleOperator, id, "Failed to restore client") {
+		return
+	}
+
+	client, err := h.routeService.WithActor(middleware.Actor(c)).RestoreFromTrash(id)
+	if err != nil {
+		trashError(c, "Failed to restore client", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(client))
+}
+
+// RestoreCluster godoc
+// @Summary Restore a deleted cluster
+// @Description Recreate, in a single transaction, the most recently deleted version of every client of a cluster in the trash
+// @Tags trash
+// @Produce json
+// @Param cluster query string true "Cluster name"
+// @Success 200 {object} model.Response{data=[]model.Client}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/trash/restore [post]
+func (h *TrashHandler) RestoreCluster(c *gin.Context) {
+	clusterName := c.Query("cluster")
+	if clusterName == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Missing cluster",
+			"cluster query parameter is required",
+		))
+		return
+	}
+
+	clients, err := h.routeService.WithActor(middleware.Actor(c)).RestoreClusterFromTrash(clusterName)
+	if err != nil {
+		trashError(c, "Failed to restore cluster", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(clients))
+}
+
+// PurgeEntry godoc
+// @Summary Purge a deleted client
+// @Description Permanently remove a client from the trash and release its IP
+// @Tags trash
+// @Produce json
+// @Param id path int true "Trash entry ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/trash/{id} [delete]
+func (h *TrashHandler) PurgeEntry(c *gin.Context) {
+	id, ok := trashID(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeEntry(c, model.RoleAdmin, id, "Failed to purge client") {
+		return
+	}
+
+	entry, err := h.routeService.PurgeTrash(id)
+	if err != nil {
+		trashError(c, "Failed to purge client", err)
+		return
+	}
+	h.auditPurged(c, []model.TrashEntry{*entry})
+
+	c.JSON(http.StatusOK, model.SuccessResponse(gin.H{
+		"message": "Client purged successfully",
+	}))
+}
+
+// EmptyTrash godoc
+// @Summary Empty the trash
+// @Description Permanently remove clients from the trash and release their IPs
+// @Tags trash
+// @Produce json
+// @Param cluster query string false "Only purge clients of this cluster"
+// @Param before query string false "Only purge clients deleted before this time (RFC3339)"
+// @Success 200 {object} model.Response{data=[]model.TrashEntry}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/trash [delete]
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	var before time.Time
+	if raw := c.Query("before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+				http.StatusBadRequest,
+				"Invalid before",
+				"before must be an RFC3339 timestamp",
+			))
+			return
+		}
+		before = parsed
+	}
+
+	purged, err := h.routeService.PurgeTrashEntries(c.Query("cluster"), before)
+	if err != nil {
+		trashError(c, "Failed to empty trash", err)
+		return
+	}
+	h.auditPurged(c, purged)
+
+	c.JSON(http.StatusOK, model.SuccessResponse(purged))
+}
+
+// auditPurged records the entries purged by the caller
+func (h *TrashHandler) auditPurged(c *gin.Context, entries []model.TrashEntry) {
+	actor := middleware.Actor(c).Name
+	for _, entry := range entries {
+		detail := fmt.Sprintf("purged (deleted at %s by %s, ip %s released)", entry.DeletedAt.Format(time.RFC3339), entry.DeletedBy, entry.Client.PrivateIP)
+		h.auditService.Record(actor, "trash.purged", entry.Cluster, entry.Identity, detail)
+	}
+}
+
+// authorizeEntry checks that the caller holds role in the cluster of a
+// trash entry, responding with an error otherwise
+func (h *TrashHandler) authorizeEntry(c *gin.Context, role string, id uint, message string) bool {
+	entry, err := h.routeService.GetTrashEntry(id)
+	if err != nil {
+		trashError(c, message, err)
+		return false
+	}
+	return middleware.Authorize(c, role, entry.Cluster)
+}
+
+func trashID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid trash entry ID",
+			"trash entry ID must be a positive integer",
+		))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func trashError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "trash entry not found" {
+		statusCode = http.StatusNotFound
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
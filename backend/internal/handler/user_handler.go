@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type UserHandler struct {
+	userService    *service.UserService
+	sessionService *service.SessionService
+}
+
+func NewUserHandler(userService *service.UserService, sessionService *service.SessionService) *UserHandler {
+	return &UserHandler{
+		userService:    userService,
+		sessionService: sessionService,
+	}
+}
+
+// Me godoc
+// @Summary Get the current account
+// @Description Get the account of the authenticated user
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.Response{data=model.User}
+// @Router /api/auth/me [get]
+func (h *UserHandler) Me(c *gin.Context) {
+	c.JSON(http.StatusOK, model.SuccessResponse(middleware.Account(c)))
+}
+
+// ChangePassword godoc
+// @Summary Change the own password
+// @Description Change the password of the authenticated user. This also completes a forced password change and ends the user's other sessions.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body model.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/auth/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	user, err := h.userService.ChangePassword(middleware.Actor(c).Name, req)
+	if err != nil {
+		userError(c, "Failed to change password", err)
+		return
+	}
+
+	var current uint
+	if session := middleware.Session(c); session != nil {
+		current = session.ID
+	}
+	if err := h.sessionService.RevokeUserSessions(user.Username, user, current); err != nil {
+		userError(c, "Failed to end other sessions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(user))
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description List all dashboard accounts
+// @Tags users
+// @Produce json
+// @Success 200 {object} model.Response{data=[]model.User}
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users [get]
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	users, err := h.userService.ListUsers()
+	if err != nil {
+		userError(c, "Failed to list users", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(users))
+}
+
+// GetUser godoc
+// @Summary Get a user
+// @Description Get a dashboard account by ID
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetUser(id)
+	if err != nil {
+		userError(c, "Failed to get user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(user))
+}
+
+// CreateUser godoc
+// @Summary Create a user
+// @Description Create a dashboard account. Unless must_change_password is false, the user has to change the password on first login.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body model.CreateUserRequest true "Account"
+// @Success 201 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req model.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	user, err := h.userService.CreateUser(middleware.Actor(c).Name, req)
+	if err != nil {
+		userError(c, "Failed to create user", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.SuccessResponse(user))
+}
+
+// UpdateUser godoc
+// @Summary Update a user
+// @Description Change a dashboard account's display name, roles, reset its password, force a password change or disable it. Omitted fields are left unchanged. Resetting the password or disabling the account ends its sessions.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body model.UpdateUserRequest true "Changes"
+// @Success 200 {object} model.Response{data=model.User}
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id} [put]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	actor := middleware.Actor(c).Name
+	user, err := h.userService.UpdateUser(actor, id, req)
+	if err != nil {
+		userError(c, "Failed to update user", err)
+		return
+	}
+
+	if req.Password != nil || user.Disabled {
+		if err := h.sessionService.RevokeUserSessions(actor, user, 0); err != nil {
+			userError(c, "Failed to end sessions", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(user))
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Delete a dashboard account. Users cannot delete themselves.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(middleware.Actor(c).Name, id); err != nil {
+		userError(c, "Failed to delete user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(gin.H{
+		"message": "User deleted successfully",
+	}))
+}
+
+// userID parses the user ID path parameter, responding with 400 if it is
+// invalid
+func userID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Invalid user ID",
+			"user ID must be a positive integer",
+		))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// pathUser looks up the account named by the user ID path parameter,
+// responding with an error if there is none
+func pathUser(c *gin.Context, userService *service.UserService) (*model.User, bool) {
+	id, ok := userID(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := userService.GetUser(id)
+	if err != nil {
+		userError(c, "Failed to get user", err)
+		return nil, false
+	}
+	return user, true
+}
+
+// userError maps user service errors to HTTP status codes
+func userError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		statusCode = http.StatusUnauthorized
+	} else if errors.Is(err, service.ErrValidation) {
+		statusCode = http.StatusBadRequest
+	} else if err.Error() == "user not found" {
+		statusCode = http.StatusNotFound
+	} else if err.Error() == "user already exists" {
+		statusCode = http.StatusConflict
+	}
+	c.JSON(statusCode, model.ErrorResponseWithCode(
+		statusCode,
+		message,
+		err.Error(),
+	))
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/middleware"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/service"
+)
+
+type ValidateHandler struct {
+	routeService *service.RouteService
+}
+
+func NewValidateHandler(routeService *service.RouteService) *ValidateHandler {
+	return &ValidateHandler{
+		routeService: routeService,
+	}
+}
+
+// ValidateRoutes godoc
+// @Summary Validate a routes document
+// @Description Check a routes document (the JSON array of clients rustun reads) for duplicate identities and IPs, addresses outside the tunnel network, gateways used as client IPs, malformed masks and CIDRs and overlapping routes. Without a body the current configuration is checked, which requires the viewer role in all clusters. Problems are reported as findings with a severity; the request itself succeeds either way.
+// @Tags validate
+// @Accept json
+// @Produce json
+// @Param routes body []model.Client false "Routes document"
+// @Success 200 {object} model.Response{data=model.LintReport}
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/validate [post]
+func (h *ValidateHandler) ValidateRoutes(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponseWithCode(
+			http.StatusBadRequest,
+			"Failed to read request body",
+			err.Error(),
+		))
+		return
+	}
+
+	// The current configuration spans all clusters
+	if len(bytes.TrimSpace(data)) == 0 && !middleware.Authorize(c, model.RoleViewer, "") {
+		return
+	}
+
+	report, err := h.routeService.ValidateRoutes(data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponseWithCode(
+			http.StatusInternalServerError,
+			"Failed to validate routes",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse(report))
+}
@@ -73,9 +73,21 @@ func (m *IPAdmManager) InitFromExistingClients(clients []struct {
 	}
 }
 
-// AllocateIP allocates a new IP for the given cluster and returns IP with network config
-func (m *IPAdmManager) AllocateIP(cluster string) (*AllocatedIP, error) {
+// Reset forgets all allocations, e.g. before re-initializing from a restored
+// configuration
+func (m *IPAdmManager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clusters = make(map[string]*ClusterIPAlloc)
+}
+
+// clusterAlloc returns the allocation state of a cluster, creating it with
+// the default config on first use
+func (m *IPAdmManager) clusterAlloc(cluster string) *ClusterIPAlloc {
 	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	alloc, exists := m.clusters[cluster]
 	if !exists {
 		// Create new cluster allocation with default config
@@ -85,7 +97,45 @@ func (m *IPAdmManager) AllocateIP(cluster string) (*AllocatedIP, error) {
 		}
 		m.clusters[cluster] = alloc
 	}
-	m.mu.Unlock()
+	return alloc
+}
+
+// Config returns the network configuration used for the given cluster
+func (m *IPAdmManager) Config(cluster string) IPConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if alloc, exists := m.clusters[cluster]; exists {
+		return alloc.config
+	}
+	return m.defaultConfig
+}
+
+// ReserveIP marks a specific IP as allocated in the given cluster, e.g. for
+// clients imported with a fixed address
+func (m *IPAdmManager) ReserveIP(cluster, ip string) error {
+	if net.ParseIP(ip).To4() == nil {
+		return fmt.Errorf("invalid IPv4 address: %s", ip)
+	}
+
+	alloc := m.clusterAlloc(cluster)
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+
+	if ip == alloc.config.Gateway {
+		return fmt.Errorf("IP %s is the gateway of cluster %s", ip, cluster)
+	}
+	if alloc.allocatedIPs[ip] {
+		return fmt.Errorf("IP %s is already allocated in cluster %s", ip, cluster)
+	}
+
+	alloc.allocatedIPs[ip] = true
+	return nil
+}
+
+// AllocateIP allocates a new IP for the given cluster and returns IP with network config
+func (m *IPAdmManager) AllocateIP(cluster string) (*AllocatedIP, error) {
+	alloc := m.clusterAlloc(cluster)
 
 	alloc.mu.Lock()
 	defer alloc.mu.Unlock()
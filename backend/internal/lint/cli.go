@@ -0,0 +1,116 @@
+package lint
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Exit codes of the lint command
+const (
+	ExitOK       = 0 // No errors (and no warnings with -strict)
+	ExitFindings = 1 // Errors found, or warnings with -strict
+	ExitUsage    = 2 // Bad flags or unreadable files
+)
+
+// Run implements the lint command: it checks the given routes files ("-"
+// reads stdin, no arguments checks ./routes.json) and prints the findings
+// of all of them as one report
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "Output format: text or json")
+	strict := flags.Bool("strict", false, "Fail on warnings as well as errors")
+	quiet := flags.Bool("quiet", false, "Only print errors (text format)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: rustun-dashboard lint [flags] [routes.json ...]")
+		fmt.Fprintln(stderr, "\nChecks routes files for problems that break rustun. Use - to read stdin.")
+		fmt.Fprintln(stderr, "Exits 0 when clean, 1 when errors are found (or warnings with -strict), 2 on usage errors.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "lint: unknown format %q (expected text or json)\n", *format)
+		return ExitUsage
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"./routes.json"}
+	}
+
+	report := &model.LintReport{Findings: make([]model.LintFinding, 0)}
+	for _, file := range files {
+		var (
+			data []byte
+			err  error
+		)
+		if file == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "lint: %v\n", err)
+			return ExitUsage
+		}
+
+		result := CheckDocument(data)
+		for _, f := range result.Findings {
+			f.File = file
+			report.Findings = append(report.Findings, f)
+		}
+		report.Clients += result.Clients
+		report.Errors += result.Errors
+		report.Warnings += result.Warnings
+	}
+	report.Valid = report.Errors == 0
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "lint: %v\n", err)
+			return ExitUsage
+		}
+	} else {
+		printText(stdout, report, *quiet)
+	}
+
+	if report.Errors > 0 || (*strict && report.Warnings > 0) {
+		return ExitFindings
+	}
+	return ExitOK
+}
+
+// printText prints one finding per line in the file:line: form editors and
+// hooks understand, followed by a summary
+func printText(w io.Writer, report *model.LintReport, quiet bool) {
+	for _, f := range report.Findings {
+		if quiet && f.Severity != model.LintError {
+			continue
+		}
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		client := ""
+		if f.Index >= 0 {
+			client = fmt.Sprintf(" client %d", f.Index)
+			if f.Identity != "" {
+				client = fmt.Sprintf(" %s/%s", f.Cluster, f.Identity)
+			}
+		}
+		fmt.Fprintf(w, "%s: %s [%s]%s: %s\n", location, f.Severity, f.Rule, client, f.Message)
+	}
+	if !quiet {
+		fmt.Fprintf(w, "%d clients checked: %d errors, %d warnings\n", report.Clients, report.Errors, report.Warnings)
+	}
+}
@@ -0,0 +1,434 @@
+// Package lint checks routes documents for the mistakes that break rustun:
+// duplicate identities and addresses, addresses outside the tunnel network,
+// malformed masks and CIDRs, and overlapping routes. It applies the same
+// field rules the dashboard enforces when clients are created or imported.
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// entry is a client of a routes document with its position
+type entry struct {
+	client model.Client
+	index  int
+	line   int
+	err    error // Decoding the entry failed; the client is not checked
+}
+
+// Check checks clients that are already decoded, e.g. the current
+// configuration
+func Check(clients []model.Client) *model.LintReport {
+	entries := make([]entry, len(clients))
+	for i, client := range clients {
+		entries[i] = entry{client: client, index: i}
+	}
+	return check(entries, nil)
+}
+
+// CheckDocument decodes and checks a routes document, a JSON array of
+// clients as read by rustun. Findings carry the line of the client entry.
+func CheckDocument(data []byte) *model.LintReport {
+	entries, err := decode(data)
+	if err != nil {
+		return check(nil, []model.LintFinding{*err})
+	}
+	return check(entries, nil)
+}
+
+// decode splits a routes document into its entries, recording the line
+// each one starts on
+func decode(data []byte) ([]entry, *model.LintFinding) {
+	parseError := func(offset int64, format string, args ...interface{}) *model.LintFinding {
+		return &model.LintFinding{
+			Severity: model.LintError,
+			Rule:     "parse",
+			Line:     lineAt(data, int(offset)),
+			Index:    -1,
+			Message:  fmt.Sprintf(format, args...),
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, parseError(dec.InputOffset(), "invalid JSON: %v", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, parseError(0, "routes document must be a JSON array of clients")
+	}
+
+	entries := make([]entry, 0)
+	for dec.More() {
+		start := skipSeparators(data, int(dec.InputOffset()))
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, parseError(syntaxErr.Offset, "invalid JSON: %v", err)
+			}
+			return nil, parseError(int64(start), "invalid JSON: %v", err)
+		}
+
+		e := entry{index: len(entries), line: lineAt(data, start)}
+		if err := json.Unmarshal(raw, &e.client); err != nil {
+			e.err = err
+		}
+		entries = append(entries, e)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, parseError(dec.InputOffset(), "invalid JSON: %v", err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, parseError(dec.InputOffset(), "unexpected data after the clients array")
+	}
+	return entries, nil
+}
+
+// skipSeparators returns the offset of the next value after whitespace and commas
+func skipSeparators(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func lineAt(data []byte, offset int) int {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// checker collects the findings of one document
+type checker struct {
+	findings []model.LintFinding
+}
+
+func (c *checker) add(e *entry, severity, rule, field, format string, args ...interface{}) {
+	c.findings = append(c.findings, model.LintFinding{
+		Severity: severity,
+		Rule:     rule,
+		Line:     e.line,
+		Index:    e.index,
+		Cluster:  e.client.Cluster,
+		Identity: e.client.Identity,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func check(entries []entry, findings []model.LintFinding) *model.LintReport {
+	c := &checker{findings: findings}
+
+	valid := make([]*entry, 0, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		if e.err != nil {
+			c.add(e, model.LintError, "parse", "", "invalid client: %v", e.err)
+			continue
+		}
+		c.checkFields(e)
+		valid = append(valid, e)
+	}
+	c.checkIdentities(valid)
+	c.checkAddresses(valid)
+	c.checkRoutes(valid)
+
+	sort.SliceStable(c.findings, func(i, j int) bool {
+		return c.findings[i].Index < c.findings[j].Index
+	})
+
+	report := &model.LintReport{
+		Clients:  len(entries),
+		Findings: c.findings,
+	}
+	if report.Findings == nil {
+		report.Findings = make([]model.LintFinding, 0)
+	}
+	for _, f := range report.Findings {
+		if f.Severity == model.LintError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+	report.Valid = report.Errors == 0
+	return report
+}
+
+// checkFields applies the rules that concern a single client
+func (c *checker) checkFields(e *entry) {
+	client := e.client
+
+	if strings.TrimSpace(client.Cluster) == "" {
+		c.add(e, model.LintError, "missing-field", "cluster", "cluster is required")
+	} else if err := ValidateClusterName(client.Cluster); err != nil {
+		c.add(e, model.LintError, "invalid-cluster", "cluster", "%v", err)
+	}
+	if strings.TrimSpace(client.Identity) == "" {
+		c.add(e, model.LintError, "missing-field", "identity", "identity is required")
+	}
+
+	ip := c.checkIPv4(e, "private_ip", client.PrivateIP)
+	gateway := c.checkIPv4(e, "gateway", client.Gateway)
+
+	var mask net.IPMask
+	switch {
+	case client.Mask == "":
+		c.add(e, model.LintError, "missing-field", "mask", "mask is required")
+	case !ValidMask(client.Mask):
+		c.add(e, model.LintError, "invalid-mask", "mask", "invalid mask %q: expected a dotted IPv4 netmask with contiguous ones", client.Mask)
+	default:
+		mask = net.IPMask(net.ParseIP(client.Mask).To4())
+	}
+
+	if ip != nil && gateway != nil {
+		if ip.Equal(gateway) {
+			c.add(e, model.LintError, "gateway-is-client-ip", "private_ip", "private_ip %s is the client's own gateway", client.PrivateIP)
+		} else if mask != nil && !ip.Mask(mask).Equal(gateway.Mask(mask)) {
+			c.add(e, model.LintError, "ip-outside-network", "private_ip", "private_ip %s and gateway %s are not in the same /%d network", client.PrivateIP, client.Gateway, prefixLen(mask))
+		}
+	}
+	if ip != nil && mask != nil && prefixLen(mask) < 31 {
+		network := ip.Mask(mask)
+		broadcast := make(net.IP, len(network))
+		for i := range network {
+			broadcast[i] = network[i] | ^mask[i]
+		}
+		if ip.Equal(network) || ip.Equal(broadcast) {
+			c.add(e, model.LintError, "reserved-address", "private_ip", "private_ip %s is the network or broadcast address of %s/%d", client.PrivateIP, network, prefixLen(mask))
+		}
+	}
+
+	seen := make(map[string]string)
+	for _, cidr := range client.Ciders {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			c.add(e, model.LintError, "invalid-cidr", "ciders", "invalid CIDR %q", cidr)
+			continue
+		}
+		if network.String() != cidr {
+			c.add(e, model.LintWarning, "non-canonical-cidr", "ciders", "CIDR %q has host bits set, rustun routes %s", cidr, network)
+		}
+		if first, ok := seen[network.String()]; ok {
+			c.add(e, model.LintWarning, "duplicate-cidr", "ciders", "CIDR %q repeats %q", cidr, first)
+			continue
+		}
+		seen[network.String()] = cidr
+	}
+
+	if err := ValidateLabels(client.Labels); err != nil {
+		c.add(e, model.LintError, "invalid-label", "labels", "%v", err)
+	}
+}
+
+// checkIPv4 reports a missing or malformed address and returns it parsed
+func (c *checker) checkIPv4(e *entry, field, value string) net.IP {
+	if value == "" {
+		c.add(e, model.LintError, "missing-field", field, "%s is required", field)
+		return nil
+	}
+	ip := net.ParseIP(value).To4()
+	if ip == nil {
+		c.add(e, model.LintError, "invalid-ip", field, "invalid %s %q: expected an IPv4 address", field, value)
+	}
+	return ip
+}
+
+// checkIdentities reports identities used more than once. The dashboard
+// keys clients by cluster and identity, so a repeat within a cluster is an
+// error; rustun looks clients up by identity alone, so a repeat across
+// clusters is a warning.
+func (c *checker) checkIdentities(entries []*entry) {
+	first := make(map[string]*entry)
+	for _, e := range entries {
+		identity := e.client.Identity
+		if identity == "" {
+			continue
+		}
+		prev, ok := first[identity]
+		if !ok {
+			first[identity] = e
+			continue
+		}
+		if prev.client.Cluster == e.client.Cluster {
+			c.add(e, model.LintError, "duplicate-identity", "identity", "identity %s is already used by client %s", identity, describe(prev))
+		} else {
+			c.add(e, model.LintWarning, "identity-in-multiple-clusters", "identity", "identity %s is also used in cluster %s (client %s)", identity, prev.client.Cluster, describe(prev))
+		}
+	}
+}
+
+// checkAddresses reports clashing tunnel addresses within each cluster
+func (c *checker) checkAddresses(entries []*entry) {
+	type network struct {
+		gateway, mask string
+		owner         *entry
+	}
+	ips := make(map[string]*entry)      // cluster/ip -> client
+	gateways := make(map[string]*entry) // cluster/gateway -> first client using it
+	networks := make(map[string]network)
+
+	for _, e := range entries {
+		client := e.client
+		if client.Gateway != "" {
+			if _, ok := gateways[client.Cluster+"/"+client.Gateway]; !ok {
+				gateways[client.Cluster+"/"+client.Gateway] = e
+			}
+		}
+	}
+
+	for _, e := range entries {
+		client := e.client
+		if client.PrivateIP != "" {
+			key := client.Cluster + "/" + client.PrivateIP
+			if prev, ok := ips[key]; ok {
+				c.add(e, model.LintError, "duplicate-ip", "private_ip", "private_ip %s is already used by client %s", client.PrivateIP, describe(prev))
+			} else {
+				ips[key] = e
+			}
+			if owner, ok := gateways[key]; ok && client.PrivateIP != client.Gateway {
+				c.add(e, model.LintError, "gateway-is-client-ip", "private_ip", "private_ip %s is the gateway of client %s", client.PrivateIP, describe(owner))
+			}
+		}
+
+		if client.Gateway == "" || client.Mask == "" {
+			continue
+		}
+		prev, ok := networks[client.Cluster]
+		if !ok {
+			networks[client.Cluster] = network{gateway: client.Gateway, mask: client.Mask, owner: e}
+			continue
+		}
+		if prev.gateway != client.Gateway || prev.mask != client.Mask {
+			c.add(e, model.LintWarning, "inconsistent-network", "gateway", "gateway %s/mask %s differ from %s/%s used by client %s in cluster %s",
+				client.Gateway, client.Mask, prev.gateway, prev.mask, describe(prev.owner), client.Cluster)
+		}
+	}
+}
+
+// checkRoutes reports routes that overlap each other or the tunnel network.
+// Disabled clients are not published, so their routes are ignored.
+func (c *checker) checkRoutes(entries []*entry) {
+	type route struct {
+		network *net.IPNet
+		owner   *entry
+	}
+	byCluster := make(map[string][]route)
+
+	for _, e := range entries {
+		client := e.client
+		if !client.Enabled {
+			continue
+		}
+		tunnel := tunnelNetwork(client.PrivateIP, client.Mask)
+
+		seen := make(map[string]bool)
+		for _, cidr := range client.Ciders {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil || network.IP.To4() == nil || seen[network.String()] {
+				continue
+			}
+			seen[network.String()] = true
+
+			if tunnel != nil && overlap(network, tunnel) {
+				c.add(e, model.LintWarning, "route-overlaps-tunnel", "ciders", "route %s overlaps the tunnel network %s", network, tunnel)
+			}
+			for _, other := range byCluster[client.Cluster] {
+				if other.owner == e || !overlap(network, other.network) {
+					continue
+				}
+				if other.network.String() == network.String() {
+					c.add(e, model.LintWarning, "route-overlap", "ciders", "route %s is also routed to client %s", network, describe(other.owner))
+				} else {
+					c.add(e, model.LintWarning, "route-overlap", "ciders", "route %s overlaps route %s of client %s", network, other.network, describe(other.owner))
+				}
+			}
+			byCluster[client.Cluster] = append(byCluster[client.Cluster], route{network: network, owner: e})
+		}
+	}
+}
+
+// describe names a client in messages
+func describe(e *entry) string {
+	name := e.client.Identity
+	if e.client.Name != "" {
+		name = fmt.Sprintf("%s (%s)", e.client.Identity, e.client.Name)
+	}
+	if e.line > 0 {
+		return fmt.Sprintf("%s at line %d", name, e.line)
+	}
+	return fmt.Sprintf("%s at index %d", name, e.index)
+}
+
+// ValidMask reports whether mask is a dotted IPv4 netmask with contiguous ones
+func ValidMask(mask string) bool {
+	ip := net.ParseIP(mask).To4()
+	if ip == nil {
+		return false
+	}
+	_, bits := net.IPMask(ip).Size()
+	return bits != 0
+}
+
+// maxClusterNameLen keeps cluster names usable in file and unit names
+const maxClusterNameLen = 64
+
+// ValidateClusterName checks that a cluster name only uses letters, digits,
+// '-', '_' and '.' and starts with a letter or digit. Cluster names end up
+// in the paths and unit names of generated install scripts.
+func ValidateClusterName(name string) error {
+	if len(name) > maxClusterNameLen {
+		return fmt.Errorf("invalid cluster %q: must be at most %d characters", name, maxClusterNameLen)
+	}
+	for i, r := range name {
+		alnum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if !alnum && (i == 0 || !strings.ContainsRune("-_.", r)) {
+			return fmt.Errorf("invalid cluster %q: use letters, digits, '-', '_' and '.', starting with a letter or digit", name)
+		}
+	}
+	return nil
+}
+
+// ValidateLabels checks label keys and values for characters that would
+// break the CSV export
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("label keys must not be empty")
+		}
+		if strings.ContainsAny(k, "=;") || strings.Contains(v, ";") {
+			return fmt.Errorf("invalid label %q: keys must not contain '=' or ';' and values must not contain ';'", k)
+		}
+	}
+	return nil
+}
+
+// tunnelNetwork returns the network of a client's tunnel IP
+func tunnelNetwork(ip, mask string) *net.IPNet {
+	parsedIP := net.ParseIP(ip).To4()
+	if parsedIP == nil || !ValidMask(mask) {
+		return nil
+	}
+	m := net.IPMask(net.ParseIP(mask).To4())
+	return &net.IPNet{IP: parsedIP.Mask(m), Mask: m}
+}
+
+func overlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func prefixLen(mask net.IPMask) int {
+	ones, _ := mask.Size()
+	return ones
+}
@@ -0,0 +1,153 @@
+// Package logwatch follows a rustun server log file and turns the lines
+// that matter into connection, reload and error events.
+package logwatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event types
+const (
+	TypeConnect    = "connect"
+	TypeDisconnect = "disconnect"
+	TypeReload     = "reload"
+	TypeError      = "error"
+)
+
+// maxMessage caps how much of a log line is kept in an entry
+const maxMessage = 1024
+
+// Rule maps log messages matching Pattern to an event of Type. The named
+// groups identity, cluster and endpoint are copied into the entry.
+type Rule struct {
+	Type    string
+	Pattern *regexp.Regexp
+}
+
+// NewRule compiles a rule
+func NewRule(eventType, pattern string) (Rule, error) {
+	switch eventType {
+	case TypeConnect, TypeDisconnect, TypeReload, TypeError:
+	default:
+		return Rule{}, fmt.Errorf("unsupported event type %q: must be connect, disconnect, reload or error", eventType)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return Rule{Type: eventType, Pattern: re}, nil
+}
+
+// DefaultRules match the messages logged by the rustun server
+func DefaultRules() []Rule {
+	return []Rule{
+		{TypeReload, regexp.MustCompile(`^Routes file changed, reloading`)},
+		{TypeReload, regexp.MustCompile(`^Route reload complete`)},
+		{TypeReload, regexp.MustCompile(`^(?:Added new|Removed|Updated) client: (?P<identity>\S+)`)},
+		{TypeConnect, regexp.MustCompile(`(?i)\bclient\s+(?P<identity>[\w.-]+)\s+(?:connected|authenticated|registered|handshake (?:ok|completed?))(?:\s+from\s+(?P<endpoint>\S+))?`)},
+		{TypeDisconnect, regexp.MustCompile(`(?i)\bclient\s+(?P<identity>[\w.-]+)\s+(?:disconnected|timed out|connection closed)`)},
+	}
+}
+
+// prefix matches the timestamp, level and module that env_logger and
+// tracing put in front of each message, e.g.
+// "[2024-05-01T12:00:00Z INFO  rustun::server] message" or
+// "2024-05-01T12:00:00.123456Z  INFO rustun::server: message"
+var prefix = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?)?\s*(TRACE|DEBUG|INFO|WARN|ERROR)?\s+(?:[\w:]+:?\]?\s+)?`)
+
+// ansi matches terminal color codes
+var ansi = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// identityHint finds a client identity in error messages
+var identityHint = regexp.MustCompile(`(?i)\b(?:client|identity)[\s=:]+(?P<identity>[\w.-]+)`)
+
+// Entry is a log line recognized as an event
+type Entry struct {
+	Time     time.Time // Zero if the line has no timestamp
+	Type     string
+	Level    string
+	Cluster  string
+	Identity string
+	Endpoint string
+	Message  string
+}
+
+// Parser recognizes events in log lines
+type Parser struct {
+	rules []Rule
+}
+
+// NewParser creates a parser trying rules in order. Lines logged at ERROR
+// level that match no rule become error events.
+func NewParser(rules []Rule) *Parser {
+	return &Parser{rules: rules}
+}
+
+// Parse returns the event in a log line, if any
+func (p *Parser) Parse(line string) (Entry, bool) {
+	line = strings.TrimSpace(ansi.ReplaceAllString(line, ""))
+	if line == "" {
+		return Entry{}, false
+	}
+
+	var entry Entry
+	message := line
+	if m := prefix.FindStringSubmatchIndex(line); m != nil && (m[2] >= 0 || m[4] >= 0) {
+		if m[2] >= 0 {
+			entry.Time = parseTime(line[m[2]:m[3]])
+		}
+		if m[4] >= 0 {
+			entry.Level = line[m[4]:m[5]]
+		}
+		message = line[m[1]:]
+	}
+	if len(message) > maxMessage {
+		message = message[:maxMessage]
+	}
+	entry.Message = message
+
+	for _, rule := range p.rules {
+		match := rule.Pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		entry.Type = rule.Type
+		for i, name := range rule.Pattern.SubexpNames() {
+			switch name {
+			case "identity":
+				entry.Identity = match[i]
+			case "cluster":
+				entry.Cluster = match[i]
+			case "endpoint":
+				entry.Endpoint = match[i]
+			}
+		}
+		return entry, true
+	}
+
+	if entry.Level == "ERROR" {
+		entry.Type = TypeError
+		if match := identityHint.FindStringSubmatch(message); match != nil {
+			entry.Identity = match[1]
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
+
+func parseTime(value string) time.Time {
+	value = strings.Replace(value, " ", "T", 1)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", value, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
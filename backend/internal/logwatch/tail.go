@@ -0,0 +1,155 @@
+package logwatch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxLine caps the length of a single log line; longer lines are cut
+const maxLine = 64 * 1024
+
+// Tailer follows a log file by polling it, like tail -F. It survives the
+// file being rotated, truncated or not existing yet.
+type Tailer struct {
+	path      string
+	interval  time.Duration
+	fromStart bool
+
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial string
+}
+
+// NewTailer creates a tailer polling path every interval. Unless fromStart
+// is set, lines already in the file when it is first opened are skipped.
+func NewTailer(path string, interval time.Duration, fromStart bool) *Tailer {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Tailer{
+		path:      path,
+		interval:  interval,
+		fromStart: fromStart,
+	}
+}
+
+// Run passes batches of new complete lines to fn until ctx is done
+func (t *Tailer) Run(ctx context.Context, fn func(lines []string)) {
+	defer t.close()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if lines := t.poll(); len(lines) > 0 {
+			fn(lines)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll reads the lines appended since the last poll
+func (t *Tailer) poll() []string {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Logwatch] Failed to stat %s: %v", t.path, err)
+		}
+		// A removed file may still be written to until the writer
+		// reopens, so keep reading the open one
+		if t.file == nil {
+			return nil
+		}
+	} else if t.file == nil || !os.SameFile(info, t.info) {
+		// Opened for the first time, or rotated: finish the old file and
+		// continue with the new one from its start
+		lines := t.read()
+		first := t.file == nil
+		if !t.open(info, first && !t.fromStart) {
+			return lines
+		}
+		return append(lines, t.read()...)
+	} else if info.Size() < t.offset {
+		// Truncated in place
+		t.offset = 0
+		t.partial = ""
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			log.Printf("[Logwatch] Failed to rewind %s: %v", t.path, err)
+		}
+	}
+
+	return t.read()
+}
+
+func (t *Tailer) open(info os.FileInfo, atEnd bool) bool {
+	t.close()
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		log.Printf("[Logwatch] Failed to open %s: %v", t.path, err)
+		return false
+	}
+
+	t.file = file
+	t.info = info
+	t.offset = 0
+	t.partial = ""
+	if atEnd {
+		offset, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			log.Printf("[Logwatch] Failed to seek %s: %v", t.path, err)
+		}
+		t.offset = offset
+	}
+	return true
+}
+
+func (t *Tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// read returns the complete lines from the current offset. An unfinished
+// last line is kept until its newline arrives.
+func (t *Tailer) read() []string {
+	if t.file == nil {
+		return nil
+	}
+
+	var lines []string
+	reader := bufio.NewReader(t.file)
+	for {
+		chunk, err := reader.ReadString('\n')
+		t.offset += int64(len(chunk))
+		if err != nil {
+			t.partial += chunk
+			if len(t.partial) > maxLine {
+				t.partial = t.partial[:maxLine]
+			}
+			if err != io.EOF {
+				log.Printf("[Logwatch] Failed to read %s: %v", t.path, err)
+			}
+			return lines
+		}
+
+		line := t.partial + strings.TrimRight(chunk, "\r\n")
+		t.partial = ""
+		if len(line) > maxLine {
+			line = line[:maxLine]
+		}
+		lines = append(lines, line)
+	}
+}
@@ -1,36 +1,208 @@
 package middleware
 
 import (
-	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 )
 
-// BasicAuth creates a Basic Authentication middleware
-func BasicAuth(username, password string) gin.HandlerFunc {
+// UserKey is the gin context key holding the authenticated username
+const UserKey = "user"
+
+// SourceHeader lets callers declare where a request comes from (e.g. "ui")
+const SourceHeader = "X-Rustun-Source"
+
+// AccountKey is the gin context key holding the authenticated *model.User
+const AccountKey = "account"
+
+// TokenKey is the gin context key holding the *model.APIToken of requests
+// authenticated with an API token
+const TokenKey = "api_token"
+
+// SessionKey is the gin context key holding the *model.Session of requests
+// authenticated with a session access token
+const SessionKey = "session"
+
+// Authenticator checks a username and password
+type Authenticator interface {
+	Authenticate(username, password string) (*model.User, error)
+}
+
+// TokenAuthenticator checks an API token used from the given address
+type TokenAuthenticator interface {
+	Authenticate(token, ip string) (*model.User, *model.APIToken, error)
+}
+
+// SessionAuthenticator checks the access token of a login session
+type SessionAuthenticator interface {
+	Authenticate(accessToken string) (*model.User, *model.Session, error)
+}
+
+// passwordChangePaths are the routes an account that must change its
+// password can still use
+var passwordChangePaths = map[string]bool{
+	"/api/auth/me":       true,
+	"/api/auth/password": true,
+	"/api/auth/logout":   true,
+}
+
+// BasicAuth creates a Basic Authentication middleware checking credentials
+// against the dashboard accounts. Accounts that must change their password
+// are limited to doing so. Requests already authenticated by BearerAuth are
+// passed on.
+func BasicAuth(users Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, pass, ok := c.Request.BasicAuth()
+		if Account(c) != nil {
+			c.Next()
+			return
+		}
 
+		username, password, ok := c.Request.BasicAuth()
 		if !ok {
 			unauthorized(c)
 			return
 		}
 
-		// Use constant time comparison to prevent timing attacks
-		userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
-		passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
-
-		if !userMatch || !passMatch {
+		user, err := users.Authenticate(username, password)
+		if err != nil {
 			unauthorized(c)
 			return
 		}
 
+		if !setAccount(c, user) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// BearerAuth creates a middleware authenticating requests that carry an API
+// token or the access token of a login session as "Authorization: Bearer".
+// Requests without one are left to BasicAuth.
+func BearerAuth(sessions SessionAuthenticator, tokens TokenAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, value, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			c.Next()
+			return
+		}
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, model.APITokenPrefix) {
+			user, token, err := tokens.Authenticate(value, c.ClientIP())
+			if err != nil {
+				invalidToken(c, err)
+				return
+			}
+			if !setAccount(c, user) {
+				return
+			}
+			c.Set(TokenKey, token)
+		} else {
+			user, session, err := sessions.Authenticate(value)
+			if err != nil {
+				invalidToken(c, err)
+				return
+			}
+			if !setAccount(c, user) {
+				return
+			}
+			c.Set(SessionKey, session)
+		}
 		c.Next()
 	}
 }
 
+// RejectAPITokens creates a middleware refusing requests authenticated with
+// an API token, for account management that is not delegated to scripts
+func RejectAPITokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Token(c) != nil {
+			c.JSON(http.StatusForbidden, model.ErrorResponseWithCode(
+				http.StatusForbidden,
+				"Forbidden",
+				"this cannot be done with an API token",
+			))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope creates a middleware rejecting requests made with an API
+// token that lacks scope. Other requests are passed on.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := Token(c); token != nil && !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, model.ErrorResponseWithCode(
+				http.StatusForbidden,
+				"Forbidden",
+				"this requires an API token with the "+scope+" scope",
+			))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setAccount stores the authenticated account in the request context. An
+// account that must change its password is limited to doing so; other
+// requests are rejected and false is returned.
+func setAccount(c *gin.Context, user *model.User) bool {
+	if user.MustChangePassword && !passwordChangePaths[c.FullPath()] {
+		c.JSON(http.StatusForbidden, model.ErrorResponseWithCode(
+			http.StatusForbidden,
+			"Password change required",
+			"change your password with PUT /api/auth/password before using the API",
+		))
+		c.Abort()
+		return false
+	}
+
+	c.Set(UserKey, user.Username)
+	c.Set(AccountKey, user)
+	return true
+}
+
+// Account returns the authenticated account of the request, or nil
+func Account(c *gin.Context) *model.User {
+	if user, ok := c.Get(AccountKey); ok {
+		return user.(*model.User)
+	}
+	return nil
+}
+
+// Token returns the API token the request was authenticated with, or nil
+func Token(c *gin.Context) *model.APIToken {
+	if token, ok := c.Get(TokenKey); ok {
+		return token.(*model.APIToken)
+	}
+	return nil
+}
+
+// Session returns the login session the request was authenticated with, or
+// nil
+func Session(c *gin.Context) *model.Session {
+	if session, ok := c.Get(SessionKey); ok {
+		return session.(*model.Session)
+	}
+	return nil
+}
+
+func invalidToken(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	c.JSON(http.StatusUnauthorized, model.ErrorResponseWithCode(
+		http.StatusUnauthorized,
+		"Unauthorized",
+		err.Error(),
+	))
+	c.Abort()
+}
+
 func unauthorized(c *gin.Context) {
 	c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
 	c.JSON(http.StatusUnauthorized, model.ErrorResponseWithCode(
@@ -40,3 +212,16 @@ func unauthorized(c *gin.Context) {
 	))
 	c.Abort()
 }
+
+// Actor returns the authenticated user of the request and the source it
+// declared through SourceHeader, defaulting to the API
+func Actor(c *gin.Context) model.Actor {
+	actor := model.Actor{
+		Name:   c.GetString(UserKey),
+		Source: model.SourceAPI,
+	}
+	if c.GetHeader(SourceHeader) == model.SourceUI {
+		actor.Source = model.SourceUI
+	}
+	return actor
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// fakeUsers authenticates the accounts it holds with the password "secret"
+type fakeUsers map[string]*model.User
+
+func (f fakeUsers) Authenticate(username, password string) (*model.User, error) {
+	if user, ok := f[username]; ok && password == "secret" {
+		return user, nil
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+func TestBasicAuthPasswordChange(t *testing.T) {
+	users := fakeUsers{
+		"admin": {Username: "admin", MustChangePassword: true, Roles: []model.RoleBinding{{Role: model.RoleAdmin}}},
+		"ops":   {Username: "ops", Roles: []model.RoleBinding{{Role: model.RoleOperator}}},
+	}
+
+	router := gin.New()
+	api := router.Group("/api", BasicAuth(users))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/auth/me", ok)
+	api.PUT("/auth/password", ok)
+	api.POST("/auth/logout", ok)
+	api.GET("/clients", ok)
+	api.POST("/auth/sessions", ok)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		method   string
+		path     string
+		expected int
+	}{
+		{"wrong password", "ops", "guess", http.MethodGet, "/api/clients", http.StatusUnauthorized},
+		{"unknown user", "nobody", "secret", http.MethodGet, "/api/clients", http.StatusUnauthorized},
+		{"regular account", "ops", "secret", http.MethodGet, "/api/clients", http.StatusOK},
+		{"must change: clients", "admin", "secret", http.MethodGet, "/api/clients", http.StatusForbidden},
+		{"must change: other auth route", "admin", "secret", http.MethodPost, "/api/auth/sessions", http.StatusForbidden},
+		{"must change: me", "admin", "secret", http.MethodGet, "/api/auth/me", http.StatusOK},
+		{"must change: password", "admin", "secret", http.MethodPut, "/api/auth/password", http.StatusOK},
+		{"must change: logout", "admin", "secret", http.MethodPost, "/api/auth/logout", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBasicAuthWithoutCredentials(t *testing.T) {
+	router := gin.New()
+	router.GET("/api/clients", BasicAuth(fakeUsers{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/clients", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Scope tells which cluster a request concerns. With any set, a role in
+// any cluster is enough to pass the middleware and the handler checks the
+// clusters it touches, e.g. named in the request body, or filters what it
+// returns. Otherwise the role is required in cluster, or in all clusters
+// if cluster is empty.
+type Scope func(c *gin.Context) (cluster string, any bool)
+
+// Global requires the role in all clusters
+func Global(c *gin.Context) (string, bool) {
+	return "", false
+}
+
+// AnyCluster requires the role in some cluster and leaves the rest to the
+// handler
+func AnyCluster(c *gin.Context) (string, bool) {
+	return "", true
+}
+
+// ClusterParam requires the role in the cluster named by a path parameter
+func ClusterParam(name string) Scope {
+	return func(c *gin.Context) (string, bool) {
+		return c.Param(name), false
+	}
+}
+
+// ClusterQuery requires the role in the cluster named by a query parameter,
+// falling back to otherwise when the parameter is absent
+func ClusterQuery(name string, otherwise Scope) Scope {
+	return func(c *gin.Context) (string, bool) {
+		if cluster := c.Query(name); cluster != "" {
+			return cluster, false
+		}
+		return otherwise(c)
+	}
+}
+
+// Require creates a middleware that rejects requests whose caller does not
+// hold at least role in the scope of the request
+func Require(role string, scope Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cluster, any := scope(c)
+
+		if any {
+			if !Authorizer(c).AllowsAny(role) {
+				forbidden(c, role, "in any cluster")
+				return
+			}
+		} else if !Authorize(c, role, cluster) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Allowed reports whether the caller holds at least role in cluster, or in
+// all clusters if cluster is empty
+func Allowed(c *gin.Context, role, cluster string) bool {
+	return Authorizer(c).Allows(role, cluster)
+}
+
+// Authorize responds with 403 and returns false unless the caller holds at
+// least role in cluster
+func Authorize(c *gin.Context, role, cluster string) bool {
+	if Allowed(c, role, cluster) {
+		return true
+	}
+	where := "in all clusters"
+	if cluster != "" {
+		where = "in cluster " + cluster
+	}
+	forbidden(c, role, where)
+	return false
+}
+
+// AuthorizeHeartbeat responds with 403 and returns false unless the caller
+// may record heartbeats of clients in cluster: with an API token with the
+// heartbeat scope, or with the operator role there
+func AuthorizeHeartbeat(c *gin.Context, cluster string) bool {
+	if user, token := Account(c), Token(c); user != nil && token != nil && token.ReportsHeartbeats(user, cluster) {
+		return true
+	}
+	return Authorize(c, model.RoleOperator, cluster)
+}
+
+// Authorizer returns what the caller of the request may do. Requests made
+// with an API token are limited to its scopes and clusters.
+func Authorizer(c *gin.Context) model.Authorizer {
+	if user := Account(c); user != nil {
+		if token := Token(c); token != nil {
+			return token.Authorizer(user)
+		}
+		return user
+	}
+	return denyAll{}
+}
+
+func forbidden(c *gin.Context, role, where string) {
+	c.JSON(http.StatusForbidden, model.ErrorResponseWithCode(
+		http.StatusForbidden,
+		"Forbidden",
+		"this requires the "+role+" role "+where,
+	))
+	c.Abort()
+}
+
+// denyAll is the authorizer of unauthenticated requests
+type denyAll struct{}
+
+func (denyAll) Allows(role, cluster string) bool { return false }
+func (denyAll) AllowsAny(role string) bool       { return false }
+
+// Visible returns the items in clusters where the caller holds at least the
+// viewer role
+func Visible[T any](c *gin.Context, items []T, cluster func(T) string) []T {
+	access := Authorizer(c)
+	visible := make([]T, 0, len(items))
+	for _, item := range items {
+		if access.Allows(model.RoleViewer, cluster(item)) {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
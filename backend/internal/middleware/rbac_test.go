@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs one request through a router that authenticates it as user,
+// with token if not nil, and returns the response status
+func serve(user *model.User, token *model.APIToken, route, target string, handlers ...gin.HandlerFunc) int {
+	router := gin.New()
+	authenticate := func(c *gin.Context) {
+		if user != nil {
+			c.Set(AccountKey, user)
+		}
+		if token != nil {
+			c.Set(TokenKey, token)
+		}
+	}
+	handlers = append([]gin.HandlerFunc{authenticate}, handlers...)
+	handlers = append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET(route, handlers...)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w.Code
+}
+
+func TestRequire(t *testing.T) {
+	viewer := &model.User{Username: "viewer", Roles: []model.RoleBinding{{Role: model.RoleViewer}}}
+	prodOperator := &model.User{Username: "ops", Roles: []model.RoleBinding{{Role: model.RoleOperator, Cluster: "prod"}}}
+	admin := &model.User{Username: "admin", Roles: []model.RoleBinding{{Role: model.RoleAdmin}}}
+
+	tests := []struct {
+		name     string
+		user     *model.User
+		role     string
+		scope    Scope
+		route    string
+		target   string
+		expected int
+	}{
+		{"unauthenticated", nil, model.RoleViewer, AnyCluster, "/clients", "/clients", http.StatusForbidden},
+		{"global admin", admin, model.RoleAdmin, Global, "/users", "/users", http.StatusOK},
+		{"global with a cluster role", prodOperator, model.RoleOperator, Global, "/users", "/users", http.StatusForbidden},
+		{"any cluster with a cluster role", prodOperator, model.RoleOperator, AnyCluster, "/clients", "/clients", http.StatusOK},
+		{"any cluster below the role", viewer, model.RoleOperator, AnyCluster, "/clients", "/clients", http.StatusForbidden},
+		{"path cluster with the role", prodOperator, model.RoleOperator, ClusterParam("cluster"), "/clusters/:cluster", "/clusters/prod", http.StatusOK},
+		{"path cluster without the role", prodOperator, model.RoleOperator, ClusterParam("cluster"), "/clusters/:cluster", "/clusters/dev", http.StatusForbidden},
+		{"path cluster with a global role", viewer, model.RoleViewer, ClusterParam("cluster"), "/clusters/:cluster", "/clusters/dev", http.StatusOK},
+		{"query cluster with the role", prodOperator, model.RoleOperator, ClusterQuery("cluster", Global), "/clients", "/clients?cluster=prod", http.StatusOK},
+		{"query cluster without the role", prodOperator, model.RoleOperator, ClusterQuery("cluster", AnyCluster), "/clients", "/clients?cluster=dev", http.StatusForbidden},
+		{"query fallback to global", prodOperator, model.RoleOperator, ClusterQuery("cluster", Global), "/clients", "/clients", http.StatusForbidden},
+		{"query fallback to any cluster", prodOperator, model.RoleOperator, ClusterQuery("cluster", AnyCluster), "/clients", "/clients", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.user, nil, tt.route, tt.target, Require(tt.role, tt.scope)); got != tt.expected {
+				t.Errorf("status = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequireWithToken(t *testing.T) {
+	admin := &model.User{Username: "admin", Roles: []model.RoleBinding{{Role: model.RoleAdmin}}}
+
+	tests := []struct {
+		name     string
+		token    *model.APIToken
+		role     string
+		scope    Scope
+		target   string
+		expected int
+	}{
+		{"scope allows the role", &model.APIToken{Scopes: []string{model.ScopeClientsWrite}}, model.RoleOperator, ClusterParam("cluster"), "/clusters/prod", http.StatusOK},
+		{"scope caps the role", &model.APIToken{Scopes: []string{model.ScopeClientsRead}}, model.RoleOperator, ClusterParam("cluster"), "/clusters/prod", http.StatusForbidden},
+		{"token cluster", &model.APIToken{Scopes: []string{model.ScopeClientsWrite}, Clusters: []string{"prod"}}, model.RoleOperator, ClusterParam("cluster"), "/clusters/prod", http.StatusOK},
+		{"outside the token clusters", &model.APIToken{Scopes: []string{model.ScopeClientsWrite}, Clusters: []string{"prod"}}, model.RoleOperator, ClusterParam("cluster"), "/clusters/dev", http.StatusForbidden},
+		{"cluster token used globally", &model.APIToken{Scopes: []string{model.ScopeClustersManage}, Clusters: []string{"prod"}}, model.RoleAdmin, Global, "/clusters/prod", http.StatusForbidden},
+		{"cluster token in any cluster", &model.APIToken{Scopes: []string{model.ScopeClientsRead}, Clusters: []string{"prod"}}, model.RoleViewer, AnyCluster, "/clusters/prod", http.StatusOK},
+		{"agent scope only", &model.APIToken{Scopes: []string{model.ScopeAgent}}, model.RoleViewer, AnyCluster, "/clusters/prod", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(admin, tt.token, "/clusters/:cluster", tt.target, Require(tt.role, tt.scope)); got != tt.expected {
+				t.Errorf("status = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthorizeHeartbeat(t *testing.T) {
+	viewer := &model.User{Username: "agent", Roles: []model.RoleBinding{{Role: model.RoleViewer, Cluster: "prod"}}}
+	operator := &model.User{Username: "ops", Roles: []model.RoleBinding{{Role: model.RoleOperator, Cluster: "prod"}}}
+
+	tests := []struct {
+		name     string
+		user     *model.User
+		token    *model.APIToken
+		target   string
+		expected int
+	}{
+		{"heartbeat token", viewer, &model.APIToken{Scopes: []string{model.ScopeHeartbeat}}, "/clusters/prod", http.StatusOK},
+		{"heartbeat token in another cluster", viewer, &model.APIToken{Scopes: []string{model.ScopeHeartbeat}}, "/clusters/dev", http.StatusForbidden},
+		{"viewer without a token", viewer, nil, "/clusters/prod", http.StatusForbidden},
+		{"operator without a token", operator, nil, "/clusters/prod", http.StatusOK},
+		{"operator with a read token", operator, &model.APIToken{Scopes: []string{model.ScopeClientsRead}}, "/clusters/prod", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(c *gin.Context) {
+				if !AuthorizeHeartbeat(c, c.Param("cluster")) {
+					return
+				}
+				c.Next()
+			}
+			if got := serve(tt.user, tt.token, "/clusters/:cluster", tt.target, check); got != tt.expected {
+				t.Errorf("status = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package model
+
+import "time"
+
+// API token scopes. The client and cluster scopes cap the roles a token can
+// use to viewer, operator and admin respectively.
+const (
+	ScopeClientsRead    = "clients:read"
+	ScopeClientsWrite   = "clients:write"
+	ScopeClustersManage = "clusters:manage"
+	ScopeAgent          = "agent"             // Use the AI agent
+	ScopeHeartbeat      = "clients:heartbeat" // Report client heartbeats
+)
+
+var scopeRoles = map[string]string{
+	ScopeClientsRead:    RoleViewer,
+	ScopeClientsWrite:   RoleOperator,
+	ScopeClustersManage: RoleAdmin,
+}
+
+// ValidScope reports whether scope is a known scope
+func ValidScope(scope string) bool {
+	return scope == ScopeAgent || scope == ScopeHeartbeat || scopeRoles[scope] != ""
+}
+
+// APITokenPrefix starts every API token, telling them apart from session
+// access tokens
+const APITokenPrefix = "rdt_"
+
+// API token states
+const (
+	APITokenActive  = "active"
+	APITokenExpired = "expired"
+	APITokenRevoked = "revoked"
+)
+
+// APIToken lets scripts and CI call the API on behalf of a user. Only a
+// SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID        uint     `gorm:"primarykey" json:"id"`
+	UserID    uint     `gorm:"index;not null" json:"user_id"`
+	Name      string   `json:"name"`
+	Prefix    string   `json:"prefix"` // Start of the token, to tell tokens apart
+	TokenHash string   `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Scopes    []string `gorm:"type:text;serializer:json" json:"scopes"`
+
+	// Clusters restricts the token to these clusters; empty allows every
+	// cluster of the user
+	Clusters []string `gorm:"type:text;serializer:json" json:"clusters,omitempty"`
+
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"` // Never expires if nil
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+
+	Status string `gorm:"-" json:"status"`
+}
+
+// TableName specifies the table name for GORM
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// StatusAt returns the state of the token at the given time
+func (t *APIToken) StatusAt(now time.Time) string {
+	switch {
+	case t.RevokedAt != nil:
+		return APITokenRevoked
+	case t.ExpiresAt != nil && !t.ExpiresAt.After(now):
+		return APITokenExpired
+	default:
+		return APITokenActive
+	}
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer returns what requests made with the token may do: the roles
+// of its user, capped by its scopes and limited to its clusters
+func (t *APIToken) Authorizer(user *User) Authorizer {
+	return tokenAuthorizer{token: t, user: user}
+}
+
+// ReportsHeartbeats reports whether requests made with the token may record
+// heartbeats of clients in cluster: the token needs the heartbeat scope and
+// must cover the cluster, where its user needs at least the viewer role
+func (t *APIToken) ReportsHeartbeats(user *User, cluster string) bool {
+	return t.HasScope(ScopeHeartbeat) && t.covers(cluster) && user.Allows(RoleViewer, cluster)
+}
+
+// grants reports whether one of the token's scopes allows role
+func (t *APIToken) grants(role string) bool {
+	for _, scope := range t.Scopes {
+		if roleRanks[scopeRoles[scope]] >= roleRanks[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether the token may be used in cluster, or in all
+// clusters if cluster is empty
+func (t *APIToken) covers(cluster string) bool {
+	if len(t.Clusters) == 0 {
+		return true
+	}
+	for _, allowed := range t.Clusters {
+		if allowed == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+type tokenAuthorizer struct {
+	token *APIToken
+	user  *User
+}
+
+func (a tokenAuthorizer) Allows(role, cluster string) bool {
+	return a.token.grants(role) && a.token.covers(cluster) && a.user.Allows(role, cluster)
+}
+
+func (a tokenAuthorizer) AllowsAny(role string) bool {
+	if !a.token.grants(role) {
+		return false
+	}
+	if len(a.token.Clusters) == 0 {
+		return a.user.AllowsAny(role)
+	}
+	for _, cluster := range a.token.Clusters {
+		if a.user.Allows(role, cluster) {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenRequest is the body of an API token creation request
+type APITokenRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Scopes   []string `json:"scopes" binding:"required"`
+	Clusters []string `json:"clusters"` // Optional cluster restriction
+	TTL      string   `json:"ttl"`      // Optional lifetime, e.g. 720h; tokens without one never expire
+}
+
+// APITokenCreated is a new token with its plaintext value
+type APITokenCreated struct {
+	APIToken
+	Token string `json:"token"`
+}
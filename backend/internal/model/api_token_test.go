@@ -0,0 +1,93 @@
+package model
+
+import "testing"
+
+func TestTokenAuthorizerAllows(t *testing.T) {
+	operator := &User{Username: "ops", Roles: []RoleBinding{{Role: RoleOperator, Cluster: "prod"}}}
+	admin := &User{Username: "root", Roles: []RoleBinding{{Role: RoleAdmin}}}
+
+	tests := []struct {
+		name     string
+		user     *User
+		token    APIToken
+		role     string
+		cluster  string
+		expected bool
+	}{
+		{"read scope allows viewer", operator, APIToken{Scopes: []string{ScopeClientsRead}}, RoleViewer, "prod", true},
+		{"read scope caps at viewer", operator, APIToken{Scopes: []string{ScopeClientsRead}}, RoleOperator, "prod", false},
+		{"write scope allows operator", operator, APIToken{Scopes: []string{ScopeClientsWrite}}, RoleOperator, "prod", true},
+		{"write scope caps at operator", admin, APIToken{Scopes: []string{ScopeClientsWrite}}, RoleAdmin, "prod", false},
+		{"manage scope allows admin", admin, APIToken{Scopes: []string{ScopeClustersManage}}, RoleAdmin, "", true},
+		{"highest scope wins", admin, APIToken{Scopes: []string{ScopeClientsRead, ScopeClustersManage}}, RoleAdmin, "prod", true},
+		{"scope does not raise the user", operator, APIToken{Scopes: []string{ScopeClustersManage}}, RoleAdmin, "prod", false},
+		{"user role outside its cluster", operator, APIToken{Scopes: []string{ScopeClientsWrite}}, RoleViewer, "dev", false},
+		{"agent scope grants no role", admin, APIToken{Scopes: []string{ScopeAgent}}, RoleViewer, "prod", false},
+		{"heartbeat scope grants no role", admin, APIToken{Scopes: []string{ScopeHeartbeat}}, RoleViewer, "prod", false},
+		{"no scopes", admin, APIToken{}, RoleViewer, "prod", false},
+		{"token cluster covered", admin, APIToken{Scopes: []string{ScopeClientsWrite}, Clusters: []string{"prod"}}, RoleOperator, "prod", true},
+		{"token cluster not covered", admin, APIToken{Scopes: []string{ScopeClientsWrite}, Clusters: []string{"prod"}}, RoleOperator, "dev", false},
+		{"cluster token is not global", admin, APIToken{Scopes: []string{ScopeClientsWrite}, Clusters: []string{"prod"}}, RoleOperator, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Authorizer(tt.user).Allows(tt.role, tt.cluster); got != tt.expected {
+				t.Errorf("Allows(%q, %q) = %t, want %t", tt.role, tt.cluster, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTokenAuthorizerAllowsAny(t *testing.T) {
+	user := &User{Username: "ops", Roles: []RoleBinding{
+		{Role: RoleViewer},
+		{Role: RoleOperator, Cluster: "prod"},
+	}}
+
+	tests := []struct {
+		name     string
+		token    APIToken
+		role     string
+		expected bool
+	}{
+		{"write scope in the user's cluster", APIToken{Scopes: []string{ScopeClientsWrite}}, RoleOperator, true},
+		{"read scope caps at viewer", APIToken{Scopes: []string{ScopeClientsRead}}, RoleOperator, false},
+		{"limited to the user's cluster", APIToken{Scopes: []string{ScopeClientsWrite}, Clusters: []string{"prod"}}, RoleOperator, true},
+		{"limited to other clusters", APIToken{Scopes: []string{ScopeClientsWrite}, Clusters: []string{"dev"}}, RoleOperator, false},
+		{"global role in a token cluster", APIToken{Scopes: []string{ScopeClientsRead}, Clusters: []string{"dev"}}, RoleViewer, true},
+		{"role above the user", APIToken{Scopes: []string{ScopeClustersManage}}, RoleAdmin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Authorizer(user).AllowsAny(tt.role); got != tt.expected {
+				t.Errorf("AllowsAny(%q) = %t, want %t", tt.role, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReportsHeartbeats(t *testing.T) {
+	user := &User{Username: "agent", Roles: []RoleBinding{{Role: RoleViewer, Cluster: "prod"}}}
+
+	tests := []struct {
+		name     string
+		token    APIToken
+		cluster  string
+		expected bool
+	}{
+		{"heartbeat scope", APIToken{Scopes: []string{ScopeHeartbeat}}, "prod", true},
+		{"without heartbeat scope", APIToken{Scopes: []string{ScopeClientsWrite}}, "prod", false},
+		{"cluster the user cannot view", APIToken{Scopes: []string{ScopeHeartbeat}}, "dev", false},
+		{"cluster outside the token", APIToken{Scopes: []string{ScopeHeartbeat}, Clusters: []string{"dev"}}, "prod", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.ReportsHeartbeats(user, tt.cluster); got != tt.expected {
+				t.Errorf("ReportsHeartbeats(%q) = %t, want %t", tt.cluster, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// AuditEntry records a single administrative event
+type AuditEntry struct {
+	ID       uint      `gorm:"primarykey" json:"id"`
+	Time     time.Time `gorm:"index;not null" json:"time"`
+	Actor    string    `gorm:"index" json:"actor"`  // User or subsystem that performed the action
+	Action   string    `gorm:"index" json:"action"` // e.g. client.expired
+	Cluster  string    `gorm:"index" json:"cluster,omitempty"`
+	Identity string    `gorm:"index" json:"identity,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (AuditEntry) TableName() string {
+	return "audit_logs"
+}
+
+// AuditFilter narrows down an audit log query
+type AuditFilter struct {
+	Cluster  string
+	Identity string
+	Action   string
+	Limit    int // 0 means no limit
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Batch operation types
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// Batch item statuses
+const (
+	BatchStatusApplied    = "applied"     // Operation succeeded and was committed
+	BatchStatusFailed     = "failed"      // Operation caused the batch to roll back
+	BatchStatusRolledBack = "rolled_back" // Operation succeeded but was undone by a later failure
+	BatchStatusSkipped    = "skipped"     // Operation was not attempted
+)
+
+// BatchRequest represents the request body for POST /api/clients:batch
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required"`
+}
+
+// BatchOperation is a single create, update or delete within a batch.
+// For updates, omitted fields keep their current value.
+type BatchOperation struct {
+	Op        string     `json:"op"`                 // create, update or delete
+	Cluster   string     `json:"cluster"`            // Required for all operations
+	Identity  string     `json:"identity,omitempty"` // Required for update and delete; generated for create if empty
+	Name      *string    `json:"name,omitempty"`
+	Ciders    []string   `json:"ciders,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Enabled   *bool      `json:"enabled,omitempty"` // Defaults to true for create
+}
+
+// BatchItemResult reports the outcome of one batch operation
+type BatchItemResult struct {
+	Index    int     `json:"index"`
+	Op       string  `json:"op"`
+	Cluster  string  `json:"cluster"`
+	Identity string  `json:"identity,omitempty"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Client   *Client `json:"client,omitempty"` // Resulting client for create and update
+}
+
+// BatchResult reports the outcome of a whole batch
+type BatchResult struct {
+	Applied bool              `json:"applied"` // False if the batch was rolled back
+	Results []BatchItemResult `json:"results"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// ConnectSettings are the rustun server settings a client needs to connect
+type ConnectSettings struct {
+	Server            string `json:"server"`                       // Server address (host:port)
+	Crypto            string `json:"crypto,omitempty"`             // Client -c value, e.g. chacha20:key
+	EnableP2P         bool   `json:"enable_p2p"`                   // Pass --enable-p2p
+	KeepaliveInterval int    `json:"keepalive_interval,omitempty"` // Seconds, 0 uses the client default
+	Binary            string `json:"binary"`                       // Path of the rustun client binary
+}
+
+// ClientConfig is a ready-to-run rustun client configuration
+type ClientConfig struct {
+	Cluster   string   `json:"cluster"`
+	Identity  string   `json:"identity"`
+	Name      string   `json:"name,omitempty"`
+	PrivateIP string   `json:"private_ip"`
+	Ciders    []string `json:"ciders"`
+	Enabled   bool     `json:"enabled"`
+	ConnectSettings
+	NextCrypto string `json:"next_crypto,omitempty"` // Pending cluster key, used once it is activated
+	Command    string `json:"command"`               // Equivalent command line
+}
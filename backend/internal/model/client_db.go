@@ -9,16 +9,19 @@ import (
 // ClientDB represents the database model for Client (when using ORM like GORM)
 // This is prepared for future database implementation
 type ClientDB struct {
-	ID        uint      `gorm:"primarykey" json:"id"`
-	Cluster   string    `gorm:"index:idx_cluster_identity,unique;not null" json:"cluster"`
-	Identity  string    `gorm:"index:idx_cluster_identity,unique;not null" json:"identity"`
-	Name      string    `gorm:"" json:"name"` // Optional friendly name
-	PrivateIP string    `gorm:"not null" json:"private_ip"`
-	Mask      string    `gorm:"not null" json:"mask"`
-	Gateway   string    `gorm:"not null" json:"gateway"`
-	Ciders    JSONArray `gorm:"type:json" json:"ciders"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint              `gorm:"primarykey" json:"id"`
+	Cluster   string            `gorm:"index:idx_cluster_identity,unique;not null" json:"cluster"`
+	Identity  string            `gorm:"index:idx_cluster_identity,unique;not null" json:"identity"`
+	Name      string            `gorm:"" json:"name"` // Optional friendly name
+	PrivateIP string            `gorm:"not null" json:"private_ip"`
+	Mask      string            `gorm:"not null" json:"mask"`
+	Gateway   string            `gorm:"not null" json:"gateway"`
+	Ciders    JSONArray         `gorm:"type:json" json:"ciders"`
+	ExpiresAt *time.Time        `gorm:"index" json:"expires_at,omitempty"`
+	Labels    map[string]string `gorm:"type:text;serializer:json" json:"labels,omitempty"`
+	Disabled  bool              `gorm:"not null;default:false" json:"disabled"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
 }
 
 // TableName specifies the table name for GORM
@@ -36,6 +39,9 @@ func (c *ClientDB) ToClient() Client {
 		Mask:      c.Mask,
 		Gateway:   c.Gateway,
 		Ciders:    c.Ciders,
+		ExpiresAt: c.ExpiresAt,
+		Labels:    c.Labels,
+		Enabled:   !c.Disabled,
 	}
 }
 
@@ -48,6 +54,9 @@ func (c *ClientDB) FromClient(client Client) {
 	c.Mask = client.Mask
 	c.Gateway = client.Gateway
 	c.Ciders = client.Ciders
+	c.ExpiresAt = client.ExpiresAt
+	c.Labels = client.Labels
+	c.Disabled = !client.Enabled
 }
 
 // JSONArray is a custom type for storing string arrays as JSON in database
@@ -74,4 +83,3 @@ func (j JSONArray) Value() (driver.Value, error) {
 	}
 	return json.Marshal(j)
 }
-
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// Key states of the staged rotation workflow: a new key is pending until it
+// is activated, which retires the previously active key
+const (
+	KeyPending = "pending"
+	KeyActive  = "active"
+	KeyRetired = "retired"
+)
+
+// ClusterKey is a crypto key shared by the clients of a cluster. The key
+// itself is stored encrypted and never serialized.
+type ClusterKey struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Cluster      string     `gorm:"index;not null" json:"cluster"`
+	Cipher       string     `gorm:"not null" json:"cipher"` // chacha20 or aes256gcm
+	Status       string     `gorm:"index;not null" json:"status"`
+	Fingerprint  string     `json:"fingerprint"` // Short hash to compare keys without revealing them
+	EncryptedKey string     `gorm:"type:text;not null" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CreatedBy    string     `json:"created_by"`
+	ActivatedAt  *time.Time `json:"activated_at,omitempty"`
+	RetiredAt    *time.Time `json:"retired_at,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (ClusterKey) TableName() string {
+	return "cluster_keys"
+}
+
+// KeyGenerateRequest asks for a new key for a cluster
+type KeyGenerateRequest struct {
+	Cipher string `json:"cipher"` // chacha20 (default) or aes256gcm
+}
+
+// KeyActivateRequest confirms a key activation
+type KeyActivateRequest struct {
+	ServerUpdated bool `json:"server_updated"` // The rustun server already uses the key; required if keys.server_config is off
+}
+
+// KeySecret is a revealed key
+type KeySecret struct {
+	ClusterKey
+	Key    string `json:"key"`
+	Crypto string `json:"crypto"` // Client -c value, e.g. chacha20:key
+}
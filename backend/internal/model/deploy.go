@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Delivery states of a deployment target
+const (
+	DeployPending  = "pending"  // Not delivered since the dashboard started
+	DeploySynced   = "synced"   // The last delivery succeeded
+	DeployRetrying = "retrying" // The last delivery failed and will be retried
+	DeployFailed   = "failed"   // Retries are exhausted until the next change or re-sync
+)
+
+// DeployTargetStatus is the delivery status of a deployment target
+type DeployTargetStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Destination string     `json:"destination"`
+	Clusters    []string   `json:"clusters,omitempty"` // Empty if the target receives all clusters
+	State       string     `json:"state"`
+	Clients     int        `json:"clients"`          // Clients in the last delivered document
+	Digest      string     `json:"digest,omitempty"` // SHA-256 of the last delivered document
+	Attempts    int        `json:"attempts"`         // Failed attempts to deliver the current document
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	NextRetry   *time.Time `json:"next_retry,omitempty"`
+}
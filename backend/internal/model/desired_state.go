@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// DesiredClient is one entry of a declarative cluster definition. Existing
+// clients are matched by identity, or by name when no identity is given.
+type DesiredClient struct {
+	Identity  string     `json:"identity,omitempty"` // Generated on create if empty
+	Name      string     `json:"name,omitempty"`
+	Ciders    []string   `json:"ciders"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Enabled   *bool      `json:"enabled,omitempty"` // Defaults to true
+}
+
+// DesiredStateRequest represents the request body for
+// PUT /api/clusters/{name}/desired-state
+type DesiredStateRequest struct {
+	Clients    []DesiredClient `json:"clients" binding:"required"`
+	DryRun     bool            `json:"dry_run"`
+	AllowEmpty bool            `json:"allow_empty"` // Confirms that an empty list deletes every client
+}
+
+// ClusterPlan lists the changes that bring a cluster to its desired state
+type ClusterPlan struct {
+	Cluster string         `json:"cluster"`
+	DryRun  bool           `json:"dry_run"`
+	Applied bool           `json:"applied"`
+	Summary DiffSummary    `json:"summary"`
+	Changes []ClientChange `json:"changes"`
+}
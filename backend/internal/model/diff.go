@@ -0,0 +1,128 @@
+package model
+
+import "time"
+
+// Change actions used in diffs
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// FieldChange describes a single changed client field
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ClientChange describes how a single client differs between two states
+type ClientChange struct {
+	Action   string        `json:"action"` // create, update or delete
+	Cluster  string        `json:"cluster"`
+	Identity string        `json:"identity,omitempty"` // Empty for creates whose identity is generated on apply
+	Name     string        `json:"name,omitempty"`
+	Fields   []FieldChange `json:"fields,omitempty"` // Changed fields, for updates
+	Before   *Client       `json:"before,omitempty"`
+	After    *Client       `json:"after,omitempty"`
+}
+
+// DiffSummary counts changes by action
+type DiffSummary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Delete    int `json:"delete"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Add counts a change in the summary
+func (s *DiffSummary) Add(action string) {
+	switch action {
+	case ChangeCreate:
+		s.Create++
+	case ChangeUpdate:
+		s.Update++
+	case ChangeDelete:
+		s.Delete++
+	}
+}
+
+// DiffClients compares two versions of a client field by field. A nil
+// before or after stands for a client that does not exist, in which case
+// every set field of the other side is reported.
+func DiffClients(before, after *Client) []FieldChange {
+	var empty Client
+	if before == nil {
+		before = &empty
+	}
+	if after == nil {
+		after = &empty
+	}
+
+	changes := make([]FieldChange, 0)
+	addString := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+
+	addString("cluster", before.Cluster, after.Cluster)
+	addString("identity", before.Identity, after.Identity)
+	addString("name", before.Name, after.Name)
+	addString("private_ip", before.PrivateIP, after.PrivateIP)
+	addString("mask", before.Mask, after.Mask)
+	addString("gateway", before.Gateway, after.Gateway)
+
+	if !equalStrings(before.Ciders, after.Ciders) {
+		changes = append(changes, FieldChange{Field: "ciders", Old: nonNil(before.Ciders), New: nonNil(after.Ciders)})
+	}
+	if !equalTimes(before.ExpiresAt, after.ExpiresAt) {
+		changes = append(changes, FieldChange{Field: "expires_at", Old: before.ExpiresAt, New: after.ExpiresAt})
+	}
+	if !equalLabels(before.Labels, after.Labels) {
+		changes = append(changes, FieldChange{Field: "labels", Old: before.Labels, New: after.Labels})
+	}
+	if before.Enabled != after.Enabled {
+		changes = append(changes, FieldChange{Field: "enabled", Old: before.Enabled, New: after.Enabled})
+	}
+
+	return changes
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
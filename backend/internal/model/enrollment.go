@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// Enrollment token states, derived from the token's timestamps
+const (
+	EnrollmentActive  = "active"
+	EnrollmentUsed    = "used"
+	EnrollmentExpired = "expired"
+	EnrollmentRevoked = "revoked"
+)
+
+// EnrollmentToken lets a new client register itself in a cluster once. Only
+// a hash of the token is stored; the token itself is shown when it is
+// created and never again.
+type EnrollmentToken struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	Prefix    string            `json:"prefix"` // Start of the token, to tell tokens apart
+	TokenHash string            `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Cluster   string            `gorm:"index;not null" json:"cluster"`
+	Name      string            `json:"name,omitempty"` // Preset client name
+	Labels    map[string]string `gorm:"type:text;serializer:json" json:"labels,omitempty"`
+	Ciders    []string          `gorm:"type:text;serializer:json" json:"ciders,omitempty"`
+	ExpiresAt time.Time         `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	CreatedBy string            `json:"created_by"`
+
+	UsedAt         *time.Time `json:"used_at,omitempty"`
+	ClientIdentity string     `json:"client_identity,omitempty"` // Client created with the token
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy      string     `json:"revoked_by,omitempty"`
+
+	Status string `gorm:"-" json:"status"`
+}
+
+// TableName specifies the table name for GORM
+func (EnrollmentToken) TableName() string {
+	return "enrollment_tokens"
+}
+
+// StatusAt returns the state of the token at the given time
+func (t *EnrollmentToken) StatusAt(now time.Time) string {
+	switch {
+	case t.RevokedAt != nil:
+		return EnrollmentRevoked
+	case t.UsedAt != nil:
+		return EnrollmentUsed
+	case !t.ExpiresAt.After(now):
+		return EnrollmentExpired
+	default:
+		return EnrollmentActive
+	}
+}
+
+// EnrollmentTokenRequest represents the request body for creating an
+// enrollment token
+type EnrollmentTokenRequest struct {
+	Cluster string            `json:"cluster" binding:"required"`
+	Name    string            `json:"name"`   // Optional preset client name
+	Labels  map[string]string `json:"labels"` // Optional labels of the client
+	Ciders  []string          `json:"ciders"` // Optional routes of the client
+	TTL     string            `json:"ttl"`    // Optional lifetime, e.g. 1h (defaults to enrollment.default_ttl)
+}
+
+// EnrollmentTokenCreated is a new token with its plaintext value
+type EnrollmentTokenCreated struct {
+	EnrollmentToken
+	Token     string `json:"token"`
+	EnrollURL string `json:"enroll_url,omitempty"` // Opening it shows an enrollment page, POSTing to it enrolls directly; set if enrollment.url is configured
+}
+
+// EnrollRequest trades an enrollment token for a new client
+type EnrollRequest struct {
+	Token string `json:"token"` // May also be passed as the token query parameter
+	Name  string `json:"name"`  // Client name, used if the token has no preset name
+}
+
+// EnrollResult is the client created by an enrollment and its configuration
+type EnrollResult struct {
+	Client Client        `json:"client"`
+	Config *ClientConfig `json:"config,omitempty"`
+}
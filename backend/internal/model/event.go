@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Event is something that happened on a rustun server, derived from its log
+type Event struct {
+	ID       uint      `gorm:"primarykey" json:"id"`
+	Time     time.Time `gorm:"index;not null" json:"time"`
+	Type     string    `gorm:"index;not null" json:"type"` // connect, disconnect, reload or error
+	Cluster  string    `gorm:"index" json:"cluster,omitempty"`
+	Identity string    `gorm:"index" json:"identity,omitempty"`
+	Endpoint string    `json:"endpoint,omitempty"`
+	Message  string    `json:"message"` // Log message the event was derived from
+}
+
+// TableName specifies the table name for GORM
+func (Event) TableName() string {
+	return "events"
+}
+
+// EventFilter narrows down an event query
+type EventFilter struct {
+	Cluster  string
+	Identity string
+	Type     string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int // 0 means no limit
+}
+
+// Matches reports whether an event passes the filter
+func (f EventFilter) Matches(event Event) bool {
+	if f.Cluster != "" && event.Cluster != f.Cluster {
+		return false
+	}
+	if f.Identity != "" && event.Identity != f.Identity {
+		return false
+	}
+	if f.Type != "" && event.Type != f.Type {
+		return false
+	}
+	if f.Since != nil && event.Time.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !event.Time.Before(*f.Until) {
+		return false
+	}
+	return true
+}
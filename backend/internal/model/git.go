@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// GitCommit describes a commit of the git-backed routes repository
+type GitCommit struct {
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Time        time.Time `json:"time"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body,omitempty"`
+}
+
+// GitStatus reports the state of the git-backed routes repository
+type GitStatus struct {
+	Dir         string     `json:"dir"`
+	Head        string     `json:"head"`
+	Remote      string     `json:"remote,omitempty"`
+	Branch      string     `json:"branch,omitempty"`
+	LastPush    *time.Time `json:"last_push,omitempty"`
+	LastPushErr string     `json:"last_push_error,omitempty"`
+}
+
+// GitRevertRequest selects the commit to revert
+type GitRevertRequest struct {
+	Commit string `json:"commit" binding:"required"`
+}
+
+// GitRevertResult reports the outcome of a revert
+type GitRevertResult struct {
+	Commit  string         `json:"commit"` // The new commit undoing the reverted one
+	Summary DiffSummary    `json:"summary"`
+	Changes []ClientChange `json:"changes"`
+}
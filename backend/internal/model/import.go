@@ -0,0 +1,60 @@
+package model
+
+// Import conflict policies, applied when an imported client already exists
+const (
+	ConflictSkip      = "skip"      // Keep the existing client
+	ConflictOverwrite = "overwrite" // Replace the existing client with the imported one
+	ConflictFail      = "fail"      // Reject the whole import
+)
+
+// Import row actions
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportSkip      = "skip"
+	ImportInvalid   = "invalid"
+)
+
+// ImportRecord is one decoded client with its position in the source document
+type ImportRecord struct {
+	Row    int    // 1-based row (CSV: line number, JSON/YAML: list index + 1)
+	Client Client // Decoded client; empty fields were left empty in the source
+	Err    string // Decoding error for this row, if any
+}
+
+// ImportOptions controls how an import is applied
+type ImportOptions struct {
+	Policy  string // skip, overwrite or fail
+	Cluster string // Only import rows of this cluster; rows without a cluster get it
+	DryRun  bool
+}
+
+// ImportRowResult reports what happens to one imported row
+type ImportRowResult struct {
+	Row      int    `json:"row"`
+	Cluster  string `json:"cluster"`
+	Identity string `json:"identity,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Action   string `json:"action"` // create, update, unchanged, skip or invalid
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportSummary counts imported rows by action
+type ImportSummary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Unchanged int `json:"unchanged"`
+	Skip      int `json:"skip"`
+	Invalid   int `json:"invalid"`
+}
+
+// ImportResult reports the outcome or preview of an import
+type ImportResult struct {
+	Policy  string            `json:"policy"`
+	DryRun  bool              `json:"dry_run"`
+	Applied bool              `json:"applied"`
+	Summary ImportSummary     `json:"summary"`
+	Rows    []ImportRowResult `json:"rows"`
+	Changes []ClientChange    `json:"changes"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// Lint finding severities
+const (
+	LintError   = "error"   // rustun or the dashboard would reject or misroute the client
+	LintWarning = "warning" // Suspicious but accepted, e.g. overlapping routes
+)
+
+// LintFinding is a problem found in a routes document
+type LintFinding struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`           // Stable rule name, e.g. duplicate-ip
+	File     string `json:"file,omitempty"` // Set by the lint command
+	Line     int    `json:"line,omitempty"` // Line of the client entry, when decoded from a file
+	Index    int    `json:"index"`          // Position of the client in the document, -1 for the document itself
+	Cluster  string `json:"cluster,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintReport is the result of checking a routes document
+type LintReport struct {
+	Valid    bool          `json:"valid"` // No errors; warnings are allowed
+	Clients  int           `json:"clients"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Findings []LintFinding `json:"findings"`
+}
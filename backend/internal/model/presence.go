@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Connection states of a client, derived from when it last sent a heartbeat
+const (
+	PresenceOnline  = "online"  // Seen within the online threshold
+	PresenceStale   = "stale"   // Seen within the offline threshold, but not recently
+	PresenceOffline = "offline" // Not seen within the offline threshold, or never
+)
+
+// Presence is the last heartbeat received from a client
+type Presence struct {
+	Cluster  string    `gorm:"primaryKey;size:255" json:"cluster"`
+	Identity string    `gorm:"primaryKey;size:255" json:"identity"`
+	Endpoint string    `json:"endpoint,omitempty"` // Public address the client connects from
+	Version  string    `json:"version,omitempty"`  // rustun client version
+	Uptime   int64     `json:"uptime"`             // Seconds since the client started
+	LastSeen time.Time `gorm:"index" json:"last_seen"`
+}
+
+// TableName specifies the table name for GORM
+func (Presence) TableName() string {
+	return "client_presence"
+}
+
+// HeartbeatRequest is sent periodically by rustun clients or a sidecar
+type HeartbeatRequest struct {
+	Cluster  string `json:"cluster"` // Optional if the identity is unique across clusters
+	Identity string `json:"identity" binding:"required"`
+	Endpoint string `json:"endpoint"` // Defaults to the address the heartbeat came from
+	Version  string `json:"version"`
+	Uptime   int64  `json:"uptime"` // Seconds since the client started
+}
+
+// ClientStatus is the connection state of a client
+type ClientStatus struct {
+	State    string     `json:"state"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	Endpoint string     `json:"endpoint,omitempty"`
+	Version  string     `json:"version,omitempty"`
+	Uptime   int64      `json:"uptime,omitempty"` // Seconds, as of the last heartbeat
+}
+
+// ClientWithStatus is a client together with its connection state
+type ClientWithStatus struct {
+	Client
+	Status *ClientStatus `json:"status,omitempty"`
+}
@@ -0,0 +1,61 @@
+package model
+
+// Roles, from least to most privileged. Each role includes the ones below it.
+const (
+	RoleViewer   = "viewer"   // Read clusters, clients, status and history
+	RoleOperator = "operator" // Create, change and delete clients
+	RoleAdmin    = "admin"    // Manage clusters and keys; globally also users and server settings
+)
+
+var roleRanks = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleBinding grants a role in one cluster, or in all clusters when Cluster
+// is empty
+type RoleBinding struct {
+	Role    string `json:"role"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// Authorizer answers whether the caller of a request holds a role
+type Authorizer interface {
+	// Allows reports whether the caller holds at least role in cluster, or
+	// in all clusters if cluster is empty
+	Allows(role, cluster string) bool
+
+	// AllowsAny reports whether the caller holds at least role in some
+	// cluster
+	AllowsAny(role string) bool
+}
+
+// Allows reports whether the user's role bindings grant at least role in
+// cluster, or in all clusters if cluster is empty
+func (u *User) Allows(role, cluster string) bool {
+	for _, binding := range u.Roles {
+		if roleRanks[binding.Role] < roleRanks[role] {
+			continue
+		}
+		if binding.Cluster == "" || (cluster != "" && binding.Cluster == cluster) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAny reports whether the user holds at least role in some cluster
+func (u *User) AllowsAny(role string) bool {
+	for _, binding := range u.Roles {
+		if roleRanks[binding.Role] >= roleRanks[role] {
+			return true
+		}
+	}
+	return false
+}
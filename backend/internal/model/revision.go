@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// Sources of a change
+const (
+	SourceUI     = "ui"     // Dashboard web UI
+	SourceAPI    = "api"    // Direct API call
+	SourceAgent  = "agent"  // AI agent tool call
+	SourceSystem = "system" // Background job inside the dashboard
+)
+
+// Actor identifies who made a change and through which channel
+type Actor struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// Revision records one change to a client with full before/after snapshots.
+// Before is nil for creations and After is nil for deletions.
+type Revision struct {
+	ID       uint          `gorm:"primarykey" json:"id"`
+	Time     time.Time     `gorm:"index;not null" json:"time"`
+	Actor    string        `gorm:"index" json:"actor"`
+	Source   string        `json:"source"`
+	Action   string        `gorm:"index" json:"action"` // create, update or delete
+	Cluster  string        `gorm:"index;not null" json:"cluster"`
+	Identity string        `gorm:"index;not null" json:"identity"`
+	Comment  string        `json:"comment,omitempty"`
+	Before   *Client       `gorm:"type:text;serializer:json" json:"before,omitempty"`
+	After    *Client       `gorm:"type:text;serializer:json" json:"after,omitempty"`
+	Changes  []FieldChange `gorm:"-" json:"changes,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (Revision) TableName() string {
+	return "client_revisions"
+}
+
+// RevisionFilter narrows down a revision query
+type RevisionFilter struct {
+	Cluster  string
+	Identity string
+	After    time.Time // Only revisions recorded after this time, if set
+	Limit    int       // 0 means no limit
+}
+
+// TimeDiff lists the client changes between two points in time
+type TimeDiff struct {
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Summary DiffSummary    `json:"summary"`
+	Changes []ClientChange `json:"changes"`
+}
+
+// RollbackRequest selects the revision to restore
+type RollbackRequest struct {
+	Revision uint `json:"revision" binding:"required"`
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Client represents a VPN client configuration
 type Client struct {
 	Cluster   string   `json:"cluster" binding:"required"`
@@ -9,6 +14,35 @@ type Client struct {
 	Mask      string   `json:"mask" binding:"required"`
 	Gateway   string   `json:"gateway" binding:"required"`
 	Ciders    []string `json:"ciders"`
+
+	// ExpiresAt is an optional deadline after which the expiry scheduler
+	// deactivates the client
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Labels are free-form key/value tags, e.g. set when a client enrolls
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Enabled controls whether the client is published to rustun. Disabled
+	// clients keep their identity and IP reservation but are left out of
+	// the routes file.
+	Enabled bool `json:"enabled"`
+}
+
+// UnmarshalJSON decodes a client, treating a missing "enabled" field as true
+// so routes files written before the flag existed stay fully enabled
+func (c *Client) UnmarshalJSON(data []byte) error {
+	type plainClient Client
+	decoded := plainClient{Enabled: true}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*c = Client(decoded)
+	return nil
+}
+
+// IsExpired reports whether the client has an expiry time at or before now
+func (c *Client) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !c.ExpiresAt.After(now)
 }
 
 // ClientCreateRequest represents the request body for creating a client
@@ -17,6 +51,9 @@ type ClientCreateRequest struct {
 	Cluster string   `json:"cluster" binding:"required"`
 	Name    string   `json:"name"` // Optional friendly name
 	Ciders  []string `json:"ciders"`
+
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"` // Optional expiry time (RFC3339)
+	Labels    map[string]string `json:"labels,omitempty"`     // Optional key/value tags
 }
 
 // Cluster represents a group of clients
@@ -0,0 +1,22 @@
+package model
+
+// Crypto modes supported by the rustun server
+const (
+	CryptoChaCha20  = "chacha20"
+	CryptoAES256GCM = "aes256gcm"
+	CryptoXOR       = "xor"
+	CryptoPlain     = "plain"
+)
+
+// MaskedSecret replaces secrets in responses. Sending it back in an update
+// keeps the stored secret.
+const MaskedSecret = "********"
+
+// ServerConfig is the rustun server configuration (server.toml)
+type ServerConfig struct {
+	ListenAddr string `json:"listen_addr" binding:"required"` // Address the server listens on, e.g. 0.0.0.0:8080
+	Crypto     string `json:"crypto" binding:"required"`      // chacha20, aes256gcm, xor or plain
+	CryptoKey  string `json:"crypto_key,omitempty"`           // Masked in responses; empty or masked keeps the current key
+	RoutesFile string `json:"routes_file"`                    // routes.json read by the server
+	Path       string `json:"path,omitempty"`                 // Location of the config file (read-only)
+}
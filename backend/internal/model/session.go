@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Session is a login of the web UI. It is kept alive with a refresh token,
+// of which only a SHA-256 hash is stored, and authenticates requests with
+// short-lived signed access tokens.
+type Session struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	RefreshHash string    `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`                     // Last time a refresh token was issued
+	ExpiresAt   time.Time `gorm:"index;not null" json:"expires_at"` // Ends unless refreshed before
+	IP          string    `json:"ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+
+	Current bool `gorm:"-" json:"current"` // Whether the listing request was made with this session
+}
+
+// TableName specifies the table name for GORM
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// LoginResponse is a new session and its tokens
+type LoginResponse struct {
+	User             *User     `json:"user"`
+	SessionID        uint      `json:"session_id"`
+	TokenType        string    `json:"token_type"` // Always Bearer
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"` // When the access token expires
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshRequest trades a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Snapshot triggers
+const (
+	SnapshotManual    = "manual"    // Created on demand
+	SnapshotAutomatic = "automatic" // Created before a destructive operation
+)
+
+// Snapshot is a point-in-time copy of the clients of all clusters
+type Snapshot struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"index;not null" json:"name"`
+	Description string    `json:"description,omitempty"`
+	Trigger     string    `json:"trigger"` // manual or automatic
+	Actor       string    `json:"actor"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	ClientCount int       `json:"client_count"`
+	Clients     []Client  `gorm:"type:string;size:16777216;serializer:json" json:"clients,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (Snapshot) TableName() string {
+	return "snapshots"
+}
+
+// SnapshotCreateRequest represents the request to create a snapshot
+type SnapshotCreateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SnapshotDiff lists the changes between two configurations
+type SnapshotDiff struct {
+	From    string         `json:"from"` // Snapshot ID or "current"
+	To      string         `json:"to"`
+	Summary DiffSummary    `json:"summary"`
+	Changes []ClientChange `json:"changes"`
+}
+
+// SnapshotRestoreResult reports the outcome of restoring a snapshot
+type SnapshotRestoreResult struct {
+	Restored *Snapshot     `json:"restored"`
+	Backup   *Snapshot     `json:"backup"` // Snapshot taken right before the restore
+	Diff     *SnapshotDiff `json:"diff"`
+}
@@ -0,0 +1,82 @@
+package model
+
+// Topology node types
+const (
+	TopologyGateway = "gateway"
+	TopologyClient  = "client"
+	TopologySubnet  = "subnet"
+)
+
+// Topology edge types
+const (
+	TopologyTunnel = "tunnel" // Client to gateway
+	TopologyRoute  = "route"  // Client to a LAN subnet it advertises
+)
+
+// Reachability of an edge
+const (
+	ReachUp       = "up"       // The client is online
+	ReachDegraded = "degraded" // The client's heartbeats are stale
+	ReachDown     = "down"     // The client is offline
+	ReachDisabled = "disabled" // The client is disabled and not published
+	ReachConflict = "conflict" // The route overlaps a route of another client
+)
+
+// Overlap kinds
+const (
+	OverlapDuplicate = "duplicate" // Two clients advertise the same subnet
+	OverlapContains  = "contains"  // One client's subnet contains another's
+	OverlapTunnel    = "tunnel"    // A subnet overlaps the tunnel network
+)
+
+// Topology is the computed network graph of a cluster
+type Topology struct {
+	Cluster  string            `json:"cluster"`
+	Networks []string          `json:"networks"` // Tunnel networks the client IPs are in
+	Nodes    []TopologyNode    `json:"nodes"`
+	Edges    []TopologyEdge    `json:"edges"`
+	Overlaps []TopologyOverlap `json:"overlaps"`
+	Summary  TopologySummary   `json:"summary"`
+}
+
+// TopologyNode is a gateway, client or subnet
+type TopologyNode struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Label    string `json:"label"`
+	IP       string `json:"ip,omitempty"`       // Gateway and client tunnel IP
+	CIDR     string `json:"cidr,omitempty"`     // Subnet
+	Identity string `json:"identity,omitempty"` // Client
+	Name     string `json:"name,omitempty"`     // Client
+	Enabled  *bool  `json:"enabled,omitempty"`  // Client
+	Status   string `json:"status,omitempty"`   // Client: online, stale or offline
+	Conflict bool   `json:"conflict,omitempty"` // Subnet: overlaps another route or the tunnel network
+}
+
+// TopologyEdge is a tunnel or a route
+type TopologyEdge struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Reachability string `json:"reachability"`
+}
+
+// TopologyOverlap is a pair of overlapping subnets
+type TopologyOverlap struct {
+	Kind       string   `json:"kind"`
+	CIDR       string   `json:"cidr"`
+	Other      string   `json:"other"`      // Overlapping subnet or tunnel network
+	Identities []string `json:"identities"` // Clients advertising the subnets
+}
+
+// TopologySummary counts the elements of a topology
+type TopologySummary struct {
+	Clients  int `json:"clients"`
+	Online   int `json:"online"`
+	Stale    int `json:"stale"`
+	Offline  int `json:"offline"`
+	Disabled int `json:"disabled"`
+	Subnets  int `json:"subnets"`
+	Overlaps int `json:"overlaps"`
+}
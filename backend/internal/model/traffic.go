@@ -0,0 +1,87 @@
+package model
+
+import "time"
+
+// Resolutions traffic is stored at. Samples are rolled up into 5-minute and
+// hourly buckets as they arrive, and each resolution is kept for its own
+// retention period.
+const (
+	TrafficRaw        = "raw"
+	TrafficFiveMinute = "5m"
+	TrafficHourly     = "1h"
+)
+
+// TrafficCounter is the last byte counter reading of a client, used to turn
+// cumulative counters into per-interval traffic
+type TrafficCounter struct {
+	Cluster  string    `gorm:"primaryKey;size:255" json:"cluster"`
+	Identity string    `gorm:"primaryKey;size:255" json:"identity"`
+	RxBytes  int64     `json:"rx_bytes"`
+	TxBytes  int64     `json:"tx_bytes"`
+	Time     time.Time `json:"time"`
+}
+
+// TableName specifies the table name for GORM
+func (TrafficCounter) TableName() string {
+	return "traffic_counters"
+}
+
+// TrafficSample is the traffic of a client in one bucket of a resolution
+type TrafficSample struct {
+	Cluster    string    `gorm:"primaryKey;size:255" json:"cluster"`
+	Identity   string    `gorm:"primaryKey;size:255" json:"identity"`
+	Resolution string    `gorm:"primaryKey;size:8" json:"resolution"`
+	Time       time.Time `gorm:"primaryKey;index" json:"time"` // Start of the bucket
+	RxBytes    int64     `json:"rx_bytes"`
+	TxBytes    int64     `json:"tx_bytes"`
+}
+
+// TableName specifies the table name for GORM
+func (TrafficSample) TableName() string {
+	return "traffic_samples"
+}
+
+// TrafficReport is a byte counter reading of a client. The counters are
+// cumulative, e.g. since the client connected; a counter lower than the
+// previous reading is taken as a reset.
+type TrafficReport struct {
+	Cluster  string     `json:"cluster"` // Optional if the identity is unique across clusters
+	Identity string     `json:"identity"`
+	RxBytes  int64      `json:"rx_bytes"`
+	TxBytes  int64      `json:"tx_bytes"`
+	Time     *time.Time `json:"time,omitempty"` // Defaults to the time the report is received
+}
+
+// TrafficIngestRequest is a batch of counter readings
+type TrafficIngestRequest struct {
+	Reports []TrafficReport `json:"reports" binding:"required"`
+}
+
+// TrafficIngestResult reports how a batch of readings was applied
+type TrafficIngestResult struct {
+	Accepted int      `json:"accepted"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// TrafficPoint is the traffic in one step of a series
+type TrafficPoint struct {
+	Time    time.Time `json:"time"` // Start of the step
+	RxBytes int64     `json:"rx_bytes"`
+	TxBytes int64     `json:"tx_bytes"`
+	RxRate  float64   `json:"rx_rate"` // Bytes per second
+	TxRate  float64   `json:"tx_rate"` // Bytes per second
+}
+
+// TrafficSeries is the traffic of a client or cluster over time
+type TrafficSeries struct {
+	Cluster    string         `json:"cluster"`
+	Identity   string         `json:"identity,omitempty"` // Empty for a whole cluster
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Step       string         `json:"step"`
+	Resolution string         `json:"resolution"` // Stored resolution the series was built from
+	RxBytes    int64          `json:"rx_bytes"`   // Total over the series
+	TxBytes    int64          `json:"tx_bytes"`   // Total over the series
+	Points     []TrafficPoint `json:"points"`
+}
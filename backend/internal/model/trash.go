@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// TrashEntry holds a deleted client until it is restored or purged. Its IP
+// stays reserved in the meantime.
+type TrashEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Cluster   string    `gorm:"index;not null" json:"cluster"`
+	Identity  string    `gorm:"index;not null" json:"identity"`
+	Name      string    `json:"name,omitempty"`
+	DeletedAt time.Time `gorm:"index;not null" json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by"`
+	Source    string    `json:"source"`
+	Client    Client    `gorm:"type:text;serializer:json" json:"client"`
+}
+
+// TableName specifies the table name for GORM
+func (TrashEntry) TableName() string {
+	return "trash"
+}
+
+// TrashFilter narrows down a trash query
+type TrashFilter struct {
+	Cluster  string
+	Identity string
+}
@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// User is a dashboard account. Only a bcrypt hash of the password is stored.
+type User struct {
+	ID           uint   `gorm:"primarykey" json:"id"`
+	Username     string `gorm:"uniqueIndex;size:64;not null" json:"username"`
+	DisplayName  string `json:"display_name,omitempty"`
+	PasswordHash string `gorm:"not null" json:"-"`
+
+	// MustChangePassword restricts the account to changing its password,
+	// e.g. for the default account created from config.yaml
+	MustChangePassword bool `json:"must_change_password"`
+	Disabled           bool `json:"disabled"`
+
+	// Roles granted globally or per cluster
+	Roles []RoleBinding `gorm:"type:text;serializer:json" json:"roles"`
+
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+	LastLoginAt       *time.Time `json:"last_login_at,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (User) TableName() string {
+	return "users"
+}
+
+// CreateUserRequest is the body of a user creation request
+type CreateUserRequest struct {
+	Username    string `json:"username" binding:"required"`
+	DisplayName string `json:"display_name"`
+	Password    string `json:"password" binding:"required"`
+
+	// MustChangePassword defaults to true so the new user picks their own
+	// password on first login
+	MustChangePassword *bool `json:"must_change_password"`
+
+	// Roles default to viewer of all clusters
+	Roles []RoleBinding `json:"roles"`
+}
+
+// UpdateUserRequest is the body of a user update request. Omitted fields
+// are left unchanged.
+type UpdateUserRequest struct {
+	DisplayName        *string `json:"display_name"`
+	Password           *string `json:"password"` // Resets the password
+	MustChangePassword *bool   `json:"must_change_password"`
+	Disabled           *bool   `json:"disabled"`
+
+	// Roles replaces all role bindings of the user
+	Roles *[]RoleBinding `json:"roles"`
+}
+
+// ChangePasswordRequest is the body of a request to change one's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// LoginRequest is the body of a login request
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
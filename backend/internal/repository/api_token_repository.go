@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// APITokenRepository defines the interface for storage of API tokens
+type APITokenRepository interface {
+	// Create stores a new token and sets its ID
+	Create(token *model.APIToken) error
+
+	// Update replaces an existing token
+	Update(token model.APIToken) error
+
+	// Get returns a single token by ID
+	Get(id uint) (*model.APIToken, error)
+
+	// GetByHash returns the token with the given hash
+	GetByHash(hash string) (*model.APIToken, error)
+
+	// List returns the tokens of a user, newest first
+	List(userID uint) ([]model.APIToken, error)
+
+	// Touch records a use of a token
+	Touch(id uint, usedAt time.Time, ip string) error
+
+	// DeleteByUser removes all tokens of a user
+	DeleteByUser(userID uint) error
+}
+
+// FileAPITokenRepository implements APITokenRepository using a JSON file
+type FileAPITokenRepository struct {
+	store *jsonStore[fileAPIToken]
+}
+
+// fileAPIToken stores the token hash, which model.APIToken leaves out of its
+// JSON
+type fileAPIToken struct {
+	model.APIToken
+	TokenHash string `json:"token_hash"`
+}
+
+// NewFileAPITokenRepository creates a new file-based API token repository.
+// The file holds token hashes, so it is only readable by its owner.
+func NewFileAPITokenRepository(filePath string) *FileAPITokenRepository {
+	store := newJSONStore[fileAPIToken](filePath)
+	store.perm = 0600
+	return &FileAPITokenRepository{
+		store: store,
+	}
+}
+
+func newFileAPIToken(token model.APIToken) fileAPIToken {
+	return fileAPIToken{APIToken: token, TokenHash: token.TokenHash}
+}
+
+func (t fileAPIToken) token() model.APIToken {
+	token := t.APIToken
+	token.TokenHash = t.TokenHash
+	return token
+}
+
+// Create stores a new token and sets its ID
+func (r *FileAPITokenRepository) Create(token *model.APIToken) error {
+	return r.store.update(func(tokens []fileAPIToken) ([]fileAPIToken, error) {
+		var highest uint
+		for _, existing := range tokens {
+			highest = max(highest, existing.ID)
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		token.ID = id
+		return append(tokens, newFileAPIToken(*token)), nil
+	})
+}
+
+// Update replaces an existing token
+func (r *FileAPITokenRepository) Update(token model.APIToken) error {
+	return r.store.update(func(tokens []fileAPIToken) ([]fileAPIToken, error) {
+		for i := range tokens {
+			if tokens[i].ID == token.ID {
+				tokens[i] = newFileAPIToken(token)
+				return tokens, nil
+			}
+		}
+		return nil, fmt.Errorf("api token not found")
+	})
+}
+
+// Get returns a single token by ID
+func (r *FileAPITokenRepository) Get(id uint) (*model.APIToken, error) {
+	return r.find(func(token fileAPIToken) bool { return token.ID == id })
+}
+
+// GetByHash returns the token with the given hash
+func (r *FileAPITokenRepository) GetByHash(hash string) (*model.APIToken, error) {
+	return r.find(func(token fileAPIToken) bool { return token.TokenHash == hash })
+}
+
+func (r *FileAPITokenRepository) find(match func(fileAPIToken) bool) (*model.APIToken, error) {
+	tokens, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range tokens {
+		if match(stored) {
+			token := stored.token()
+			return &token, nil
+		}
+	}
+
+	return nil, fmt.Errorf("api token not found")
+}
+
+// List returns the tokens of a user, newest first
+func (r *FileAPITokenRepository) List(userID uint) ([]model.APIToken, error) {
+	tokens, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.APIToken, 0)
+	for _, stored := range tokens {
+		if stored.UserID == userID {
+			result = append(result, stored.token())
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// Touch records a use of a token
+func (r *FileAPITokenRepository) Touch(id uint, usedAt time.Time, ip string) error {
+	return r.store.update(func(tokens []fileAPIToken) ([]fileAPIToken, error) {
+		for i := range tokens {
+			if tokens[i].ID == id {
+				tokens[i].LastUsedAt = &usedAt
+				tokens[i].LastUsedIP = ip
+				return tokens, nil
+			}
+		}
+		return nil, fmt.Errorf("api token not found")
+	})
+}
+
+// DeleteByUser removes all tokens of a user
+func (r *FileAPITokenRepository) DeleteByUser(userID uint) error {
+	return r.store.update(func(tokens []fileAPIToken) ([]fileAPIToken, error) {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			if token.UserID != userID {
+				kept = append(kept, token)
+			}
+		}
+		return kept, nil
+	})
+}
+
+// DatabaseAPITokenRepository implements APITokenRepository using GORM
+type DatabaseAPITokenRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseAPITokenRepository creates a new database-based API token
+// repository
+func NewDatabaseAPITokenRepository(db *gorm.DB) *DatabaseAPITokenRepository {
+	return &DatabaseAPITokenRepository{
+		db: db,
+	}
+}
+
+// Create stores a new token and sets its ID
+func (r *DatabaseAPITokenRepository) Create(token *model.APIToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create api token: %w", err)
+	}
+	return nil
+}
+
+// Update replaces an existing token
+func (r *DatabaseAPITokenRepository) Update(token model.APIToken) error {
+	if err := r.db.Save(&token).Error; err != nil {
+		return fmt.Errorf("failed to update api token: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single token by ID
+func (r *DatabaseAPITokenRepository) Get(id uint) (*model.APIToken, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+// GetByHash returns the token with the given hash
+func (r *DatabaseAPITokenRepository) GetByHash(hash string) (*model.APIToken, error) {
+	return r.find(r.db.Where("token_hash = ?", hash))
+}
+
+func (r *DatabaseAPITokenRepository) find(query *gorm.DB) (*model.APIToken, error) {
+	var token model.APIToken
+	if err := query.First(&token).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("api token not found")
+		}
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	return &token, nil
+}
+
+// List returns the tokens of a user, newest first
+func (r *DatabaseAPITokenRepository) List(userID uint) ([]model.APIToken, error) {
+	tokens := make([]model.APIToken, 0)
+	if err := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Touch records a use of a token. Only the usage columns are written so a
+// concurrent revocation is not undone.
+func (r *DatabaseAPITokenRepository) Touch(id uint, usedAt time.Time, ip string) error {
+	err := r.db.Model(&model.APIToken{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_used_at": usedAt,
+		"last_used_ip": ip,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record api token use: %w", err)
+	}
+	return nil
+}
+
+// DeleteByUser removes all tokens of a user
+func (r *DatabaseAPITokenRepository) DeleteByUser(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&model.APIToken{}).Error; err != nil {
+		return fmt.Errorf("failed to delete api tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// AuditRepository defines the interface for audit log storage
+type AuditRepository interface {
+	// Append stores a new audit entry
+	Append(entry model.AuditEntry) error
+
+	// List returns audit entries matching the filter, newest first
+	List(filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+// FileAuditRepository implements AuditRepository using a JSON file
+type FileAuditRepository struct {
+	store *jsonStore[model.AuditEntry]
+}
+
+// NewFileAuditRepository creates a new file-based audit repository
+func NewFileAuditRepository(filePath string) *FileAuditRepository {
+	return &FileAuditRepository{
+		store: newJSONStore[model.AuditEntry](filePath),
+	}
+}
+
+// Append stores a new audit entry
+func (r *FileAuditRepository) Append(entry model.AuditEntry) error {
+	return r.store.update(func(entries []model.AuditEntry) ([]model.AuditEntry, error) {
+		var highest uint
+		if len(entries) > 0 {
+			highest = entries[len(entries)-1].ID
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		entry.ID = id
+		return append(entries, entry), nil
+	})
+}
+
+// List returns audit entries matching the filter, newest first
+func (r *FileAuditRepository) List(filter model.AuditFilter) ([]model.AuditEntry, error) {
+	entries, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.AuditEntry, 0)
+	for _, entry := range entries {
+		if filter.Cluster != "" && entry.Cluster != filter.Cluster {
+			continue
+		}
+		if filter.Identity != "" && entry.Identity != filter.Identity {
+			continue
+		}
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+// DatabaseAuditRepository implements AuditRepository using GORM
+type DatabaseAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseAuditRepository creates a new database-based audit repository
+func NewDatabaseAuditRepository(db *gorm.DB) *DatabaseAuditRepository {
+	return &DatabaseAuditRepository{
+		db: db,
+	}
+}
+
+// Append stores a new audit entry
+func (r *DatabaseAuditRepository) Append(entry model.AuditEntry) error {
+	entry.ID = 0
+	if err := r.db.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// List returns audit entries matching the filter, newest first
+func (r *DatabaseAuditRepository) List(filter model.AuditFilter) ([]model.AuditEntry, error) {
+	query := r.db.Model(&model.AuditEntry{})
+	if filter.Cluster != "" {
+		query = query.Where("cluster = ?", filter.Cluster)
+	}
+	if filter.Identity != "" {
+		query = query.Where("identity = ?", filter.Identity)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	entries := make([]model.AuditEntry, 0)
+	if err := query.Order("id DESC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	return entries, nil
+}
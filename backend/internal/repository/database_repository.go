@@ -108,15 +108,13 @@ func (r *DatabaseRepository) Update(cluster, identity string, client model.Clien
 		client.Ciders = []string{}
 	}
 
-	// Update fields (keep cluster and identity unchanged)
-	updates := map[string]interface{}{
-		"private_ip": client.PrivateIP,
-		"mask":       client.Mask,
-		"gateway":    client.Gateway,
-		"ciders":     client.Ciders,
-	}
+	// Update fields (keep cluster and identity unchanged). Selecting the
+	// columns writes zero values too, and updating from a struct applies
+	// the labels serializer.
+	var updates model.ClientDB
+	updates.FromClient(client)
 
-	if err := r.db.Model(&dbClient).Updates(updates).Error; err != nil {
+	if err := r.db.Model(&dbClient).Select("name", "private_ip", "mask", "gateway", "ciders", "expires_at", "labels", "disabled").Updates(&updates).Error; err != nil {
 		return fmt.Errorf("failed to update client: %w", err)
 	}
 
@@ -173,3 +171,10 @@ func (r *DatabaseRepository) GetAllClusters() (map[string]int, error) {
 
 	return clusterMap, nil
 }
+
+// Transaction runs fn inside a database transaction
+func (r *DatabaseRepository) Transaction(fn func(tx RouteRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewDatabaseRepository(tx))
+	})
+}
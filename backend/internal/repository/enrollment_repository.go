@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// EnrollmentRepository defines the interface for storage of enrollment tokens
+type EnrollmentRepository interface {
+	// Create stores a new token and sets its ID
+	Create(token *model.EnrollmentToken) error
+
+	// Update replaces an existing token
+	Update(token model.EnrollmentToken) error
+
+	// Get returns a single token by ID
+	Get(id uint) (*model.EnrollmentToken, error)
+
+	// GetByHash returns the token with the given hash
+	GetByHash(hash string) (*model.EnrollmentToken, error)
+
+	// List returns the tokens of a cluster (or of all clusters if empty),
+	// newest first
+	List(cluster string) ([]model.EnrollmentToken, error)
+
+	// Claim marks an unused, unrevoked token as used, failing if it was
+	// used or revoked in the meantime
+	Claim(id uint, usedAt time.Time) error
+}
+
+// FileEnrollmentRepository implements EnrollmentRepository using a JSON file
+type FileEnrollmentRepository struct {
+	store *jsonStore[fileEnrollmentToken]
+}
+
+// fileEnrollmentToken stores the token hash, which model.EnrollmentToken
+// leaves out of its JSON
+type fileEnrollmentToken struct {
+	model.EnrollmentToken
+	TokenHash string `json:"token_hash"`
+}
+
+// NewFileEnrollmentRepository creates a new file-based enrollment repository
+func NewFileEnrollmentRepository(filePath string) *FileEnrollmentRepository {
+	return &FileEnrollmentRepository{
+		store: newJSONStore[fileEnrollmentToken](filePath),
+	}
+}
+
+func (t fileEnrollmentToken) token() model.EnrollmentToken {
+	token := t.EnrollmentToken
+	token.TokenHash = t.TokenHash
+	return token
+}
+
+// Create stores a new token and sets its ID
+func (r *FileEnrollmentRepository) Create(token *model.EnrollmentToken) error {
+	return r.store.update(func(tokens []fileEnrollmentToken) ([]fileEnrollmentToken, error) {
+		var highest uint
+		if len(tokens) > 0 {
+			highest = tokens[len(tokens)-1].ID
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		token.ID = id
+		return append(tokens, fileEnrollmentToken{EnrollmentToken: *token, TokenHash: token.TokenHash}), nil
+	})
+}
+
+// Update replaces an existing token
+func (r *FileEnrollmentRepository) Update(token model.EnrollmentToken) error {
+	return r.store.update(func(tokens []fileEnrollmentToken) ([]fileEnrollmentToken, error) {
+		for i := range tokens {
+			if tokens[i].ID == token.ID {
+				tokens[i] = fileEnrollmentToken{EnrollmentToken: token, TokenHash: token.TokenHash}
+				return tokens, nil
+			}
+		}
+		return nil, fmt.Errorf("enrollment token not found")
+	})
+}
+
+// Get returns a single token by ID
+func (r *FileEnrollmentRepository) Get(id uint) (*model.EnrollmentToken, error) {
+	return r.find(func(token fileEnrollmentToken) bool { return token.ID == id })
+}
+
+// GetByHash returns the token with the given hash
+func (r *FileEnrollmentRepository) GetByHash(hash string) (*model.EnrollmentToken, error) {
+	return r.find(func(token fileEnrollmentToken) bool { return token.TokenHash == hash })
+}
+
+func (r *FileEnrollmentRepository) find(match func(fileEnrollmentToken) bool) (*model.EnrollmentToken, error) {
+	tokens, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range tokens {
+		if match(stored) {
+			token := stored.token()
+			return &token, nil
+		}
+	}
+
+	return nil, fmt.Errorf("enrollment token not found")
+}
+
+// List returns the tokens of a cluster (or of all clusters if empty), newest
+// first
+func (r *FileEnrollmentRepository) List(cluster string) ([]model.EnrollmentToken, error) {
+	tokens, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.EnrollmentToken, 0)
+	for _, stored := range tokens {
+		if cluster == "" || stored.Cluster == cluster {
+			result = append(result, stored.token())
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// Claim marks an unused, unrevoked token as used
+func (r *FileEnrollmentRepository) Claim(id uint, usedAt time.Time) error {
+	return r.store.update(func(tokens []fileEnrollmentToken) ([]fileEnrollmentToken, error) {
+		for i := range tokens {
+			if tokens[i].ID != id {
+				continue
+			}
+			if tokens[i].UsedAt != nil || tokens[i].RevokedAt != nil {
+				return nil, fmt.Errorf("enrollment token already used or revoked")
+			}
+			tokens[i].UsedAt = &usedAt
+			return tokens, nil
+		}
+		return nil, fmt.Errorf("enrollment token not found")
+	})
+}
+
+// DatabaseEnrollmentRepository implements EnrollmentRepository using GORM
+type DatabaseEnrollmentRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseEnrollmentRepository creates a new database-based enrollment
+// repository
+func NewDatabaseEnrollmentRepository(db *gorm.DB) *DatabaseEnrollmentRepository {
+	return &DatabaseEnrollmentRepository{
+		db: db,
+	}
+}
+
+// Create stores a new token and sets its ID
+func (r *DatabaseEnrollmentRepository) Create(token *model.EnrollmentToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create enrollment token: %w", err)
+	}
+	return nil
+}
+
+// Update replaces an existing token
+func (r *DatabaseEnrollmentRepository) Update(token model.EnrollmentToken) error {
+	if err := r.db.Save(&token).Error; err != nil {
+		return fmt.Errorf("failed to update enrollment token: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single token by ID
+func (r *DatabaseEnrollmentRepository) Get(id uint) (*model.EnrollmentToken, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+// GetByHash returns the token with the given hash
+func (r *DatabaseEnrollmentRepository) GetByHash(hash string) (*model.EnrollmentToken, error) {
+	return r.find(r.db.Where("token_hash = ?", hash))
+}
+
+func (r *DatabaseEnrollmentRepository) find(query *gorm.DB) (*model.EnrollmentToken, error) {
+	var token model.EnrollmentToken
+	if err := query.First(&token).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("enrollment token not found")
+		}
+		return nil, fmt.Errorf("failed to get enrollment token: %w", err)
+	}
+	return &token, nil
+}
+
+// List returns the tokens of a cluster (or of all clusters if empty), newest
+// first
+func (r *DatabaseEnrollmentRepository) List(cluster string) ([]model.EnrollmentToken, error) {
+	query := r.db.Model(&model.EnrollmentToken{})
+	if cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+
+	tokens := make([]model.EnrollmentToken, 0)
+	if err := query.Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list enrollment tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Claim marks an unused, unrevoked token as used. The conditional update
+// makes concurrent claims of the same token fail.
+func (r *DatabaseEnrollmentRepository) Claim(id uint, usedAt time.Time) error {
+	result := r.db.Model(&model.EnrollmentToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return fmt.Errorf("failed to claim enrollment token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("enrollment token already used or revoked")
+	}
+	return nil
+}
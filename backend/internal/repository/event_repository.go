@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// EventRepository defines the interface for storage of server events
+type EventRepository interface {
+	// Append stores new events and sets their IDs
+	Append(events []model.Event) error
+
+	// List returns events matching the filter, newest first
+	List(filter model.EventFilter) ([]model.Event, error)
+
+	// Purge removes events that happened before the given time and returns
+	// how many were removed
+	Purge(before time.Time) (int, error)
+}
+
+// FileEventRepository implements EventRepository using a JSON file
+type FileEventRepository struct {
+	store *jsonStore[model.Event]
+}
+
+// NewFileEventRepository creates a new file-based event repository
+func NewFileEventRepository(filePath string) *FileEventRepository {
+	return &FileEventRepository{
+		store: newJSONStore[model.Event](filePath),
+	}
+}
+
+// Append stores new events and sets their IDs
+func (r *FileEventRepository) Append(events []model.Event) error {
+	return r.store.update(func(stored []model.Event) ([]model.Event, error) {
+		var highest uint
+		if len(stored) > 0 {
+			highest = stored[len(stored)-1].ID
+		}
+		first, err := r.store.nextIDs(highest, len(events))
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			events[i].ID = first + uint(i)
+		}
+		return append(stored, events...), nil
+	})
+}
+
+// List returns events matching the filter, newest first
+func (r *FileEventRepository) List(filter model.EventFilter) ([]model.Event, error) {
+	events, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Event, 0)
+	for _, event := range events {
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+// Purge removes events that happened before the given time and returns how
+// many were removed
+func (r *FileEventRepository) Purge(before time.Time) (int, error) {
+	purged := 0
+	err := r.store.update(func(events []model.Event) ([]model.Event, error) {
+		kept := events[:0]
+		for _, event := range events {
+			if event.Time.Before(before) {
+				purged++
+				continue
+			}
+			kept = append(kept, event)
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// DatabaseEventRepository implements EventRepository using GORM
+type DatabaseEventRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseEventRepository creates a new database-based event repository
+func NewDatabaseEventRepository(db *gorm.DB) *DatabaseEventRepository {
+	return &DatabaseEventRepository{
+		db: db,
+	}
+}
+
+// Append stores new events and sets their IDs
+func (r *DatabaseEventRepository) Append(events []model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.db.Create(&events).Error; err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
+
+// List returns events matching the filter, newest first
+func (r *DatabaseEventRepository) List(filter model.EventFilter) ([]model.Event, error) {
+	query := r.db.Model(&model.Event{})
+	if filter.Cluster != "" {
+		query = query.Where("cluster = ?", filter.Cluster)
+	}
+	if filter.Identity != "" {
+		query = query.Where("identity = ?", filter.Identity)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Since != nil {
+		query = query.Where("time >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("time < ?", *filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	events := make([]model.Event, 0)
+	if err := query.Order("id DESC").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Purge removes events that happened before the given time and returns how
+// many were removed
+func (r *DatabaseEventRepository) Purge(before time.Time) (int, error) {
+	result := r.db.Where("time < ?", before).Delete(&model.Event{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge events: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
@@ -3,60 +3,154 @@ package repository
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/smartethnet/rustun-dashboard/internal/model"
 )
 
-// FileRepository implements RouteRepository using JSON file storage
+// FileRepository implements RouteRepository using JSON file storage.
+// Enabled clients live in the routes file that rustun reads; disabled clients
+// are kept in a sidecar file next to it so they can be restored unchanged.
 type FileRepository struct {
-	filePath string
-	mu       sync.RWMutex
+	filePath     string
+	disabledPath string
+	mu           sync.RWMutex
 }
 
 // NewFileRepository creates a new file-based repository
 func NewFileRepository(filePath string) *FileRepository {
 	return &FileRepository{
-		filePath: filePath,
+		filePath:     filePath,
+		disabledPath: sidecarPath(filePath, "disabled"),
 	}
 }
 
-// loadRoutes reads and parses the routes file
+// sidecarPath derives the path of a companion file, e.g. routes.json ->
+// routes.disabled.json
+func sidecarPath(filePath, kind string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "." + kind + ext
+}
+
+// loadRoutes reads all clients under the read lock
 func (r *FileRepository) loadRoutes() ([]model.Client, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	return r.readRoutes()
+}
+
+// readRoutes reads and parses the routes file and the disabled clients file.
+// Callers must hold mu.
+func (r *FileRepository) readRoutes() ([]model.Client, error) {
 	data, err := os.ReadFile(r.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read routes file: %w", err)
 	}
 
-	var routes []model.Client
-	if err := json.Unmarshal(data, &routes); err != nil {
-		return nil, fmt.Errorf("failed to parse routes file: %w", err)
+	disabledData, err := os.ReadFile(r.disabledPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read disabled clients file: %w", err)
 	}
 
-	return routes, nil
+	return decodeRoutes(data, disabledData)
 }
 
-// saveRoutes writes routes to the file
-func (r *FileRepository) saveRoutes(routes []model.Client) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// decodeRoutes parses the contents of a routes file and of its disabled
+// clients file. A nil argument stands for a missing file.
+func decodeRoutes(data, disabledData []byte) ([]model.Client, error) {
+	routes := make([]model.Client, 0)
+	if data != nil {
+		if err := json.Unmarshal(data, &routes); err != nil {
+			return nil, fmt.Errorf("failed to parse routes file: %w", err)
+		}
+	}
+	for i := range routes {
+		routes[i].Enabled = true
+	}
+
+	if disabledData == nil {
+		return routes, nil
+	}
+
+	var disabled []model.Client
+	if err := json.Unmarshal(disabledData, &disabled); err != nil {
+		return nil, fmt.Errorf("failed to parse disabled clients file: %w", err)
+	}
+	for i := range disabled {
+		disabled[i].Enabled = false
+	}
+
+	return append(routes, disabled...), nil
+}
+
+// writeRoutes writes enabled clients to the routes file and disabled clients
+// to the sidecar file. The sidecar is written first and restored if the
+// routes file cannot be replaced, so a client moving between the two files
+// is never lost. Callers must hold mu for writing.
+func (r *FileRepository) writeRoutes(routes []model.Client) error {
+	enabled := make([]model.Client, 0, len(routes))
+	disabled := make([]model.Client, 0)
+	for _, client := range routes {
+		if client.Enabled {
+			enabled = append(enabled, client)
+		} else {
+			disabled = append(disabled, client)
+		}
+	}
 
-	data, err := json.MarshalIndent(routes, "", "  ")
+	data, err := json.MarshalIndent(enabled, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal routes: %w", err)
 	}
 
-	if err := os.WriteFile(r.filePath, data, 0644); err != nil {
+	previous, err := os.ReadFile(r.disabledPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read disabled clients file: %w", err)
+	}
+	sidecarExists := err == nil
+
+	// Avoid creating the sidecar file until a client is actually disabled
+	writeSidecar := sidecarExists || len(disabled) > 0
+	if writeSidecar {
+		disabledData, err := json.MarshalIndent(disabled, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal disabled clients: %w", err)
+		}
+
+		if err := writeFileAtomic(r.disabledPath, disabledData, 0644); err != nil {
+			return fmt.Errorf("failed to write disabled clients file: %w", err)
+		}
+	}
+
+	if err := writeFileAtomic(r.filePath, data, 0644); err != nil {
+		if writeSidecar {
+			r.restoreSidecar(previous, sidecarExists)
+		}
 		return fmt.Errorf("failed to write routes file: %w", err)
 	}
 
 	return nil
 }
 
+// restoreSidecar puts back the disabled clients file as it was before a
+// failed write
+func (r *FileRepository) restoreSidecar(previous []byte, existed bool) {
+	var err error
+	if existed {
+		err = writeFileAtomic(r.disabledPath, previous, 0644)
+	} else {
+		err = os.Remove(r.disabledPath)
+	}
+	if err != nil {
+		log.Printf("Failed to restore %s: %v", r.disabledPath, err)
+	}
+}
+
 // GetAll returns all clients
 func (r *FileRepository) GetAll() ([]model.Client, error) {
 	return r.loadRoutes()
@@ -69,13 +163,7 @@ func (r *FileRepository) GetByClusterAndIdentity(cluster, identity string) (*mod
 		return nil, err
 	}
 
-	for _, client := range routes {
-		if client.Cluster == cluster && client.Identity == identity {
-			return &client, nil
-		}
-	}
-
-	return nil, fmt.Errorf("client not found")
+	return NewMemoryRepository(routes).GetByClusterAndIdentity(cluster, identity)
 }
 
 // GetByCluster returns all clients in a cluster
@@ -85,114 +173,35 @@ func (r *FileRepository) GetByCluster(cluster string) ([]model.Client, error) {
 		return nil, err
 	}
 
-	clients := make([]model.Client, 0)
-	for _, client := range routes {
-		if client.Cluster == cluster {
-			clients = append(clients, client)
-		}
-	}
-
-	return clients, nil
+	return NewMemoryRepository(routes).GetByCluster(cluster)
 }
 
 // Create adds a new client
 func (r *FileRepository) Create(client model.Client) error {
-	routes, err := r.loadRoutes()
-	if err != nil {
-		return err
-	}
-
-	// Check if client already exists
-	for _, c := range routes {
-		if c.Cluster == client.Cluster && c.Identity == client.Identity {
-			return fmt.Errorf("client already exists")
-		}
-	}
-
-	// Initialize empty ciders if nil
-	if client.Ciders == nil {
-		client.Ciders = []string{}
-	}
-
-	routes = append(routes, client)
-	return r.saveRoutes(routes)
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Create(client)
+	})
 }
 
 // Update updates an existing client
 func (r *FileRepository) Update(cluster, identity string, updatedClient model.Client) error {
-	routes, err := r.loadRoutes()
-	if err != nil {
-		return err
-	}
-
-	found := false
-	for i, client := range routes {
-		if client.Cluster == cluster && client.Identity == identity {
-			// Keep the original cluster and identity
-			updatedClient.Cluster = cluster
-			updatedClient.Identity = identity
-			if updatedClient.Ciders == nil {
-				updatedClient.Ciders = []string{}
-			}
-			routes[i] = updatedClient
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("client not found")
-	}
-
-	return r.saveRoutes(routes)
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Update(cluster, identity, updatedClient)
+	})
 }
 
 // Delete removes a client
 func (r *FileRepository) Delete(cluster, identity string) error {
-	routes, err := r.loadRoutes()
-	if err != nil {
-		return err
-	}
-
-	newRoutes := make([]model.Client, 0)
-	found := false
-	for _, client := range routes {
-		if client.Cluster == cluster && client.Identity == identity {
-			found = true
-			continue
-		}
-		newRoutes = append(newRoutes, client)
-	}
-
-	if !found {
-		return fmt.Errorf("client not found")
-	}
-
-	return r.saveRoutes(newRoutes)
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Delete(cluster, identity)
+	})
 }
 
 // DeleteCluster removes all clients in a cluster
 func (r *FileRepository) DeleteCluster(cluster string) error {
-	routes, err := r.loadRoutes()
-	if err != nil {
-		return err
-	}
-
-	newRoutes := make([]model.Client, 0)
-	found := false
-	for _, client := range routes {
-		if client.Cluster != cluster {
-			newRoutes = append(newRoutes, client)
-		} else {
-			found = true
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("cluster not found")
-	}
-
-	return r.saveRoutes(newRoutes)
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.DeleteCluster(cluster)
+	})
 }
 
 // GetAllClusters returns all unique clusters with counts
@@ -202,10 +211,25 @@ func (r *FileRepository) GetAllClusters() (map[string]int, error) {
 		return nil, err
 	}
 
-	clusterMap := make(map[string]int)
-	for _, client := range routes {
-		clusterMap[client.Cluster]++
+	return NewMemoryRepository(routes).GetAllClusters()
+}
+
+// Transaction loads the routes, applies fn to an in-memory copy and writes
+// the result back in one step while holding the write lock, so concurrent
+// changes cannot interleave
+func (r *FileRepository) Transaction(fn func(tx RouteRepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes, err := r.readRoutes()
+	if err != nil {
+		return err
+	}
+
+	tx := NewMemoryRepository(routes)
+	if err := fn(tx); err != nil {
+		return err
 	}
 
-	return clusterMap, nil
+	return r.writeRoutes(tx.clients)
 }
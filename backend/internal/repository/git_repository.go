@@ -0,0 +1,584 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// gitCommitter is the committer of every commit made by the dashboard; the
+// author is the user who made the change
+const gitCommitter = "rustun-dashboard"
+
+// gitRemoteName is the name under which the configured remote is registered
+const gitRemoteName = "dashboard"
+
+var gitHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// GitOptions configures a GitRepository
+type GitOptions struct {
+	Remote      string        // Remote URL pushed to after every commit; empty disables pushing
+	Branch      string        // Branch to commit and push to
+	EmailDomain string        // Domain used to build author emails from usernames
+	Timeout     time.Duration // Limit for each git command, including pushes
+}
+
+// GitRepository decorates a file-backed RouteRepository whose routes file
+// lives in a git working tree. Every mutation is committed with a message
+// describing the change and the acting user as author; mutations that cannot
+// be committed are undone.
+type GitRepository struct {
+	inner   RouteRepository
+	dir     string
+	files   []string // Tracked files relative to dir
+	opts    GitOptions
+	actor   model.Actor
+	subject string    // Replaces the generated commit subject when set
+	state   *gitState // Shared between actor-scoped copies
+}
+
+type gitState struct {
+	mu sync.Mutex // Serializes changes to the working tree
+
+	// Pushes run in the background so a slow remote never holds up changes
+	pushes      chan struct{}
+	pushMu      sync.Mutex // Serializes pushes and guards their outcome
+	lastPush    *time.Time
+	lastPushErr string
+}
+
+// NewGitRepository wraps inner, initializing a git repository in the
+// directory of routesFile if there is none and committing any uncommitted
+// changes to the routes files
+func NewGitRepository(inner RouteRepository, routesFile string, opts GitOptions) (*GitRepository, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git storage requires the git binary: %w", err)
+	}
+
+	absPath, err := filepath.Abs(routesFile)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Branch == "" {
+		opts.Branch = "main"
+	}
+	if opts.EmailDomain == "" {
+		opts.EmailDomain = "rustun-dashboard.local"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Minute
+	}
+
+	r := &GitRepository{
+		inner: inner,
+		dir:   filepath.Dir(absPath),
+		files: []string{filepath.Base(absPath), filepath.Base(sidecarPath(absPath, "disabled"))},
+		opts:  opts,
+		actor: model.Actor{Name: gitCommitter, Source: model.SourceSystem},
+		state: &gitState{pushes: make(chan struct{}, 1)},
+	}
+
+	if err := r.init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize git repository in %s: %w", r.dir, err)
+	}
+	if opts.Remote != "" {
+		go r.pushInBackground()
+	}
+	return r, nil
+}
+
+// init makes sure dir is the root of a git repository with the configured
+// remote and commits the current routes files
+func (r *GitRepository) init() error {
+	top, err := r.git("rev-parse", "--show-toplevel")
+	if err != nil || filepath.Clean(top) != r.dir {
+		// Never commit into an enclosing repository; keep a dedicated one
+		if _, err := r.git("init", "-q"); err != nil {
+			return err
+		}
+		if _, err := r.git("symbolic-ref", "HEAD", "refs/heads/"+r.opts.Branch); err != nil {
+			return err
+		}
+	}
+
+	if r.opts.Remote != "" {
+		if _, err := r.git("remote", "get-url", gitRemoteName); err != nil {
+			_, err = r.git("remote", "add", gitRemoteName, r.opts.Remote)
+			if err != nil {
+				return err
+			}
+		} else if _, err := r.git("remote", "set-url", gitRemoteName, r.opts.Remote); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.commit("Sync routes configuration", r.actor)
+	return err
+}
+
+// WithActor returns a copy of the repository that commits as the given actor
+func (r *GitRepository) WithActor(actor model.Actor) RouteRepository {
+	scoped := *r
+	scoped.actor = actor
+	return &scoped
+}
+
+// WithSubject returns a copy of the repository whose commits use subject,
+// listing the individual changes in the body
+func (r *GitRepository) WithSubject(subject string) RouteRepository {
+	scoped := *r
+	scoped.subject = subject
+	return &scoped
+}
+
+// GetAll returns all clients
+func (r *GitRepository) GetAll() ([]model.Client, error) {
+	return r.inner.GetAll()
+}
+
+// GetByClusterAndIdentity returns a specific client
+func (r *GitRepository) GetByClusterAndIdentity(cluster, identity string) (*model.Client, error) {
+	return r.inner.GetByClusterAndIdentity(cluster, identity)
+}
+
+// GetByCluster returns all clients in a cluster
+func (r *GitRepository) GetByCluster(cluster string) ([]model.Client, error) {
+	return r.inner.GetByCluster(cluster)
+}
+
+// GetAllClusters returns all unique clusters with counts
+func (r *GitRepository) GetAllClusters() (map[string]int, error) {
+	return r.inner.GetAllClusters()
+}
+
+// Create adds a new client and commits it
+func (r *GitRepository) Create(client model.Client) error {
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Create(client)
+	})
+}
+
+// Update updates an existing client and commits it
+func (r *GitRepository) Update(cluster, identity string, client model.Client) error {
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Update(cluster, identity, client)
+	})
+}
+
+// Delete removes a client and commits it
+func (r *GitRepository) Delete(cluster, identity string) error {
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.Delete(cluster, identity)
+	})
+}
+
+// DeleteCluster removes all clients in a cluster and commits it
+func (r *GitRepository) DeleteCluster(cluster string) error {
+	return r.Transaction(func(tx RouteRepository) error {
+		return tx.DeleteCluster(cluster)
+	})
+}
+
+// Transaction runs fn against the wrapped repository and commits the result
+// as a single git commit. If the commit fails, the routes files are put back
+// as they were and the error is returned.
+func (r *GitRepository) Transaction(fn func(tx RouteRepository) error) error {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+
+	previous, err := r.readFiles()
+	if err != nil {
+		return err
+	}
+
+	recorder := &gitChangeRecorder{}
+	err = r.inner.Transaction(func(tx RouteRepository) error {
+		recorder.RouteRepository = tx
+		return fn(recorder)
+	})
+	if err != nil {
+		return err
+	}
+	if len(recorder.changes) == 0 {
+		return nil
+	}
+
+	if _, err := r.commit(gitMessage(r.subject, recorder.changes, r.actor), r.actor); err != nil {
+		r.restoreFiles(previous)
+		return fmt.Errorf("failed to commit the change to git, so it was not applied: %w", err)
+	}
+	return nil
+}
+
+// readFiles returns the contents of the routes files, nil for missing ones.
+// Callers must hold state.mu.
+func (r *GitRepository) readFiles() (map[string][]byte, error) {
+	contents := make(map[string][]byte, len(r.files))
+	for _, file := range r.files {
+		data, err := os.ReadFile(filepath.Join(r.dir, file))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		contents[file] = data
+	}
+	return contents, nil
+}
+
+// restoreFiles unstages the routes files and writes back the contents read
+// by readFiles. Callers must hold state.mu.
+func (r *GitRepository) restoreFiles(contents map[string][]byte) {
+	if _, err := r.git(append([]string{"reset", "-q", "--"}, r.files...)...); err != nil {
+		log.Printf("[Git] Failed to unstage the routes files: %v", err)
+	}
+
+	for file, data := range contents {
+		path := filepath.Join(r.dir, file)
+		var err error
+		if data == nil {
+			err = os.Remove(path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = writeFileAtomic(path, data, 0644)
+		}
+		if err != nil {
+			log.Printf("[Git] Failed to restore %s after a failed commit: %v", path, err)
+		}
+	}
+}
+
+// Log returns the most recent commits touching the routes files, newest first
+func (r *GitRepository) Log(limit int) ([]model.GitCommit, error) {
+	args := []string{"log", "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("-n%d", limit))
+	}
+	args = append(args, "--")
+	args = append(args, r.files...)
+
+	out, err := r.git(args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not have any commits") {
+			return []model.GitCommit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := make([]model.GitCommit, 0)
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) < 6 {
+			continue
+		}
+		when, _ := time.Parse(time.RFC3339, fields[3])
+		commits = append(commits, model.GitCommit{
+			Hash:        fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Time:        when,
+			Subject:     fields[4],
+			Body:        strings.TrimSpace(fields[5]),
+		})
+	}
+	return commits, nil
+}
+
+// CommitClients returns the clients as they were before and after a
+// commit, read from the routes files of its first parent and of the commit
+func (r *GitRepository) CommitClients(commit string) (before, after []model.Client, err error) {
+	if !gitHashPattern.MatchString(commit) {
+		return nil, nil, fmt.Errorf("invalid commit hash %q", commit)
+	}
+
+	hash, err := r.git("rev-parse", "--verify", "-q", commit+"^{commit}")
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown commit %s", commit)
+	}
+	if _, err := r.git("rev-parse", "--verify", "-q", hash+"^"); err != nil {
+		return nil, nil, fmt.Errorf("commit %s has no parent", commit)
+	}
+
+	if before, err = r.clientsAt(hash + "^"); err != nil {
+		return nil, nil, err
+	}
+	if after, err = r.clientsAt(hash); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// clientsAt reads the clients from the routes files at a revision. Files
+// missing at that revision hold no clients.
+func (r *GitRepository) clientsAt(rev string) ([]model.Client, error) {
+	contents := make([][]byte, len(r.files))
+	for i, file := range r.files {
+		listed, err := r.git("ls-tree", "--name-only", rev, "--", file)
+		if err != nil {
+			return nil, err
+		}
+		if listed == "" {
+			continue
+		}
+
+		data, err := r.git("show", rev+":"+file)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = []byte(data)
+	}
+
+	clients, err := decodeRoutes(contents[0], contents[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rev, err)
+	}
+	return clients, nil
+}
+
+// Push pushes the branch to the configured remote and waits for the result
+func (r *GitRepository) Push() error {
+	if r.opts.Remote == "" {
+		return fmt.Errorf("no git remote configured")
+	}
+
+	return r.push()
+}
+
+// Status reports the current head and the outcome of the last push
+func (r *GitRepository) Status() (*model.GitStatus, error) {
+	head, _ := r.git("rev-parse", "HEAD")
+
+	r.state.pushMu.Lock()
+	defer r.state.pushMu.Unlock()
+
+	return &model.GitStatus{
+		Dir:         r.dir,
+		Head:        head,
+		Remote:      r.opts.Remote,
+		Branch:      r.opts.Branch,
+		LastPush:    r.state.lastPush,
+		LastPushErr: r.state.lastPushErr,
+	}, nil
+}
+
+// commit stages the routes files and commits them if anything changed,
+// scheduling a push afterwards. It returns whether a commit was made. Callers must
+// hold state.mu, except during init.
+func (r *GitRepository) commit(message string, actor model.Actor) (bool, error) {
+	paths := make([]string, 0, len(r.files))
+	for _, file := range r.files {
+		if _, err := os.Stat(filepath.Join(r.dir, file)); err == nil {
+			paths = append(paths, file)
+		}
+	}
+	if len(paths) == 0 {
+		return false, nil
+	}
+
+	if _, err := r.git(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return false, err
+	}
+	if _, err := r.git("diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+
+	if _, err := r.gitAs(actor, "commit", "-q", "-m", message); err != nil {
+		return false, err
+	}
+	r.schedulePush()
+	return true, nil
+}
+
+// schedulePush asks the background pusher to push. Requests made while a
+// push is running are folded into a single follow-up push.
+func (r *GitRepository) schedulePush() {
+	if r.opts.Remote == "" {
+		return
+	}
+
+	select {
+	case r.state.pushes <- struct{}{}:
+	default:
+	}
+}
+
+// pushInBackground pushes whenever a push is scheduled. Failures are logged
+// and reported by Status; the next commit or a manual push tries again.
+func (r *GitRepository) pushInBackground() {
+	for range r.state.pushes {
+		if err := r.push(); err != nil {
+			log.Printf("[Git] Failed to push to %s: %v", r.opts.Remote, err)
+		}
+	}
+}
+
+// push pushes to the configured remote and remembers the outcome
+func (r *GitRepository) push() error {
+	r.state.pushMu.Lock()
+	defer r.state.pushMu.Unlock()
+
+	_, err := r.git("push", "-q", gitRemoteName, "HEAD:refs/heads/"+r.opts.Branch)
+
+	now := time.Now().UTC()
+	r.state.lastPush = &now
+	r.state.lastPushErr = ""
+	if err != nil {
+		r.state.lastPushErr = err.Error()
+	}
+	return err
+}
+
+// git runs a git command in the working tree as the dashboard
+func (r *GitRepository) git(args ...string) (string, error) {
+	return r.gitAs(model.Actor{Name: gitCommitter}, args...)
+}
+
+// gitAs runs a git command in the working tree with actor as author. The
+// command is killed once it exceeds the configured timeout, e.g. when a
+// remote does not answer.
+func (r *GitRepository) gitAs(actor model.Actor, args ...string) (string, error) {
+	name := actor.Name
+	if name == "" {
+		name = gitCommitter
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+name,
+		"GIT_AUTHOR_EMAIL="+name+"@"+r.opts.EmailDomain,
+		"GIT_COMMITTER_NAME="+gitCommitter,
+		"GIT_COMMITTER_EMAIL="+gitCommitter+"@"+r.opts.EmailDomain,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_SSH_COMMAND=ssh -o BatchMode=yes",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git %s: timed out after %s", args[0], r.opts.Timeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitMessage builds a commit message from the recorded changes, using
+// subject as the first line if set
+func gitMessage(subject string, changes []string, actor model.Actor) string {
+	var b strings.Builder
+	if subject == "" && len(changes) == 1 {
+		b.WriteString(changes[0])
+	} else {
+		if subject == "" {
+			subject = fmt.Sprintf("Apply %d changes", len(changes))
+		}
+		b.WriteString(subject + "\n\n")
+		for _, change := range changes {
+			b.WriteString("- " + change + "\n")
+		}
+	}
+
+	if actor.Source != "" {
+		b.WriteString("\n\nSource: " + actor.Source)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// gitChangeRecorder wraps a transaction and describes each mutation
+type gitChangeRecorder struct {
+	RouteRepository
+	changes []string
+}
+
+func (g *gitChangeRecorder) Create(client model.Client) error {
+	if err := g.RouteRepository.Create(client); err != nil {
+		return err
+	}
+	g.changes = append(g.changes, fmt.Sprintf("Create %s/%s%s", client.Cluster, client.Identity, gitClientName(client.Name)))
+	return nil
+}
+
+func (g *gitChangeRecorder) Update(cluster, identity string, client model.Client) error {
+	before, err := g.RouteRepository.GetByClusterAndIdentity(cluster, identity)
+	if err != nil {
+		return err
+	}
+	if err := g.RouteRepository.Update(cluster, identity, client); err != nil {
+		return err
+	}
+
+	fields := model.DiffClients(before, &client)
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) == 1 && fields[0].Field == "enabled" {
+		verb := "Disable"
+		if client.Enabled {
+			verb = "Enable"
+		}
+		g.changes = append(g.changes, fmt.Sprintf("%s %s/%s%s", verb, cluster, identity, gitClientName(client.Name)))
+		return nil
+	}
+
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Field
+	}
+	g.changes = append(g.changes, fmt.Sprintf("Update %s/%s%s: %s", cluster, identity, gitClientName(client.Name), strings.Join(names, ", ")))
+	return nil
+}
+
+func (g *gitChangeRecorder) Delete(cluster, identity string) error {
+	before, err := g.RouteRepository.GetByClusterAndIdentity(cluster, identity)
+	if err != nil {
+		return err
+	}
+	if err := g.RouteRepository.Delete(cluster, identity); err != nil {
+		return err
+	}
+	g.changes = append(g.changes, fmt.Sprintf("Delete %s/%s%s", cluster, identity, gitClientName(before.Name)))
+	return nil
+}
+
+func (g *gitChangeRecorder) DeleteCluster(cluster string) error {
+	clients, err := g.RouteRepository.GetByCluster(cluster)
+	if err != nil {
+		return err
+	}
+	if err := g.RouteRepository.DeleteCluster(cluster); err != nil {
+		return err
+	}
+	g.changes = append(g.changes, fmt.Sprintf("Delete cluster %s (%d clients)", cluster, len(clients)))
+	return nil
+}
+
+func (g *gitChangeRecorder) Transaction(fn func(tx RouteRepository) error) error {
+	return fn(g)
+}
+
+func gitClientName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", name)
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// jsonStore persists a slice of records as a JSON array in a single file.
+// It backs the file-based auxiliary repositories that live next to routes.json.
+type jsonStore[T any] struct {
+	path string
+	perm os.FileMode
+	mu   sync.Mutex
+}
+
+// newJSONStore creates a store for the given file path
+func newJSONStore[T any](path string) *jsonStore[T] {
+	return &jsonStore[T]{
+		path: path,
+		perm: 0644,
+	}
+}
+
+// load returns all stored records
+func (s *jsonStore[T]) load() ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+// update loads the records, applies fn and writes the result back
+func (s *jsonStore[T]) update(fn func(records []T) ([]T, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	records, err = fn(records)
+	if err != nil {
+		return err
+	}
+
+	return s.write(records)
+}
+
+// nextIDs reserves n consecutive record IDs and returns the first. IDs are
+// counted from a high-water mark kept in a sidecar file (e.g. trash.seq.json),
+// so the ID of a removed record is never handed out again; highest is the
+// largest stored ID, which seeds the mark for stores that predate it.
+// Callers must hold mu, i.e. call it from update.
+func (s *jsonStore[T]) nextIDs(highest uint, n int) (uint, error) {
+	seqPath := sidecarPath(s.path, "seq")
+
+	last := highest
+	data, err := os.ReadFile(seqPath)
+	if err == nil {
+		stored, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", filepath.Base(seqPath), err)
+		}
+		if uint(stored) > last {
+			last = uint(stored)
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read %s: %w", filepath.Base(seqPath), err)
+	}
+
+	mark := strconv.FormatUint(uint64(last)+uint64(n), 10) + "\n"
+	if err := writeFileAtomic(seqPath, []byte(mark), s.perm); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// read parses the file; a missing file is treated as an empty store
+func (s *jsonStore[T]) read() ([]T, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []T{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(s.path), err)
+	}
+
+	records := make([]T, 0)
+	if len(data) == 0 {
+		return records, nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(s.path), err)
+	}
+
+	return records, nil
+}
+
+// write replaces the file contents atomically
+func (s *jsonStore[T]) write(records []T) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(s.path), err)
+	}
+
+	return writeFileAtomic(s.path, data, s.perm)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to chmod %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// KeyRepository defines the interface for storage of cluster crypto keys
+type KeyRepository interface {
+	// Create stores a new key and sets its ID
+	Create(key *model.ClusterKey) error
+
+	// Update replaces existing keys, all or none
+	Update(keys ...model.ClusterKey) error
+
+	// Get returns a single key by ID
+	Get(id uint) (*model.ClusterKey, error)
+
+	// List returns the keys of a cluster (or of all clusters if empty),
+	// newest first
+	List(cluster string) ([]model.ClusterKey, error)
+}
+
+// FileKeyRepository implements KeyRepository using a JSON file
+type FileKeyRepository struct {
+	store *jsonStore[fileClusterKey]
+}
+
+// fileClusterKey stores the encrypted key, which model.ClusterKey leaves out
+// of its JSON
+type fileClusterKey struct {
+	model.ClusterKey
+	EncryptedKey string `json:"encrypted_key"`
+}
+
+// NewFileKeyRepository creates a new file-based key repository
+func NewFileKeyRepository(filePath string) *FileKeyRepository {
+	return &FileKeyRepository{
+		store: newJSONStore[fileClusterKey](filePath),
+	}
+}
+
+func (k fileClusterKey) key() model.ClusterKey {
+	key := k.ClusterKey
+	key.EncryptedKey = k.EncryptedKey
+	return key
+}
+
+// Create stores a new key and sets its ID
+func (r *FileKeyRepository) Create(key *model.ClusterKey) error {
+	return r.store.update(func(keys []fileClusterKey) ([]fileClusterKey, error) {
+		var highest uint
+		if len(keys) > 0 {
+			highest = keys[len(keys)-1].ID
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = id
+		return append(keys, fileClusterKey{ClusterKey: *key, EncryptedKey: key.EncryptedKey}), nil
+	})
+}
+
+// Update replaces existing keys, all or none
+func (r *FileKeyRepository) Update(updated ...model.ClusterKey) error {
+	return r.store.update(func(keys []fileClusterKey) ([]fileClusterKey, error) {
+		for _, key := range updated {
+			found := false
+			for i := range keys {
+				if keys[i].ID == key.ID {
+					keys[i] = fileClusterKey{ClusterKey: key, EncryptedKey: key.EncryptedKey}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("key not found")
+			}
+		}
+		return keys, nil
+	})
+}
+
+// Get returns a single key by ID
+func (r *FileKeyRepository) Get(id uint) (*model.ClusterKey, error) {
+	keys, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range keys {
+		if stored.ID == id {
+			key := stored.key()
+			return &key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("key not found")
+}
+
+// List returns the keys of a cluster (or of all clusters if empty), newest
+// first
+func (r *FileKeyRepository) List(cluster string) ([]model.ClusterKey, error) {
+	keys, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.ClusterKey, 0)
+	for _, stored := range keys {
+		if cluster == "" || stored.Cluster == cluster {
+			result = append(result, stored.key())
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// DatabaseKeyRepository implements KeyRepository using GORM
+type DatabaseKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseKeyRepository creates a new database-based key repository
+func NewDatabaseKeyRepository(db *gorm.DB) *DatabaseKeyRepository {
+	return &DatabaseKeyRepository{
+		db: db,
+	}
+}
+
+// Create stores a new key and sets its ID
+func (r *DatabaseKeyRepository) Create(key *model.ClusterKey) error {
+	if err := r.db.Create(key).Error; err != nil {
+		return fmt.Errorf("failed to create key: %w", err)
+	}
+	return nil
+}
+
+// Update replaces existing keys, all or none
+func (r *DatabaseKeyRepository) Update(keys ...model.ClusterKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			result := tx.Save(&key)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update key: %w", result.Error)
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns a single key by ID
+func (r *DatabaseKeyRepository) Get(id uint) (*model.ClusterKey, error) {
+	var key model.ClusterKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("key not found")
+		}
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	return &key, nil
+}
+
+// List returns the keys of a cluster (or of all clusters if empty), newest
+// first
+func (r *DatabaseKeyRepository) List(cluster string) ([]model.ClusterKey, error) {
+	query := r.db.Model(&model.ClusterKey{})
+	if cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+
+	keys := make([]model.ClusterKey, 0)
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	return keys, nil
+}
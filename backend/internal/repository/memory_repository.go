@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// MemoryRepository implements RouteRepository on an in-memory client list.
+// FileRepository uses it to apply changes before writing them out, and the
+// service uses it to preview changes without touching real storage.
+type MemoryRepository struct {
+	clients []model.Client
+}
+
+// NewMemoryRepository creates a repository holding a copy of the given clients
+func NewMemoryRepository(clients []model.Client) *MemoryRepository {
+	return &MemoryRepository{
+		clients: copyClients(clients),
+	}
+}
+
+// copyClients deep-copies a client list so callers cannot alias slices,
+// maps or pointers
+func copyClients(clients []model.Client) []model.Client {
+	copied := make([]model.Client, len(clients))
+	for i, client := range clients {
+		if client.Ciders != nil {
+			client.Ciders = append([]string{}, client.Ciders...)
+		}
+		if client.ExpiresAt != nil {
+			expiresAt := *client.ExpiresAt
+			client.ExpiresAt = &expiresAt
+		}
+		if client.Labels != nil {
+			labels := make(map[string]string, len(client.Labels))
+			for k, v := range client.Labels {
+				labels[k] = v
+			}
+			client.Labels = labels
+		}
+		copied[i] = client
+	}
+	return copied
+}
+
+// Clients returns a copy of the current client list
+func (r *MemoryRepository) Clients() []model.Client {
+	return copyClients(r.clients)
+}
+
+// GetAll returns all clients
+func (r *MemoryRepository) GetAll() ([]model.Client, error) {
+	return r.Clients(), nil
+}
+
+// GetByClusterAndIdentity returns a specific client
+func (r *MemoryRepository) GetByClusterAndIdentity(cluster, identity string) (*model.Client, error) {
+	for _, client := range r.clients {
+		if client.Cluster == cluster && client.Identity == identity {
+			found := copyClients([]model.Client{client})[0]
+			return &found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("client not found")
+}
+
+// GetByCluster returns all clients in a cluster
+func (r *MemoryRepository) GetByCluster(cluster string) ([]model.Client, error) {
+	clients := make([]model.Client, 0)
+	for _, client := range r.clients {
+		if client.Cluster == cluster {
+			clients = append(clients, client)
+		}
+	}
+
+	return copyClients(clients), nil
+}
+
+// Create adds a new client
+func (r *MemoryRepository) Create(client model.Client) error {
+	for _, c := range r.clients {
+		if c.Cluster == client.Cluster && c.Identity == client.Identity {
+			return fmt.Errorf("client already exists")
+		}
+	}
+
+	// Initialize empty ciders if nil
+	if client.Ciders == nil {
+		client.Ciders = []string{}
+	}
+
+	r.clients = append(r.clients, copyClients([]model.Client{client})...)
+	return nil
+}
+
+// Update updates an existing client
+func (r *MemoryRepository) Update(cluster, identity string, updatedClient model.Client) error {
+	for i, client := range r.clients {
+		if client.Cluster == cluster && client.Identity == identity {
+			// Keep the original cluster and identity
+			updatedClient.Cluster = cluster
+			updatedClient.Identity = identity
+			if updatedClient.Ciders == nil {
+				updatedClient.Ciders = []string{}
+			}
+			r.clients[i] = copyClients([]model.Client{updatedClient})[0]
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client not found")
+}
+
+// Delete removes a client
+func (r *MemoryRepository) Delete(cluster, identity string) error {
+	for i, client := range r.clients {
+		if client.Cluster == cluster && client.Identity == identity {
+			r.clients = append(r.clients[:i:i], r.clients[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client not found")
+}
+
+// DeleteCluster removes all clients in a cluster
+func (r *MemoryRepository) DeleteCluster(cluster string) error {
+	remaining := make([]model.Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		if client.Cluster != cluster {
+			remaining = append(remaining, client)
+		}
+	}
+
+	if len(remaining) == len(r.clients) {
+		return fmt.Errorf("cluster not found")
+	}
+
+	r.clients = remaining
+	return nil
+}
+
+// GetAllClusters returns all unique clusters with counts
+func (r *MemoryRepository) GetAllClusters() (map[string]int, error) {
+	clusterMap := make(map[string]int)
+	for _, client := range r.clients {
+		clusterMap[client.Cluster]++
+	}
+
+	return clusterMap, nil
+}
+
+// Transaction runs fn against a copy of the clients and keeps the changes
+// only if fn succeeds
+func (r *MemoryRepository) Transaction(fn func(tx RouteRepository) error) error {
+	tx := NewMemoryRepository(r.clients)
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.clients = tx.clients
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PresenceRepository defines the interface for storage of client heartbeats
+type PresenceRepository interface {
+	// Save stores the last heartbeat of a client, replacing the previous one
+	Save(presence model.Presence) error
+
+	// List returns the last heartbeats of the clients of a cluster (or of
+	// all clusters if empty)
+	List(cluster string) ([]model.Presence, error)
+}
+
+// FilePresenceRepository implements PresenceRepository using a JSON file
+type FilePresenceRepository struct {
+	store *jsonStore[model.Presence]
+}
+
+// NewFilePresenceRepository creates a new file-based presence repository
+func NewFilePresenceRepository(filePath string) *FilePresenceRepository {
+	return &FilePresenceRepository{
+		store: newJSONStore[model.Presence](filePath),
+	}
+}
+
+// Save stores the last heartbeat of a client, replacing the previous one
+func (r *FilePresenceRepository) Save(presence model.Presence) error {
+	return r.store.update(func(records []model.Presence) ([]model.Presence, error) {
+		for i := range records {
+			if records[i].Cluster == presence.Cluster && records[i].Identity == presence.Identity {
+				records[i] = presence
+				return records, nil
+			}
+		}
+		return append(records, presence), nil
+	})
+}
+
+// List returns the last heartbeats of the clients of a cluster (or of all
+// clusters if empty)
+func (r *FilePresenceRepository) List(cluster string) ([]model.Presence, error) {
+	records, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Presence, 0, len(records))
+	for _, presence := range records {
+		if cluster == "" || presence.Cluster == cluster {
+			result = append(result, presence)
+		}
+	}
+	return result, nil
+}
+
+// DatabasePresenceRepository implements PresenceRepository using GORM
+type DatabasePresenceRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabasePresenceRepository creates a new database-based presence
+// repository
+func NewDatabasePresenceRepository(db *gorm.DB) *DatabasePresenceRepository {
+	return &DatabasePresenceRepository{
+		db: db,
+	}
+}
+
+// Save stores the last heartbeat of a client, replacing the previous one
+func (r *DatabasePresenceRepository) Save(presence model.Presence) error {
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&presence).Error; err != nil {
+		return fmt.Errorf("failed to save heartbeat: %w", err)
+	}
+	return nil
+}
+
+// List returns the last heartbeats of the clients of a cluster (or of all
+// clusters if empty)
+func (r *DatabasePresenceRepository) List(cluster string) ([]model.Presence, error) {
+	query := r.db.Model(&model.Presence{})
+	if cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+
+	records := make([]model.Presence, 0)
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list heartbeats: %w", err)
+	}
+	return records, nil
+}
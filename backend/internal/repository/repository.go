@@ -27,4 +27,34 @@ type RouteRepository interface {
 
 	// GetAllClusters returns all unique clusters with counts
 	GetAllClusters() (map[string]int, error)
+
+	// Transaction runs fn against a transactional view of the repository.
+	// Changes made through tx are committed together if fn returns nil and
+	// discarded otherwise.
+	Transaction(fn func(tx RouteRepository) error) error
+}
+
+// ActorScoped is implemented by repositories that attribute the changes they
+// store to the acting user
+type ActorScoped interface {
+	// WithActor returns a view of the repository acting as actor
+	WithActor(actor model.Actor) RouteRepository
+}
+
+// GitHistory is implemented by repositories that keep their data in git
+type GitHistory interface {
+	// Log returns the most recent commits, newest first
+	Log(limit int) ([]model.GitCommit, error)
+
+	// CommitClients returns the clients before and after a commit
+	CommitClients(commit string) (before, after []model.Client, err error)
+
+	// WithSubject returns a view of the repository whose commits use subject
+	WithSubject(subject string) RouteRepository
+
+	// Push pushes to the configured remote
+	Push() error
+
+	// Status reports the current head and the last push
+	Status() (*model.GitStatus, error)
 }
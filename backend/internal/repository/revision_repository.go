@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// RevisionRepository defines the interface for client revision storage
+type RevisionRepository interface {
+	// Append stores new revisions in order
+	Append(revisions ...model.Revision) error
+
+	// Get returns a single revision by ID
+	Get(id uint) (*model.Revision, error)
+
+	// List returns revisions matching the filter, newest first
+	List(filter model.RevisionFilter) ([]model.Revision, error)
+}
+
+// FileRevisionRepository implements RevisionRepository using a JSON file
+type FileRevisionRepository struct {
+	store *jsonStore[model.Revision]
+}
+
+// NewFileRevisionRepository creates a new file-based revision repository
+func NewFileRevisionRepository(filePath string) *FileRevisionRepository {
+	return &FileRevisionRepository{
+		store: newJSONStore[model.Revision](filePath),
+	}
+}
+
+// Append stores new revisions in order
+func (r *FileRevisionRepository) Append(revisions ...model.Revision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	return r.store.update(func(existing []model.Revision) ([]model.Revision, error) {
+		var highest uint
+		if len(existing) > 0 {
+			highest = existing[len(existing)-1].ID
+		}
+		first, err := r.store.nextIDs(highest, len(revisions))
+		if err != nil {
+			return nil, err
+		}
+		for i, revision := range revisions {
+			revision.ID = first + uint(i)
+			revision.Changes = nil
+			existing = append(existing, revision)
+		}
+		return existing, nil
+	})
+}
+
+// Get returns a single revision by ID
+func (r *FileRevisionRepository) Get(id uint) (*model.Revision, error) {
+	revisions, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, revision := range revisions {
+		if revision.ID == id {
+			return &revision, nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision not found")
+}
+
+// List returns revisions matching the filter, newest first
+func (r *FileRevisionRepository) List(filter model.RevisionFilter) ([]model.Revision, error) {
+	revisions, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Revision, 0)
+	for _, revision := range revisions {
+		if filter.Cluster != "" && revision.Cluster != filter.Cluster {
+			continue
+		}
+		if filter.Identity != "" && revision.Identity != filter.Identity {
+			continue
+		}
+		if !filter.After.IsZero() && !revision.Time.After(filter.After) {
+			continue
+		}
+		result = append(result, revision)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+// DatabaseRevisionRepository implements RevisionRepository using GORM
+type DatabaseRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseRevisionRepository creates a new database-based revision repository
+func NewDatabaseRevisionRepository(db *gorm.DB) *DatabaseRevisionRepository {
+	return &DatabaseRevisionRepository{
+		db: db,
+	}
+}
+
+// Append stores new revisions in order
+func (r *DatabaseRevisionRepository) Append(revisions ...model.Revision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	for i := range revisions {
+		revisions[i].ID = 0
+	}
+	if err := r.db.Create(&revisions).Error; err != nil {
+		return fmt.Errorf("failed to write revisions: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single revision by ID
+func (r *DatabaseRevisionRepository) Get(id uint) (*model.Revision, error) {
+	var revision model.Revision
+	if err := r.db.First(&revision, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("revision not found")
+		}
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// List returns revisions matching the filter, newest first
+func (r *DatabaseRevisionRepository) List(filter model.RevisionFilter) ([]model.Revision, error) {
+	query := r.db.Model(&model.Revision{})
+	if filter.Cluster != "" {
+		query = query.Where("cluster = ?", filter.Cluster)
+	}
+	if filter.Identity != "" {
+		query = query.Where("identity = ?", filter.Identity)
+	}
+	if !filter.After.IsZero() {
+		query = query.Where("time > ?", filter.After)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	revisions := make([]model.Revision, 0)
+	if err := query.Order("id DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// ServerConfigRepository defines the interface for storage of the rustun
+// server configuration
+type ServerConfigRepository interface {
+	// Get returns the current configuration
+	Get() (*model.ServerConfig, error)
+
+	// Save replaces the configuration
+	Save(cfg model.ServerConfig) error
+}
+
+// cryptoKeys maps crypto modes to their key in the [crypto_config] table
+var cryptoKeys = map[string]string{
+	model.CryptoChaCha20:  "chacha20poly1305",
+	model.CryptoAES256GCM: "aes256gcm",
+	model.CryptoXOR:       "xor",
+}
+
+// FileServerConfigRepository implements ServerConfigRepository using the
+// server's TOML file. Sections and keys the dashboard does not manage are
+// kept when the file is rewritten.
+type FileServerConfigRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileServerConfigRepository creates a repository for the given server.toml
+func NewFileServerConfigRepository(path string) *FileServerConfigRepository {
+	return &FileServerConfigRepository{
+		path: path,
+	}
+}
+
+// Get returns the current configuration
+func (r *FileServerConfigRepository) Get() (*model.ServerConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("server config not found")
+	}
+
+	cfg := &model.ServerConfig{Path: r.path}
+	if server, ok := doc["server_config"].(map[string]interface{}); ok {
+		cfg.ListenAddr, _ = server["listen_addr"].(string)
+	}
+	if route, ok := doc["route_config"].(map[string]interface{}); ok {
+		cfg.RoutesFile, _ = route["routes_file"].(string)
+	}
+
+	switch crypto := doc["crypto_config"].(type) {
+	case string:
+		cfg.Crypto = crypto
+	case map[string]interface{}:
+		for mode, key := range cryptoKeys {
+			if value, ok := crypto[key].(string); ok {
+				cfg.Crypto = mode
+				cfg.CryptoKey = value
+				break
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Save replaces the configuration
+func (r *FileServerConfigRepository) Save(cfg model.ServerConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc, err := r.read()
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+
+	server, ok := doc["server_config"].(map[string]interface{})
+	if !ok {
+		server = make(map[string]interface{})
+	}
+	server["listen_addr"] = cfg.ListenAddr
+	doc["server_config"] = server
+
+	route, ok := doc["route_config"].(map[string]interface{})
+	if !ok {
+		route = make(map[string]interface{})
+	}
+	route["routes_file"] = cfg.RoutesFile
+	doc["route_config"] = route
+
+	if key, ok := cryptoKeys[cfg.Crypto]; ok {
+		doc["crypto_config"] = map[string]interface{}{key: cfg.CryptoKey}
+	} else {
+		doc["crypto_config"] = cfg.Crypto
+	}
+
+	data, err := toml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal server config: %w", err)
+	}
+
+	// The file holds the crypto key
+	return writeFileAtomic(r.path, data, 0600)
+}
+
+// read parses the file; a missing file is returned as nil
+func (r *FileServerConfigRepository) read() (map[string]interface{}, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read server config: %w", err)
+	}
+
+	doc := make(map[string]interface{})
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse server config: %w", err)
+	}
+	return doc, nil
+}
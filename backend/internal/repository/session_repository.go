@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// SessionRepository defines the interface for storage of login sessions
+type SessionRepository interface {
+	// Create stores a new session and sets its ID
+	Create(session *model.Session) error
+
+	// Get returns a single session by ID
+	Get(id uint) (*model.Session, error)
+
+	// GetByRefreshHash returns the session with the given refresh token hash
+	GetByRefreshHash(hash string) (*model.Session, error)
+
+	// List returns the sessions of a user, newest first
+	List(userID uint) ([]model.Session, error)
+
+	// Rotate replaces the refresh token hash of a session and extends it,
+	// failing if the hash changed in the meantime so a refresh token can
+	// only be used once
+	Rotate(id uint, oldHash, newHash string, refreshedAt, expiresAt time.Time) error
+
+	// Delete removes a session
+	Delete(id uint) error
+
+	// DeleteByUser removes all sessions of a user except the one with ID
+	// except, and returns how many were removed
+	DeleteByUser(userID, except uint) (int, error)
+
+	// DeleteExpired removes sessions that expired before the given time
+	DeleteExpired(before time.Time) error
+}
+
+// FileSessionRepository implements SessionRepository using a JSON file
+type FileSessionRepository struct {
+	store *jsonStore[fileSession]
+}
+
+// fileSession stores the refresh token hash, which model.Session leaves out
+// of its JSON
+type fileSession struct {
+	model.Session
+	RefreshHash string `json:"refresh_hash"`
+}
+
+// NewFileSessionRepository creates a new file-based session repository. The
+// file holds refresh token hashes, so it is only readable by its owner.
+func NewFileSessionRepository(filePath string) *FileSessionRepository {
+	store := newJSONStore[fileSession](filePath)
+	store.perm = 0600
+	return &FileSessionRepository{
+		store: store,
+	}
+}
+
+func newFileSession(session model.Session) fileSession {
+	return fileSession{Session: session, RefreshHash: session.RefreshHash}
+}
+
+func (s fileSession) session() model.Session {
+	session := s.Session
+	session.RefreshHash = s.RefreshHash
+	return session
+}
+
+// Create stores a new session and sets its ID
+func (r *FileSessionRepository) Create(session *model.Session) error {
+	return r.store.update(func(sessions []fileSession) ([]fileSession, error) {
+		var highest uint
+		for _, existing := range sessions {
+			highest = max(highest, existing.ID)
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		session.ID = id
+		return append(sessions, newFileSession(*session)), nil
+	})
+}
+
+// Get returns a single session by ID
+func (r *FileSessionRepository) Get(id uint) (*model.Session, error) {
+	return r.find(func(session fileSession) bool { return session.ID == id })
+}
+
+// GetByRefreshHash returns the session with the given refresh token hash
+func (r *FileSessionRepository) GetByRefreshHash(hash string) (*model.Session, error) {
+	return r.find(func(session fileSession) bool { return session.RefreshHash == hash })
+}
+
+func (r *FileSessionRepository) find(match func(fileSession) bool) (*model.Session, error) {
+	sessions, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range sessions {
+		if match(stored) {
+			session := stored.session()
+			return &session, nil
+		}
+	}
+
+	return nil, fmt.Errorf("session not found")
+}
+
+// List returns the sessions of a user, newest first
+func (r *FileSessionRepository) List(userID uint) ([]model.Session, error) {
+	sessions, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Session, 0)
+	for _, stored := range sessions {
+		if stored.UserID == userID {
+			result = append(result, stored.session())
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// Rotate replaces the refresh token hash of a session and extends it
+func (r *FileSessionRepository) Rotate(id uint, oldHash, newHash string, refreshedAt, expiresAt time.Time) error {
+	return r.store.update(func(sessions []fileSession) ([]fileSession, error) {
+		for i := range sessions {
+			if sessions[i].ID != id {
+				continue
+			}
+			if sessions[i].RefreshHash != oldHash {
+				return nil, fmt.Errorf("refresh token already used")
+			}
+			sessions[i].RefreshHash = newHash
+			sessions[i].RefreshedAt = refreshedAt
+			sessions[i].ExpiresAt = expiresAt
+			return sessions, nil
+		}
+		return nil, fmt.Errorf("session not found")
+	})
+}
+
+// Delete removes a session
+func (r *FileSessionRepository) Delete(id uint) error {
+	return r.store.update(func(sessions []fileSession) ([]fileSession, error) {
+		for i := range sessions {
+			if sessions[i].ID == id {
+				return append(sessions[:i], sessions[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("session not found")
+	})
+}
+
+// DeleteByUser removes all sessions of a user except one
+func (r *FileSessionRepository) DeleteByUser(userID, except uint) (int, error) {
+	removed := 0
+	err := r.store.update(func(sessions []fileSession) ([]fileSession, error) {
+		kept := sessions[:0]
+		for _, session := range sessions {
+			if session.UserID == userID && session.ID != except {
+				removed++
+				continue
+			}
+			kept = append(kept, session)
+		}
+		return kept, nil
+	})
+	return removed, err
+}
+
+// DeleteExpired removes sessions that expired before the given time
+func (r *FileSessionRepository) DeleteExpired(before time.Time) error {
+	return r.store.update(func(sessions []fileSession) ([]fileSession, error) {
+		kept := sessions[:0]
+		for _, session := range sessions {
+			if session.ExpiresAt.After(before) {
+				kept = append(kept, session)
+			}
+		}
+		return kept, nil
+	})
+}
+
+// DatabaseSessionRepository implements SessionRepository using GORM
+type DatabaseSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseSessionRepository creates a new database-based session
+// repository
+func NewDatabaseSessionRepository(db *gorm.DB) *DatabaseSessionRepository {
+	return &DatabaseSessionRepository{
+		db: db,
+	}
+}
+
+// Create stores a new session and sets its ID
+func (r *DatabaseSessionRepository) Create(session *model.Session) error {
+	if err := r.db.Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single session by ID
+func (r *DatabaseSessionRepository) Get(id uint) (*model.Session, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+// GetByRefreshHash returns the session with the given refresh token hash
+func (r *DatabaseSessionRepository) GetByRefreshHash(hash string) (*model.Session, error) {
+	return r.find(r.db.Where("refresh_hash = ?", hash))
+}
+
+func (r *DatabaseSessionRepository) find(query *gorm.DB) (*model.Session, error) {
+	var session model.Session
+	if err := query.First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &session, nil
+}
+
+// List returns the sessions of a user, newest first
+func (r *DatabaseSessionRepository) List(userID uint) ([]model.Session, error) {
+	sessions := make([]model.Session, 0)
+	if err := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Rotate replaces the refresh token hash of a session and extends it. The
+// conditional update makes concurrent uses of the same refresh token fail.
+func (r *DatabaseSessionRepository) Rotate(id uint, oldHash, newHash string, refreshedAt, expiresAt time.Time) error {
+	result := r.db.Model(&model.Session{}).
+		Where("id = ? AND refresh_hash = ?", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_hash": newHash,
+			"refreshed_at": refreshedAt,
+			"expires_at":   expiresAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to refresh session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("refresh token already used")
+	}
+	return nil
+}
+
+// Delete removes a session
+func (r *DatabaseSessionRepository) Delete(id uint) error {
+	result := r.db.Delete(&model.Session{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// DeleteByUser removes all sessions of a user except one
+func (r *DatabaseSessionRepository) DeleteByUser(userID, except uint) (int, error) {
+	result := r.db.Where("user_id = ? AND id <> ?", userID, except).Delete(&model.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// DeleteExpired removes sessions that expired before the given time
+func (r *DatabaseSessionRepository) DeleteExpired(before time.Time) error {
+	if err := r.db.Where("expires_at <= ?", before).Delete(&model.Session{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
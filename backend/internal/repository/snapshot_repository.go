@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// SnapshotRepository defines the interface for configuration snapshot storage
+type SnapshotRepository interface {
+	// Create stores a new snapshot and fills in its ID
+	Create(snapshot *model.Snapshot) error
+
+	// Get returns a snapshot including its clients
+	Get(id uint) (*model.Snapshot, error)
+
+	// List returns all snapshots without their clients, newest first
+	List() ([]model.Snapshot, error)
+
+	// Delete removes a snapshot
+	Delete(id uint) error
+}
+
+// FileSnapshotRepository implements SnapshotRepository using a JSON file
+type FileSnapshotRepository struct {
+	store *jsonStore[model.Snapshot]
+}
+
+// NewFileSnapshotRepository creates a new file-based snapshot repository
+func NewFileSnapshotRepository(filePath string) *FileSnapshotRepository {
+	return &FileSnapshotRepository{
+		store: newJSONStore[model.Snapshot](filePath),
+	}
+}
+
+// Create stores a new snapshot and fills in its ID
+func (r *FileSnapshotRepository) Create(snapshot *model.Snapshot) error {
+	return r.store.update(func(snapshots []model.Snapshot) ([]model.Snapshot, error) {
+		var highest uint
+		if len(snapshots) > 0 {
+			highest = snapshots[len(snapshots)-1].ID
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.ID = id
+		return append(snapshots, *snapshot), nil
+	})
+}
+
+// Get returns a snapshot including its clients
+func (r *FileSnapshotRepository) Get(id uint) (*model.Snapshot, error) {
+	snapshots, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return &snapshot, nil
+		}
+	}
+
+	return nil, fmt.Errorf("snapshot not found")
+}
+
+// List returns all snapshots without their clients, newest first
+func (r *FileSnapshotRepository) List() ([]model.Snapshot, error) {
+	snapshots, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Snapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshot.Clients = nil
+		result = append(result, snapshot)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// Delete removes a snapshot
+func (r *FileSnapshotRepository) Delete(id uint) error {
+	return r.store.update(func(snapshots []model.Snapshot) ([]model.Snapshot, error) {
+		for i, snapshot := range snapshots {
+			if snapshot.ID == id {
+				return append(snapshots[:i], snapshots[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("snapshot not found")
+	})
+}
+
+// DatabaseSnapshotRepository implements SnapshotRepository using GORM
+type DatabaseSnapshotRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseSnapshotRepository creates a new database-based snapshot repository
+func NewDatabaseSnapshotRepository(db *gorm.DB) *DatabaseSnapshotRepository {
+	return &DatabaseSnapshotRepository{
+		db: db,
+	}
+}
+
+// Create stores a new snapshot and fills in its ID
+func (r *DatabaseSnapshotRepository) Create(snapshot *model.Snapshot) error {
+	snapshot.ID = 0
+	if err := r.db.Create(snapshot).Error; err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return nil
+}
+
+// Get returns a snapshot including its clients
+func (r *DatabaseSnapshotRepository) Get(id uint) (*model.Snapshot, error) {
+	var snapshot model.Snapshot
+	if err := r.db.First(&snapshot, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("snapshot not found")
+		}
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// List returns all snapshots without their clients, newest first
+func (r *DatabaseSnapshotRepository) List() ([]model.Snapshot, error) {
+	snapshots := make([]model.Snapshot, 0)
+	if err := r.db.Omit("clients").Order("id DESC").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// Delete removes a snapshot
+func (r *DatabaseSnapshotRepository) Delete(id uint) error {
+	result := r.db.Delete(&model.Snapshot{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("snapshot not found")
+	}
+	return nil
+}
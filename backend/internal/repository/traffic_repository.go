@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// TrafficRepository defines the interface for storage of traffic time
+// series. Time series outgrow JSON files quickly, so there is only a
+// database implementation; file storage uses an embedded SQLite database.
+type TrafficRepository interface {
+	// Counters returns the last counter reading of every client
+	Counters() ([]model.TrafficCounter, error)
+
+	// Record stores new counter readings and adds samples to their
+	// buckets, all or none
+	Record(counters []model.TrafficCounter, samples []model.TrafficSample) error
+
+	// Query returns the samples of a resolution in [from, to) for a client,
+	// or for all clients of the cluster if identity is empty, oldest first
+	Query(cluster, identity, resolution string, from, to time.Time) ([]model.TrafficSample, error)
+
+	// Purge removes samples of a resolution older than the given time and
+	// returns how many were removed
+	Purge(resolution string, before time.Time) (int, error)
+}
+
+// DatabaseTrafficRepository implements TrafficRepository using GORM
+type DatabaseTrafficRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseTrafficRepository creates a new database-based traffic
+// repository
+func NewDatabaseTrafficRepository(db *gorm.DB) *DatabaseTrafficRepository {
+	return &DatabaseTrafficRepository{
+		db: db,
+	}
+}
+
+// Counters returns the last counter reading of every client
+func (r *DatabaseTrafficRepository) Counters() ([]model.TrafficCounter, error) {
+	counters := make([]model.TrafficCounter, 0)
+	if err := r.db.Find(&counters).Error; err != nil {
+		return nil, fmt.Errorf("failed to load traffic counters: %w", err)
+	}
+	return counters, nil
+}
+
+// Record stores new counter readings and adds samples to their buckets, all
+// or none
+func (r *DatabaseTrafficRepository) Record(counters []model.TrafficCounter, samples []model.TrafficSample) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, counter := range counters {
+			if err := tx.Save(&counter).Error; err != nil {
+				return fmt.Errorf("failed to save traffic counter: %w", err)
+			}
+		}
+
+		for _, sample := range samples {
+			// Add to an existing bucket, or start it
+			result := tx.Model(&model.TrafficSample{}).
+				Where("cluster = ? AND identity = ? AND resolution = ? AND time = ?", sample.Cluster, sample.Identity, sample.Resolution, sample.Time).
+				Updates(map[string]interface{}{
+					"rx_bytes": gorm.Expr("rx_bytes + ?", sample.RxBytes),
+					"tx_bytes": gorm.Expr("tx_bytes + ?", sample.TxBytes),
+				})
+			if result.Error != nil {
+				return fmt.Errorf("failed to update traffic sample: %w", result.Error)
+			}
+			if result.RowsAffected > 0 {
+				continue
+			}
+			if err := tx.Create(&sample).Error; err != nil {
+				return fmt.Errorf("failed to create traffic sample: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns the samples of a resolution in [from, to) for a client, or
+// for all clients of the cluster if identity is empty, oldest first
+func (r *DatabaseTrafficRepository) Query(cluster, identity, resolution string, from, to time.Time) ([]model.TrafficSample, error) {
+	query := r.db.Model(&model.TrafficSample{}).
+		Where("cluster = ? AND resolution = ? AND time >= ? AND time < ?", cluster, resolution, from, to)
+	if identity != "" {
+		query = query.Where("identity = ?", identity)
+	}
+
+	samples := make([]model.TrafficSample, 0)
+	if err := query.Order("time").Find(&samples).Error; err != nil {
+		return nil, fmt.Errorf("failed to query traffic: %w", err)
+	}
+	return samples, nil
+}
+
+// Purge removes samples of a resolution older than the given time and
+// returns how many were removed
+func (r *DatabaseTrafficRepository) Purge(resolution string, before time.Time) (int, error) {
+	result := r.db.Where("resolution = ? AND time < ?", resolution, before).Delete(&model.TrafficSample{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge traffic: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
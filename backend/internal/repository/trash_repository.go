@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// TrashRepository defines the interface for storage of deleted clients
+type TrashRepository interface {
+	// Add stores deleted clients and sets the IDs of the entries
+	Add(entries ...model.TrashEntry) error
+
+	// Get returns a single entry by ID
+	Get(id uint) (*model.TrashEntry, error)
+
+	// List returns entries matching the filter, most recently deleted first
+	List(filter model.TrashFilter) ([]model.TrashEntry, error)
+
+	// Remove deletes entries by ID
+	Remove(ids ...uint) error
+}
+
+// FileTrashRepository implements TrashRepository using a JSON file
+type FileTrashRepository struct {
+	store *jsonStore[model.TrashEntry]
+}
+
+// NewFileTrashRepository creates a new file-based trash repository
+func NewFileTrashRepository(filePath string) *FileTrashRepository {
+	return &FileTrashRepository{
+		store: newJSONStore[model.TrashEntry](filePath),
+	}
+}
+
+// Add stores deleted clients
+func (r *FileTrashRepository) Add(entries ...model.TrashEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return r.store.update(func(existing []model.TrashEntry) ([]model.TrashEntry, error) {
+		var highest uint
+		if len(existing) > 0 {
+			highest = existing[len(existing)-1].ID
+		}
+		first, err := r.store.nextIDs(highest, len(entries))
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entries[i].ID = first + uint(i)
+			existing = append(existing, entries[i])
+		}
+		return existing, nil
+	})
+}
+
+// Get returns a single entry by ID
+func (r *FileTrashRepository) Get(id uint) (*model.TrashEntry, error) {
+	entries, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("trash entry not found")
+}
+
+// List returns entries matching the filter, most recently deleted first
+func (r *FileTrashRepository) List(filter model.TrashFilter) ([]model.TrashEntry, error) {
+	entries, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.TrashEntry, 0)
+	for _, entry := range entries {
+		if filter.Cluster != "" && entry.Cluster != filter.Cluster {
+			continue
+		}
+		if filter.Identity != "" && entry.Identity != filter.Identity {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// Remove deletes entries by ID
+func (r *FileTrashRepository) Remove(ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	remove := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	return r.store.update(func(entries []model.TrashEntry) ([]model.TrashEntry, error) {
+		kept := make([]model.TrashEntry, 0, len(entries))
+		for _, entry := range entries {
+			if !remove[entry.ID] {
+				kept = append(kept, entry)
+			}
+		}
+		return kept, nil
+	})
+}
+
+// DatabaseTrashRepository implements TrashRepository using GORM
+type DatabaseTrashRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseTrashRepository creates a new database-based trash repository
+func NewDatabaseTrashRepository(db *gorm.DB) *DatabaseTrashRepository {
+	return &DatabaseTrashRepository{
+		db: db,
+	}
+}
+
+// Add stores deleted clients
+func (r *DatabaseTrashRepository) Add(entries ...model.TrashEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	for i := range entries {
+		entries[i].ID = 0
+	}
+	if err := r.db.Create(&entries).Error; err != nil {
+		return fmt.Errorf("failed to add to trash: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single entry by ID
+func (r *DatabaseTrashRepository) Get(id uint) (*model.TrashEntry, error) {
+	var entry model.TrashEntry
+	if err := r.db.First(&entry, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("trash entry not found")
+		}
+		return nil, fmt.Errorf("failed to get trash entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// List returns entries matching the filter, most recently deleted first
+func (r *DatabaseTrashRepository) List(filter model.TrashFilter) ([]model.TrashEntry, error) {
+	query := r.db.Model(&model.TrashEntry{})
+	if filter.Cluster != "" {
+		query = query.Where("cluster = ?", filter.Cluster)
+	}
+	if filter.Identity != "" {
+		query = query.Where("identity = ?", filter.Identity)
+	}
+
+	entries := make([]model.TrashEntry, 0)
+	if err := query.Order("id DESC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	return entries, nil
+}
+
+// Remove deletes entries by ID
+func (r *DatabaseTrashRepository) Remove(ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.db.Delete(&model.TrashEntry{}, ids).Error; err != nil {
+		return fmt.Errorf("failed to remove from trash: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"gorm.io/gorm"
+)
+
+// UserRepository defines the interface for storage of dashboard accounts
+type UserRepository interface {
+	// Create stores a new user and sets its ID, failing if the username is
+	// taken
+	Create(user *model.User) error
+
+	// Update replaces an existing user
+	Update(user model.User) error
+
+	// Delete removes a user
+	Delete(id uint) error
+
+	// Get returns a single user by ID
+	Get(id uint) (*model.User, error)
+
+	// GetByUsername returns the user with the given username, ignoring case
+	GetByUsername(username string) (*model.User, error)
+
+	// List returns all users ordered by username
+	List() ([]model.User, error)
+
+	// Count returns the number of users
+	Count() (int, error)
+}
+
+// FileUserRepository implements UserRepository using a JSON file
+type FileUserRepository struct {
+	store *jsonStore[fileUser]
+}
+
+// fileUser stores the password hash, which model.User leaves out of its JSON
+type fileUser struct {
+	model.User
+	PasswordHash string `json:"password_hash"`
+}
+
+// NewFileUserRepository creates a new file-based user repository. The file
+// holds password hashes, so it is only readable by its owner.
+func NewFileUserRepository(filePath string) *FileUserRepository {
+	store := newJSONStore[fileUser](filePath)
+	store.perm = 0600
+	return &FileUserRepository{
+		store: store,
+	}
+}
+
+func newFileUser(user model.User) fileUser {
+	return fileUser{User: user, PasswordHash: user.PasswordHash}
+}
+
+func (u fileUser) user() model.User {
+	user := u.User
+	user.PasswordHash = u.PasswordHash
+	return user
+}
+
+// Create stores a new user and sets its ID
+func (r *FileUserRepository) Create(user *model.User) error {
+	return r.store.update(func(users []fileUser) ([]fileUser, error) {
+		var highest uint
+		for _, existing := range users {
+			if strings.EqualFold(existing.Username, user.Username) {
+				return nil, fmt.Errorf("user already exists")
+			}
+			highest = max(highest, existing.ID)
+		}
+		id, err := r.store.nextIDs(highest, 1)
+		if err != nil {
+			return nil, err
+		}
+		user.ID = id
+		return append(users, newFileUser(*user)), nil
+	})
+}
+
+// Update replaces an existing user
+func (r *FileUserRepository) Update(user model.User) error {
+	return r.store.update(func(users []fileUser) ([]fileUser, error) {
+		for i := range users {
+			if users[i].ID == user.ID {
+				users[i] = newFileUser(user)
+				return users, nil
+			}
+		}
+		return nil, fmt.Errorf("user not found")
+	})
+}
+
+// Delete removes a user
+func (r *FileUserRepository) Delete(id uint) error {
+	return r.store.update(func(users []fileUser) ([]fileUser, error) {
+		for i := range users {
+			if users[i].ID == id {
+				return append(users[:i], users[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("user not found")
+	})
+}
+
+// Get returns a single user by ID
+func (r *FileUserRepository) Get(id uint) (*model.User, error) {
+	return r.find(func(user fileUser) bool { return user.ID == id })
+}
+
+// GetByUsername returns the user with the given username, ignoring case
+func (r *FileUserRepository) GetByUsername(username string) (*model.User, error) {
+	return r.find(func(user fileUser) bool { return strings.EqualFold(user.Username, username) })
+}
+
+func (r *FileUserRepository) find(match func(fileUser) bool) (*model.User, error) {
+	users, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range users {
+		if match(stored) {
+			user := stored.user()
+			return &user, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user not found")
+}
+
+// List returns all users ordered by username
+func (r *FileUserRepository) List() ([]model.User, error) {
+	users, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.User, 0, len(users))
+	for _, stored := range users {
+		result = append(result, stored.user())
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+
+	return result, nil
+}
+
+// Count returns the number of users
+func (r *FileUserRepository) Count() (int, error) {
+	users, err := r.store.load()
+	if err != nil {
+		return 0, err
+	}
+	return len(users), nil
+}
+
+// DatabaseUserRepository implements UserRepository using GORM
+type DatabaseUserRepository struct {
+	db *gorm.DB
+}
+
+// NewDatabaseUserRepository creates a new database-based user repository
+func NewDatabaseUserRepository(db *gorm.DB) *DatabaseUserRepository {
+	return &DatabaseUserRepository{
+		db: db,
+	}
+}
+
+// Create stores a new user and sets its ID
+func (r *DatabaseUserRepository) Create(user *model.User) error {
+	if _, err := r.GetByUsername(user.Username); err == nil {
+		return fmt.Errorf("user already exists")
+	}
+	if err := r.db.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// Update replaces an existing user
+func (r *DatabaseUserRepository) Update(user model.User) error {
+	if err := r.db.Save(&user).Error; err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user
+func (r *DatabaseUserRepository) Delete(id uint) error {
+	result := r.db.Delete(&model.User{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// Get returns a single user by ID
+func (r *DatabaseUserRepository) Get(id uint) (*model.User, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+// GetByUsername returns the user with the given username, ignoring case
+func (r *DatabaseUserRepository) GetByUsername(username string) (*model.User, error) {
+	return r.find(r.db.Where("LOWER(username) = ?", strings.ToLower(username)))
+}
+
+func (r *DatabaseUserRepository) find(query *gorm.DB) (*model.User, error) {
+	var user model.User
+	if err := query.First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// List returns all users ordered by username
+func (r *DatabaseUserRepository) List() ([]model.User, error) {
+	users := make([]model.User, 0)
+	if err := r.db.Order("username").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// Count returns the number of users
+func (r *DatabaseUserRepository) Count() (int, error) {
+	var count int64
+	if err := r.db.Model(&model.User{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return int(count), nil
+}
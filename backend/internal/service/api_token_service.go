@@ -0,0 +1,226 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ErrInvalidToken is returned for unknown, expired and revoked API tokens
+// and tokens of disabled accounts alike
+var ErrInvalidToken = errors.New("invalid or expired api token")
+
+const (
+	apiTokenPrefixLen = len(model.APITokenPrefix) + 8
+	maxAPITokenName   = 64
+
+	// apiTokenTouchInterval limits how often the last use of a token is
+	// written, so busy scripts do not write on every request
+	apiTokenTouchInterval = time.Minute
+)
+
+// APITokenService issues API tokens and checks the tokens requests carry
+type APITokenService struct {
+	repo         repository.APITokenRepository
+	userService  *UserService
+	auditService *AuditService
+}
+
+// NewAPITokenService creates a new API token service
+func NewAPITokenService(repo repository.APITokenRepository, userService *UserService, auditService *AuditService) *APITokenService {
+	return &APITokenService{
+		repo:         repo,
+		userService:  userService,
+		auditService: auditService,
+	}
+}
+
+// ListTokens returns the tokens of a user, newest first
+func (s *APITokenService) ListTokens(userID uint) ([]model.APIToken, error) {
+	tokens, err := s.repo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range tokens {
+		tokens[i].Status = tokens[i].StatusAt(now)
+	}
+	return tokens, nil
+}
+
+// CreateToken issues a token acting for owner. The returned plaintext token
+// cannot be retrieved again.
+func (s *APITokenService) CreateToken(actor string, owner *model.User, req model.APITokenRequest) (*model.APITokenCreated, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPITokenName {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrValidation, maxAPITokenName)
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := normalizeTokenClusters(req.Clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var expiresAt *time.Time
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("%w: invalid ttl %q: must be a positive duration such as 720h", ErrValidation, req.TTL)
+		}
+		expires := now.Add(ttl)
+		expiresAt = &expires
+	}
+
+	plaintext, err := newAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &model.APIToken{
+		UserID:    owner.ID,
+		Name:      name,
+		Prefix:    plaintext[:apiTokenPrefixLen],
+		TokenHash: hashAPIToken(plaintext),
+		Scopes:    scopes,
+		Clusters:  clusters,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+		CreatedBy: actor,
+	}
+	if err := s.repo.Create(token); err != nil {
+		return nil, err
+	}
+	token.Status = token.StatusAt(now)
+
+	s.auditService.Record(actor, "token.created", "", "", fmt.Sprintf("token %d (%s) %q of %s, scopes %s", token.ID, token.Prefix, token.Name, owner.Username, strings.Join(scopes, " ")))
+	return &model.APITokenCreated{APIToken: *token, Token: plaintext}, nil
+}
+
+// RevokeToken makes a token of the given user unusable
+func (s *APITokenService) RevokeToken(actor string, userID, id uint) (*model.APIToken, error) {
+	token, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	// Do not reveal tokens of other users
+	if token.UserID != userID {
+		return nil, fmt.Errorf("api token not found")
+	}
+
+	now := time.Now().UTC()
+	if token.RevokedAt == nil {
+		token.RevokedAt = &now
+		token.RevokedBy = actor
+		if err := s.repo.Update(*token); err != nil {
+			return nil, err
+		}
+		s.auditService.Record(actor, "token.revoked", "", "", fmt.Sprintf("token %d (%s) %q", token.ID, token.Prefix, token.Name))
+	}
+
+	token.Status = token.StatusAt(now)
+	return token, nil
+}
+
+// DeleteUserTokens removes all tokens of a user, e.g. when the account is
+// deleted
+func (s *APITokenService) DeleteUserTokens(userID uint) error {
+	return s.repo.DeleteByUser(userID)
+}
+
+// Authenticate returns the token with the given plaintext value and the
+// user it acts for, and records its use from ip
+func (s *APITokenService) Authenticate(plaintext, ip string) (*model.User, *model.APIToken, error) {
+	if !strings.HasPrefix(plaintext, model.APITokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	token, err := s.repo.GetByHash(hashAPIToken(plaintext))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	now := time.Now().UTC()
+	if token.StatusAt(now) != model.APITokenActive {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := s.userService.GetUser(token.UserID)
+	if err != nil || user.Disabled {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval || token.LastUsedIP != ip {
+		if err := s.repo.Touch(token.ID, now, ip); err != nil {
+			log.Printf("Failed to record use of api token %d: %v", token.ID, err)
+		}
+		token.LastUsedAt = &now
+		token.LastUsedIP = ip
+	}
+
+	token.Status = model.APITokenActive
+	return user, token, nil
+}
+
+// normalizeScopes checks the scopes of a token request and drops duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	result := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		if !model.ValidScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q (expected %s, %s, %s, %s or %s)", ErrValidation, scope,
+				model.ScopeClientsRead, model.ScopeClientsWrite, model.ScopeClustersManage, model.ScopeAgent, model.ScopeHeartbeat)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrValidation)
+	}
+	return result, nil
+}
+
+// normalizeTokenClusters checks the cluster restriction of a token request
+// and drops duplicates
+func normalizeTokenClusters(clusters []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	for _, cluster := range clusters {
+		cluster = strings.TrimSpace(cluster)
+		if cluster == "" {
+			return nil, fmt.Errorf("%w: cluster names must not be empty", ErrValidation)
+		}
+		if !seen[cluster] {
+			seen[cluster] = true
+			result = append(result, cluster)
+		}
+	}
+	return result, nil
+}
+
+func newAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return model.APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// AuditService records and queries administrative events
+type AuditService struct {
+	repo repository.AuditRepository
+}
+
+// NewAuditService creates a new audit service with the given repository
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{
+		repo: repo,
+	}
+}
+
+// Record appends an audit entry stamped with the current time.
+// Failures are logged rather than returned so auditing never blocks the
+// operation being audited.
+func (s *AuditService) Record(actor, action, cluster, identity, detail string) {
+	entry := model.AuditEntry{
+		Time:     time.Now().UTC(),
+		Actor:    actor,
+		Action:   action,
+		Cluster:  cluster,
+		Identity: identity,
+		Detail:   detail,
+	}
+
+	if err := s.repo.Append(entry); err != nil {
+		log.Printf("[Audit] Failed to record %s for %s/%s: %v", action, cluster, identity, err)
+	}
+}
+
+// List returns audit entries matching the filter, newest first
+func (s *AuditService) List(filter model.AuditFilter) ([]model.AuditEntry, error) {
+	return s.repo.List(filter)
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/smartethnet/rustun-dashboard/internal/ipadm"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ipAllocation remembers an IP handed out during a batch so it can be
+// returned to the pool if the batch rolls back
+type ipAllocation struct {
+	cluster string
+	ip      string
+}
+
+// batchState tracks IP changes made by operations inside a transaction.
+// Allocations are released on rollback; releases are deferred until commit
+// unless the IP is freed right away, in which case rollback reserves it
+// again.
+// Client changes are collected so they can be recorded as revisions once
+// the transaction commits. With the trash enabled, deleted clients keep
+// their IP and are moved to the trash on commit instead.
+type batchState struct {
+	ipManager *ipadm.IPAdmManager
+	allocated []ipAllocation
+	released  []ipAllocation
+	freed     []ipAllocation
+	changes   []model.ClientChange
+	comment   string // Attached to the recorded revisions
+	useTrash  bool
+	trashed   []model.Client
+	untrashed []uint // Trash entries restored by the transaction
+	reseed    bool   // Rebuild IP allocation from the stored clients on commit
+}
+
+func (b *batchState) allocate(cluster string) (*ipadm.AllocatedIP, error) {
+	allocated, err := b.ipManager.AllocateIP(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate IP: %w", err)
+	}
+	b.allocated = append(b.allocated, ipAllocation{cluster: cluster, ip: allocated.IP})
+	return allocated, nil
+}
+
+func (b *batchState) reserve(cluster, ip string) error {
+	if err := b.ipManager.ReserveIP(cluster, ip); err != nil {
+		return err
+	}
+	b.allocated = append(b.allocated, ipAllocation{cluster: cluster, ip: ip})
+	return nil
+}
+
+func (b *batchState) release(cluster, ip string) {
+	for _, f := range b.freed {
+		if f.cluster == cluster && f.ip == ip {
+			return
+		}
+	}
+	b.released = append(b.released, ipAllocation{cluster: cluster, ip: ip})
+}
+
+// free returns an IP to the pool right away so later operations of the
+// transaction can take it, e.g. when clients swap addresses
+func (b *batchState) free(cluster, ip string) {
+	b.ipManager.ReleaseIP(cluster, ip)
+	b.freed = append(b.freed, ipAllocation{cluster: cluster, ip: ip})
+}
+
+func (b *batchState) created(client *model.Client) {
+	after := *client
+	b.changes = append(b.changes, model.ClientChange{
+		Action:   model.ChangeCreate,
+		Cluster:  client.Cluster,
+		Identity: client.Identity,
+		Name:     client.Name,
+		After:    &after,
+	})
+}
+
+func (b *batchState) updated(before, after model.Client) {
+	b.changes = append(b.changes, model.ClientChange{
+		Action:   model.ChangeUpdate,
+		Cluster:  before.Cluster,
+		Identity: before.Identity,
+		Name:     after.Name,
+		Before:   &before,
+		After:    &after,
+	})
+}
+
+func (b *batchState) deleted(client *model.Client) {
+	before := *client
+	b.changes = append(b.changes, model.ClientChange{
+		Action:   model.ChangeDelete,
+		Cluster:  client.Cluster,
+		Identity: client.Identity,
+		Name:     client.Name,
+		Before:   &before,
+	})
+	if b.useTrash {
+		b.trashed = append(b.trashed, before)
+		return
+	}
+	b.release(client.Cluster, client.PrivateIP)
+}
+
+func (b *batchState) rollback() {
+	for _, a := range b.allocated {
+		b.ipManager.ReleaseIP(a.cluster, a.ip)
+	}
+	for _, f := range b.freed {
+		if err := b.ipManager.ReserveIP(f.cluster, f.ip); err != nil {
+			log.Printf("[Routes] Failed to reserve %s in %s again after a rollback: %v", f.ip, f.cluster, err)
+		}
+	}
+}
+
+func (b *batchState) commit() {
+	for _, r := range b.released {
+		b.ipManager.ReleaseIP(r.cluster, r.ip)
+	}
+}
+
+// transact runs fn inside a repository transaction. IPs allocated through
+// state are released if the transaction fails; IPs released through state
+// are returned to the pool only after it commits, when the collected client
+// changes are also recorded as revisions, the trash is updated and change
+// listeners are notified. If the revisions or the trash cannot be written
+// the committed changes are undone and an error is returned, so history
+// never misses a change and no deleted client is lost. Transactions run
+// one at a time, so IP bookkeeping always matches the stored clients.
+func (s *RouteService) transact(fn func(tx repository.RouteRepository, state *batchState) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &batchState{ipManager: s.ipManager, useTrash: s.trash != nil}
+	err := s.repo.Transaction(func(tx repository.RouteRepository) error {
+		return fn(tx, state)
+	})
+	if err != nil {
+		state.rollback()
+		return err
+	}
+
+	if err := s.recordRevisions(state.changes, state.comment); err != nil {
+		return s.undoCommitted(state, err, false)
+	}
+	if err := s.updateTrash(state.trashed, state.untrashed); err != nil {
+		return s.undoCommitted(state, err, true)
+	}
+
+	s.commitIPs(state)
+	s.notifyChange(state.changes)
+	return nil
+}
+
+// commitIPs applies the IP changes of a committed transaction, rebuilding
+// the allocation state from the stored clients if the transaction asked for
+// it. Callers must hold mu.
+func (s *RouteService) commitIPs(state *batchState) {
+	if !state.reseed {
+		state.commit()
+		return
+	}
+
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		log.Printf("[Routes] Failed to re-seed IP allocation: %v", err)
+		return
+	}
+	s.reseedIPs(clients)
+}
+
+// undoCommitted reverts the changes of a committed transaction whose
+// records could not be written and returns cause. If the changes were
+// already recorded as revisions, so is the undo. If the changes cannot be
+// reverted either, they are kept and treated as committed.
+func (s *RouteService) undoCommitted(state *batchState, cause error, recorded bool) error {
+	var undone []model.ClientChange
+	if err := s.repo.Transaction(func(tx repository.RouteRepository) error {
+		var err error
+		undone, err = undoChanges(tx, state.changes)
+		return err
+	}); err != nil {
+		log.Printf("[Routes] Failed to undo %d changes after %v: %v", len(state.changes), cause, err)
+		s.commitIPs(state)
+		s.notifyChange(state.changes)
+		return fmt.Errorf("%w; undoing the changes failed too: %v", cause, err)
+	}
+
+	state.rollback()
+	if recorded {
+		if err := s.recordRevisions(undone, "undo: "+cause.Error()); err != nil {
+			log.Printf("[History] Failed to record undo: %v", err)
+		}
+	}
+	return fmt.Errorf("%w; the changes were undone", cause)
+}
+
+// undoChanges reverts committed changes, newest first, and returns the
+// reverting changes. A client changed again in the meantime is left alone
+// and fails the undo.
+func undoChanges(tx repository.RouteRepository, changes []model.ClientChange) ([]model.ClientChange, error) {
+	undone := make([]model.ClientChange, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+
+		if change.After != nil {
+			current, err := tx.GetByClusterAndIdentity(change.Cluster, change.Identity)
+			if err != nil {
+				return nil, err
+			}
+			if len(model.DiffClients(current, change.After)) > 0 {
+				return nil, fmt.Errorf("client %s/%s was changed again", change.Cluster, change.Identity)
+			}
+		}
+
+		var err error
+		switch change.Action {
+		case model.ChangeCreate:
+			err = tx.Delete(change.Cluster, change.Identity)
+		case model.ChangeUpdate:
+			err = tx.Update(change.Cluster, change.Identity, *change.Before)
+		case model.ChangeDelete:
+			err = tx.Create(*change.Before)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to undo %s of %s/%s: %w", change.Action, change.Cluster, change.Identity, err)
+		}
+
+		reverse := change
+		reverse.Before, reverse.After = change.After, change.Before
+		switch change.Action {
+		case model.ChangeCreate:
+			reverse.Action = model.ChangeDelete
+		case model.ChangeDelete:
+			reverse.Action = model.ChangeCreate
+		}
+		undone = append(undone, reverse)
+	}
+
+	return undone, nil
+}
+
+// createInTx stores a new client. Clients without an identity get a new
+// UUID; clients without an address get one allocated, while clients with a
+// fixed address have it reserved.
+func createInTx(tx repository.RouteRepository, state *batchState, client model.Client) (*model.Client, error) {
+	if client.Identity == "" {
+		client.Identity = uuid.New().String()
+	}
+	if client.PrivateIP != "" {
+		if err := state.reserve(client.Cluster, client.PrivateIP); err != nil {
+			return nil, err
+		}
+	} else {
+		allocated, err := state.allocate(client.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		client.PrivateIP = allocated.IP
+		client.Gateway = allocated.Gateway
+		client.Mask = allocated.Mask
+	}
+
+	if err := tx.Create(client); err != nil {
+		return nil, err
+	}
+	state.created(&client)
+	return &client, nil
+}
+
+// updateInTx replaces a client, moving its IP reservation if the address
+// changed
+func updateInTx(tx repository.RouteRepository, state *batchState, before, after model.Client) error {
+	if after.PrivateIP != before.PrivateIP {
+		if err := state.reserve(after.Cluster, after.PrivateIP); err != nil {
+			return err
+		}
+		state.release(before.Cluster, before.PrivateIP)
+	}
+
+	if err := tx.Update(before.Cluster, before.Identity, after); err != nil {
+		return err
+	}
+	state.updated(before, after)
+	return nil
+}
+
+// deleteInTx removes a client and schedules its IP for release on commit
+func deleteInTx(tx repository.RouteRepository, state *batchState, cluster, identity string) error {
+	client, err := tx.GetByClusterAndIdentity(cluster, identity)
+	if err != nil {
+		return err
+	}
+	if err := tx.Delete(cluster, identity); err != nil {
+		return err
+	}
+	state.deleted(client)
+	return nil
+}
+
+// ApplyBatch runs create, update and delete operations all-or-nothing. If
+// any operation fails, nothing is stored, IPs allocated by the batch are
+// released and the returned result marks which operation failed.
+func (s *RouteService) ApplyBatch(ops []model.BatchOperation) (*model.BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operations provided")
+	}
+
+	result := &model.BatchResult{
+		Results: make([]model.BatchItemResult, len(ops)),
+	}
+	for i, op := range ops {
+		result.Results[i] = model.BatchItemResult{
+			Index:    i,
+			Op:       op.Op,
+			Cluster:  op.Cluster,
+			Identity: op.Identity,
+			Status:   model.BatchStatusSkipped,
+		}
+	}
+
+	err := s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		for i, op := range ops {
+			client, err := applyBatchOperation(tx, state, op)
+			if err != nil {
+				result.Results[i].Status = model.BatchStatusFailed
+				result.Results[i].Error = err.Error()
+				return fmt.Errorf("operation %d (%s) failed: %w", i, op.Op, err)
+			}
+
+			result.Results[i].Status = model.BatchStatusApplied
+			if client != nil {
+				result.Results[i].Identity = client.Identity
+				result.Results[i].Client = client
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		for i := range result.Results {
+			if result.Results[i].Status == model.BatchStatusApplied {
+				result.Results[i].Status = model.BatchStatusRolledBack
+			}
+		}
+		return result, err
+	}
+
+	result.Applied = true
+	return result, nil
+}
+
+// applyBatchOperation applies a single operation to the transaction and
+// returns the resulting client for create and update
+func applyBatchOperation(tx repository.RouteRepository, state *batchState, op model.BatchOperation) (*model.Client, error) {
+	if op.Cluster == "" {
+		return nil, fmt.Errorf("cluster is required")
+	}
+
+	switch op.Op {
+	case model.BatchOpCreate:
+		client := model.Client{
+			Cluster:   op.Cluster,
+			Identity:  op.Identity,
+			Ciders:    op.Ciders,
+			ExpiresAt: op.ExpiresAt,
+			Enabled:   true,
+		}
+		if op.Name != nil {
+			client.Name = *op.Name
+		}
+		if op.Enabled != nil {
+			client.Enabled = *op.Enabled
+		}
+		if err := validateClientFields(client); err != nil {
+			return nil, err
+		}
+
+		return createInTx(tx, state, client)
+
+	case model.BatchOpUpdate:
+		if op.Identity == "" {
+			return nil, fmt.Errorf("identity is required")
+		}
+
+		existing, err := tx.GetByClusterAndIdentity(op.Cluster, op.Identity)
+		if err != nil {
+			return nil, err
+		}
+		client := *existing
+		if op.Name != nil {
+			client.Name = *op.Name
+		}
+		if op.Ciders != nil {
+			client.Ciders = op.Ciders
+		}
+		if op.ExpiresAt != nil {
+			client.ExpiresAt = op.ExpiresAt
+		}
+		if op.Enabled != nil {
+			client.Enabled = *op.Enabled
+		}
+		if err := validateClientFields(client); err != nil {
+			return nil, err
+		}
+
+		if err := updateInTx(tx, state, *existing, client); err != nil {
+			return nil, err
+		}
+		return &client, nil
+
+	case model.BatchOpDelete:
+		if op.Identity == "" {
+			return nil, fmt.Errorf("identity is required")
+		}
+
+		return nil, deleteInTx(tx, state, op.Cluster, op.Identity)
+
+	default:
+		return nil, fmt.Errorf("unknown operation: %q", op.Op)
+	}
+}
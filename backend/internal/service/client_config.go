@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/clientconf"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// ClientConfigService renders ready-to-run rustun client configurations
+// from client records and per-cluster connect settings
+type ClientConfigService struct {
+	routeService *RouteService
+	keyService   *KeyService
+	settings     func(cluster string) model.ConnectSettings
+}
+
+// NewClientConfigService creates a client configuration service. settings
+// returns the connect settings of a cluster; its crypto setting is replaced
+// by the cluster's active key if keyService is set and has one.
+func NewClientConfigService(routeService *RouteService, keyService *KeyService, settings func(cluster string) model.ConnectSettings) *ClientConfigService {
+	return &ClientConfigService{
+		routeService: routeService,
+		keyService:   keyService,
+		settings:     settings,
+	}
+}
+
+// GetClientConfig returns the configuration a client needs to connect
+func (s *ClientConfigService) GetClientConfig(clusterName, identity string) (*model.ClientConfig, error) {
+	client, err := s.routeService.GetClient(clusterName, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := s.settings(client.Cluster)
+	if settings.Server == "" {
+		return nil, fmt.Errorf("no server address configured for cluster %s: set client_config.server", client.Cluster)
+	}
+
+	var nextCrypto string
+	if s.keyService != nil {
+		active, pending, err := s.keyService.ClusterCrypto(client.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		if active != "" {
+			settings.Crypto = active
+		}
+		nextCrypto = pending
+	}
+
+	ciders := client.Ciders
+	if ciders == nil {
+		ciders = []string{}
+	}
+	cfg := &model.ClientConfig{
+		Cluster:         client.Cluster,
+		Identity:        client.Identity,
+		Name:            client.Name,
+		PrivateIP:       client.PrivateIP,
+		Ciders:          ciders,
+		Enabled:         client.Enabled,
+		ConnectSettings: settings,
+		NextCrypto:      nextCrypto,
+	}
+	cfg.Command = clientconf.Command(cfg)
+	return cfg, nil
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/deploy"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// DeployTarget is a destination the routes document is published to
+type DeployTarget struct {
+	Name     string
+	Type     string
+	Clusters []string // Only publish these clusters; all if empty
+	Target   deploy.Target
+}
+
+// Deployer publishes the routes document to deployment targets after every
+// change. Deliveries run in the background; failed deliveries are retried
+// with exponential backoff.
+type Deployer struct {
+	repo          repository.RouteRepository
+	auditService  *AuditService
+	targets       []DeployTarget
+	timeout       time.Duration
+	retries       int
+	retryInterval time.Duration
+
+	mu     sync.Mutex // Guards status and dirty
+	status map[string]*model.DeployTargetStatus
+	dirty  map[string]bool // Targets with changes not yet delivered
+
+	deliverMu sync.Mutex // Serializes delivery rounds
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewDeployer creates a deployer. Each delivery may take up to timeout and
+// failed deliveries are retried up to retries times, first after
+// retryInterval and then with doubling delays.
+func NewDeployer(repo repository.RouteRepository, auditService *AuditService, targets []DeployTarget, timeout time.Duration, retries int, retryInterval time.Duration) *Deployer {
+	if retryInterval <= 0 {
+		retryInterval = 10 * time.Second
+	}
+
+	status := make(map[string]*model.DeployTargetStatus, len(targets))
+	dirty := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		status[target.Name] = &model.DeployTargetStatus{
+			Name:        target.Name,
+			Type:        target.Type,
+			Destination: target.Target.Destination(),
+			Clusters:    target.Clusters,
+			State:       model.DeployPending,
+		}
+		dirty[target.Name] = true
+	}
+
+	return &Deployer{
+		repo:          repo,
+		auditService:  auditService,
+		targets:       targets,
+		timeout:       timeout,
+		retries:       retries,
+		retryInterval: retryInterval,
+		status:        status,
+		dirty:         dirty,
+		wake:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start runs the delivery loop in the background, beginning with a delivery
+// to every target
+func (d *Deployer) Start() {
+	go func() {
+		defer close(d.done)
+
+		d.deliverDue()
+		for {
+			select {
+			case <-d.wake:
+				d.deliverDue()
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the delivery loop and waits for it to finish
+func (d *Deployer) Stop() {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+	<-d.done
+}
+
+// Notify schedules a delivery to every target. It can be registered with
+// RouteService.OnChange; changes made in quick succession are published
+// together.
+func (d *Deployer) Notify(changes []model.ClientChange) {
+	d.mu.Lock()
+	for _, target := range d.targets {
+		d.dirty[target.Name] = true
+		d.restart(d.status[target.Name])
+	}
+	d.mu.Unlock()
+
+	d.wakeUp()
+}
+
+// restart gives a target a fresh set of retries. Callers must hold mu.
+func (d *Deployer) restart(status *model.DeployTargetStatus) {
+	status.NextRetry = nil
+	if status.State == model.DeployFailed {
+		status.Attempts = 0
+	}
+}
+
+func (d *Deployer) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the delivery status of every target
+func (d *Deployer) Status() []model.DeployTargetStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statuses := make([]model.DeployTargetStatus, 0, len(d.targets))
+	for _, target := range d.targets {
+		statuses = append(statuses, *d.status[target.Name])
+	}
+	return statuses
+}
+
+// Sync delivers the current routes to a target, or to all targets if name
+// is empty, right away and returns the resulting status. Documents are
+// delivered even if they have not changed.
+func (d *Deployer) Sync(actor, name string) ([]model.DeployTargetStatus, error) {
+	targets := d.targets
+	if name != "" {
+		targets = nil
+		for _, target := range d.targets {
+			if target.Name == name {
+				targets = []DeployTarget{target}
+				break
+			}
+		}
+		if targets == nil {
+			return nil, fmt.Errorf("deploy target not found")
+		}
+	}
+
+	d.deliverMu.Lock()
+	defer d.deliverMu.Unlock()
+
+	clients, err := d.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	for _, target := range targets {
+		d.restart(d.status[target.Name])
+	}
+	d.mu.Unlock()
+	d.deliverAll(targets, clients, true)
+
+	statuses := make([]model.DeployTargetStatus, 0, len(targets))
+	failed := 0
+	d.mu.Lock()
+	for _, target := range targets {
+		status := *d.status[target.Name]
+		if status.State != model.DeploySynced {
+			failed++
+		}
+		statuses = append(statuses, status)
+	}
+	d.mu.Unlock()
+
+	d.auditService.Record(actor, "deploy.synced", "", "", fmt.Sprintf("%d targets re-synced, %d failed", len(targets), failed))
+	return statuses, nil
+}
+
+// deliverDue delivers to the targets with undelivered changes whose retry
+// delay has passed
+func (d *Deployer) deliverDue() {
+	d.deliverMu.Lock()
+	defer d.deliverMu.Unlock()
+
+	now := time.Now()
+	due := make([]DeployTarget, 0, len(d.targets))
+	d.mu.Lock()
+	for _, target := range d.targets {
+		next := d.status[target.Name].NextRetry
+		if d.dirty[target.Name] && (next == nil || !next.After(now)) {
+			due = append(due, target)
+		}
+	}
+	d.mu.Unlock()
+	if len(due) == 0 {
+		return
+	}
+
+	clients, err := d.repo.GetAll()
+	if err != nil {
+		log.Printf("[Deploy] Failed to load clients: %v", err)
+		time.AfterFunc(d.retryInterval, d.wakeUp)
+		return
+	}
+	d.deliverAll(due, clients, false)
+}
+
+// deliverAll delivers to the given targets in parallel. Unless forced,
+// targets already holding the same document are skipped.
+func (d *Deployer) deliverAll(targets []DeployTarget, clients []model.Client, force bool) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target DeployTarget) {
+			defer wg.Done()
+			d.deliver(target, clients, force)
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (d *Deployer) deliver(target DeployTarget, clients []model.Client, force bool) {
+	routes, count, err := deploy.Render(clients, target.Clusters)
+	if err == nil {
+		sum := sha256.Sum256(routes)
+		digest := hex.EncodeToString(sum[:])
+
+		d.mu.Lock()
+		status := d.status[target.Name]
+		unchanged := status.State == model.DeploySynced && status.Digest == digest
+		d.dirty[target.Name] = false
+		d.mu.Unlock()
+		if unchanged && !force {
+			return
+		}
+
+		ctx, cancel := context.Background(), func() {}
+		if d.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		}
+		err = target.Target.Deliver(ctx, routes)
+		cancel()
+
+		if err == nil {
+			now := time.Now().UTC()
+			d.mu.Lock()
+			status.State = model.DeploySynced
+			status.Clients = count
+			status.Digest = digest
+			status.Attempts = 0
+			status.LastAttempt = &now
+			status.LastSuccess = &now
+			status.LastError = ""
+			status.NextRetry = nil
+			d.mu.Unlock()
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := d.status[target.Name]
+	status.Attempts++
+	status.LastAttempt = &now
+	status.LastError = err.Error()
+	if status.Attempts > d.retries {
+		status.State = model.DeployFailed
+		status.NextRetry = nil
+		log.Printf("[Deploy] Delivery to %s failed after %d attempts: %v", target.Name, status.Attempts, err)
+		return
+	}
+
+	delay := d.retryInterval << (status.Attempts - 1)
+	next := now.Add(delay)
+	status.State = model.DeployRetrying
+	status.NextRetry = &next
+	d.dirty[target.Name] = true
+	log.Printf("[Deploy] Delivery to %s failed, retrying in %s: %v", target.Name, delay, err)
+	time.AfterFunc(delay, d.wakeUp)
+}
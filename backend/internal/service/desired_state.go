@@ -0,0 +1,220 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// PlanDesiredState computes the changes needed to make a cluster match the
+// desired client list without applying them
+func (s *RouteService) PlanDesiredState(cluster string, desired []model.DesiredClient) (*model.ClusterPlan, error) {
+	existing, err := s.repo.GetByCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := planDesiredState(cluster, existing, desired)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = true
+
+	return plan, nil
+}
+
+// ApplyDesiredState makes a cluster match the desired client list in a single
+// transaction: unmatched desired entries are created with newly allocated
+// IPs, matched ones are updated and clients missing from the list are deleted
+func (s *RouteService) ApplyDesiredState(cluster string, desired []model.DesiredClient) (*model.ClusterPlan, error) {
+	var plan *model.ClusterPlan
+
+	err := s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		// Plan inside the transaction so the diff matches what gets written
+		existing, err := tx.GetByCluster(cluster)
+		if err != nil {
+			return err
+		}
+
+		plan, err = planDesiredState(cluster, existing, desired)
+		if err != nil {
+			return err
+		}
+
+		return applyChanges(tx, state, plan.Changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Applied = true
+	return plan, nil
+}
+
+// applyChanges writes a list of planned changes to the transaction. Created
+// clients get their allocated IP and identity filled into the change.
+func applyChanges(tx repository.RouteRepository, state *batchState, changes []model.ClientChange) error {
+	for i := range changes {
+		change := &changes[i]
+
+		switch change.Action {
+		case model.ChangeCreate:
+			created, err := createInTx(tx, state, *change.After)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", describeChange(change), err)
+			}
+			change.After = created
+			change.Identity = created.Identity
+		case model.ChangeUpdate:
+			if err := updateInTx(tx, state, *change.Before, *change.After); err != nil {
+				return fmt.Errorf("failed to update %s: %w", describeChange(change), err)
+			}
+		case model.ChangeDelete:
+			if err := deleteInTx(tx, state, change.Cluster, change.Identity); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", describeChange(change), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func describeChange(change *model.ClientChange) string {
+	if change.Identity != "" {
+		return change.Cluster + "/" + change.Identity
+	}
+	return fmt.Sprintf("%s/%q", change.Cluster, change.Name)
+}
+
+// planDesiredState diffs the existing clients of a cluster against the
+// desired list
+func planDesiredState(cluster string, existing []model.Client, desired []model.DesiredClient) (*model.ClusterPlan, error) {
+	byIdentity := make(map[string]int, len(existing))
+	byName := make(map[string][]int)
+	for i, client := range existing {
+		byIdentity[client.Identity] = i
+		if client.Name != "" {
+			byName[client.Name] = append(byName[client.Name], i)
+		}
+	}
+
+	// Validate the entries up front so nothing invalid is planned and
+	// matching is unambiguous
+	seenIdentity := make(map[string]bool)
+	seenName := make(map[string]bool)
+	for i, d := range desired {
+		if d.Identity == "" && d.Name == "" {
+			return nil, fmt.Errorf("%w: client %d needs an identity or a name", ErrValidation, i)
+		}
+		if err := validateClientFields(desiredClient(model.Client{Cluster: cluster, Identity: d.Identity}, d)); err != nil {
+			return nil, fmt.Errorf("%w: client %d: %v", ErrValidation, i, err)
+		}
+		if d.Identity != "" {
+			if seenIdentity[d.Identity] {
+				return nil, fmt.Errorf("%w: duplicate identity %q", ErrValidation, d.Identity)
+			}
+			seenIdentity[d.Identity] = true
+			continue
+		}
+		if seenName[d.Name] {
+			return nil, fmt.Errorf("%w: duplicate name %q; add identities to tell the clients apart", ErrValidation, d.Name)
+		}
+		seenName[d.Name] = true
+	}
+
+	plan := &model.ClusterPlan{
+		Cluster: cluster,
+		Changes: make([]model.ClientChange, 0),
+	}
+	matched := make(map[int]bool, len(existing))
+
+	for _, d := range desired {
+		index := -1
+		if d.Identity != "" {
+			if i, ok := byIdentity[d.Identity]; ok {
+				index = i
+			}
+		} else {
+			candidates := make([]int, 0)
+			for _, i := range byName[d.Name] {
+				if !matched[i] && !seenIdentity[existing[i].Identity] {
+					candidates = append(candidates, i)
+				}
+			}
+			if len(candidates) > 1 {
+				return nil, fmt.Errorf("%w: name %q matches %d existing clients; add identities to tell them apart",
+					ErrValidation, d.Name, len(candidates))
+			}
+			if len(candidates) == 1 {
+				index = candidates[0]
+			}
+		}
+
+		if index < 0 {
+			after := desiredClient(model.Client{Cluster: cluster, Identity: d.Identity}, d)
+			plan.Changes = append(plan.Changes, model.ClientChange{
+				Action:   model.ChangeCreate,
+				Cluster:  cluster,
+				Identity: d.Identity,
+				Name:     d.Name,
+				After:    &after,
+			})
+			plan.Summary.Add(model.ChangeCreate)
+			continue
+		}
+
+		matched[index] = true
+		before := existing[index]
+		after := desiredClient(before, d)
+		fields := model.DiffClients(&before, &after)
+		if len(fields) == 0 {
+			plan.Summary.Unchanged++
+			continue
+		}
+
+		plan.Changes = append(plan.Changes, model.ClientChange{
+			Action:   model.ChangeUpdate,
+			Cluster:  cluster,
+			Identity: before.Identity,
+			Name:     after.Name,
+			Fields:   fields,
+			Before:   &before,
+			After:    &after,
+		})
+		plan.Summary.Add(model.ChangeUpdate)
+	}
+
+	for i := range existing {
+		if matched[i] {
+			continue
+		}
+		before := existing[i]
+		plan.Changes = append(plan.Changes, model.ClientChange{
+			Action:   model.ChangeDelete,
+			Cluster:  cluster,
+			Identity: before.Identity,
+			Name:     before.Name,
+			Before:   &before,
+		})
+		plan.Summary.Add(model.ChangeDelete)
+	}
+
+	return plan, nil
+}
+
+// desiredClient applies a desired definition on top of a base client,
+// keeping the base's identity and IP configuration
+func desiredClient(base model.Client, d model.DesiredClient) model.Client {
+	base.Name = d.Name
+	base.Ciders = d.Ciders
+	if base.Ciders == nil {
+		base.Ciders = []string{}
+	}
+	base.ExpiresAt = d.ExpiresAt
+	base.Enabled = true
+	if d.Enabled != nil {
+		base.Enabled = *d.Enabled
+	}
+	return base
+}
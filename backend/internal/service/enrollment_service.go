@@ -0,0 +1,254 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ErrInvalidEnrollment is returned for unknown, used, expired and revoked
+// enrollment tokens alike, so callers cannot probe which tokens exist. The
+// reason is recorded in the audit log.
+var ErrInvalidEnrollment = errors.New("invalid or expired enrollment token")
+
+const (
+	enrollmentTokenPrefix = "rtn_"
+	enrollmentPrefixLen   = len(enrollmentTokenPrefix) + 8
+	maxEnrollmentNameLen  = 64
+)
+
+// EnrollmentService issues one-time enrollment tokens and trades them for
+// new clients
+type EnrollmentService struct {
+	repo                repository.EnrollmentRepository
+	routeService        *RouteService
+	clientConfigService *ClientConfigService
+	auditService        *AuditService
+	defaultTTL          time.Duration
+	maxTTL              time.Duration
+	baseURL             string
+}
+
+// NewEnrollmentService creates a new enrollment service. Tokens live for
+// defaultTTL unless another lifetime up to maxTTL is requested. If baseURL,
+// the dashboard's public address, is set, new tokens come with an
+// enrollment URL.
+func NewEnrollmentService(repo repository.EnrollmentRepository, routeService *RouteService, clientConfigService *ClientConfigService, auditService *AuditService, defaultTTL, maxTTL time.Duration, baseURL string) *EnrollmentService {
+	return &EnrollmentService{
+		repo:                repo,
+		routeService:        routeService,
+		clientConfigService: clientConfigService,
+		auditService:        auditService,
+		defaultTTL:          defaultTTL,
+		maxTTL:              maxTTL,
+		baseURL:             strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// ListTokens returns the tokens of a cluster (or of all clusters if empty),
+// newest first
+func (s *EnrollmentService) ListTokens(clusterName string) ([]model.EnrollmentToken, error) {
+	tokens, err := s.repo.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range tokens {
+		tokens[i].Status = tokens[i].StatusAt(now)
+	}
+	return tokens, nil
+}
+
+// CreateToken issues a token for the cluster of the request. The returned
+// plaintext token cannot be retrieved again.
+func (s *EnrollmentService) CreateToken(actor string, req model.EnrollmentTokenRequest) (*model.EnrollmentTokenCreated, error) {
+	ttl := s.defaultTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("%w: invalid ttl %q: must be a positive duration such as 1h", ErrValidation, req.TTL)
+		}
+		ttl = parsed
+	}
+	if s.maxTTL > 0 && ttl > s.maxTTL {
+		return nil, fmt.Errorf("%w: ttl %s exceeds the maximum of %s", ErrValidation, ttl, s.maxTTL)
+	}
+
+	preset := model.Client{
+		Cluster: strings.TrimSpace(req.Cluster),
+		Name:    strings.TrimSpace(req.Name),
+		Ciders:  req.Ciders,
+		Labels:  req.Labels,
+	}
+	if err := validateClientFields(preset); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+
+	plaintext, err := newEnrollmentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	token := &model.EnrollmentToken{
+		Prefix:    plaintext[:enrollmentPrefixLen],
+		TokenHash: hashEnrollmentToken(plaintext),
+		Cluster:   preset.Cluster,
+		Name:      preset.Name,
+		Labels:    preset.Labels,
+		Ciders:    preset.Ciders,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		CreatedBy: actor,
+	}
+	if err := s.repo.Create(token); err != nil {
+		return nil, err
+	}
+	token.Status = token.StatusAt(now)
+
+	s.auditService.Record(actor, "enrollment.created", token.Cluster, "", fmt.Sprintf("token %d (%s), expires %s", token.ID, token.Prefix, token.ExpiresAt.Format(time.RFC3339)))
+	created := &model.EnrollmentTokenCreated{EnrollmentToken: *token, Token: plaintext}
+	if s.baseURL != "" {
+		created.EnrollURL = s.baseURL + "/api/enroll?token=" + url.QueryEscape(plaintext)
+	}
+	return created, nil
+}
+
+// GetToken returns a single token without its value
+func (s *EnrollmentService) GetToken(id uint) (*model.EnrollmentToken, error) {
+	token, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	token.Status = token.StatusAt(time.Now())
+	return token, nil
+}
+
+// RevokeToken makes an unused token unusable
+func (s *EnrollmentService) RevokeToken(actor string, id uint) (*model.EnrollmentToken, error) {
+	token, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if status := token.StatusAt(now); status == model.EnrollmentUsed || status == model.EnrollmentRevoked {
+		return nil, fmt.Errorf("%w: enrollment token %d is already %s", ErrValidation, id, status)
+	}
+
+	token.RevokedAt = &now
+	token.RevokedBy = actor
+	if err := s.repo.Update(*token); err != nil {
+		return nil, err
+	}
+	token.Status = token.StatusAt(now)
+
+	s.auditService.Record(actor, "enrollment.revoked", token.Cluster, "", fmt.Sprintf("token %d (%s)", token.ID, token.Prefix))
+	return token, nil
+}
+
+// Enroll trades a token for a new client with a generated identity and an
+// allocated IP, configured with the token's presets. remoteAddr is recorded
+// in the audit log.
+func (s *EnrollmentService) Enroll(req model.EnrollRequest, remoteAddr string) (*model.EnrollResult, error) {
+	plaintext := strings.TrimSpace(req.Token)
+	token, err := s.repo.GetByHash(hashEnrollmentToken(plaintext))
+	if err != nil {
+		if err.Error() == "enrollment token not found" {
+			// Unknown tokens are not audited to keep guessing out of the log
+			log.Printf("[Enrollment] Rejected unknown token from %s", remoteAddr)
+			return nil, ErrInvalidEnrollment
+		}
+		return nil, err
+	}
+
+	actor := "enroll:" + token.Prefix
+	reject := func(reason string) error {
+		s.auditService.Record(actor, "enrollment.rejected", token.Cluster, "", fmt.Sprintf("token %d from %s: %s", token.ID, remoteAddr, reason))
+		return ErrInvalidEnrollment
+	}
+
+	now := time.Now().UTC()
+	if status := token.StatusAt(now); status != model.EnrollmentActive {
+		return nil, reject("token " + status)
+	}
+	name := strings.TrimSpace(req.Name)
+	if len(name) > maxEnrollmentNameLen {
+		return nil, fmt.Errorf("%w: name must be at most %d characters", ErrValidation, maxEnrollmentNameLen)
+	}
+	if err := validateClientName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	if err := s.repo.Claim(token.ID, now); err != nil {
+		if strings.HasPrefix(err.Error(), "enrollment token already") {
+			return nil, reject("token already used")
+		}
+		return nil, err
+	}
+
+	client := model.Client{
+		Cluster: token.Cluster,
+		Name:    token.Name,
+		Ciders:  token.Ciders,
+		Labels:  token.Labels,
+	}
+	if client.Name == "" {
+		client.Name = name
+	}
+	if client.Ciders == nil {
+		client.Ciders = []string{}
+	}
+
+	created, err := s.routeService.WithActor(model.Actor{Name: actor, Source: model.SourceAPI}).CreateClient(client)
+	if err != nil {
+		// Give the token back so the enrollment can be retried
+		token.UsedAt = nil
+		if releaseErr := s.repo.Update(*token); releaseErr != nil {
+			log.Printf("[Enrollment] Failed to release token %d: %v", token.ID, releaseErr)
+		}
+		s.auditService.Record(actor, "enrollment.failed", token.Cluster, "", fmt.Sprintf("token %d from %s: %v", token.ID, remoteAddr, err))
+		return nil, err
+	}
+
+	token.UsedAt = &now
+	token.ClientIdentity = created.Identity
+	if err := s.repo.Update(*token); err != nil {
+		log.Printf("[Enrollment] Failed to record client of token %d: %v", token.ID, err)
+	}
+	s.auditService.Record(actor, "enrollment.used", created.Cluster, created.Identity, fmt.Sprintf("token %d from %s, ip %s", token.ID, remoteAddr, created.PrivateIP))
+
+	result := &model.EnrollResult{Client: *created}
+	cfg, err := s.clientConfigService.GetClientConfig(created.Cluster, created.Identity)
+	if err != nil {
+		// The client exists; an admin can hand out its configuration later
+		log.Printf("[Enrollment] Failed to render configuration of %s/%s: %v", created.Cluster, created.Identity, err)
+	} else {
+		result.Config = cfg
+	}
+	return result, nil
+}
+
+// newEnrollmentToken returns a random URL-safe token
+func newEnrollmentToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return enrollmentTokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashEnrollmentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
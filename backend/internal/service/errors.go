@@ -0,0 +1,7 @@
+package service
+
+import "errors"
+
+// ErrValidation marks errors caused by invalid input rather than by storage
+// failures; handlers map it to 400 Bad Request
+var ErrValidation = errors.New("validation failed")
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/logwatch"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// eventBuffer is how many events a slow subscriber may fall behind before
+// events are dropped for it
+const eventBuffer = 64
+
+// EventService ingests events from the rustun server log, stores them and
+// passes them on to live subscribers
+type EventService struct {
+	repo         repository.EventRepository
+	routeService *RouteService
+	tailer       *logwatch.Tailer
+	parser       *logwatch.Parser
+	retention    time.Duration
+
+	mu          sync.Mutex // Guards subscribers
+	subscribers map[chan model.Event]struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewEventService creates an event service reading lines from tailer.
+// Events older than retention are purged; a retention of 0 keeps them
+// forever.
+func NewEventService(repo repository.EventRepository, routeService *RouteService, tailer *logwatch.Tailer, parser *logwatch.Parser, retention time.Duration) *EventService {
+	return &EventService{
+		repo:         repo,
+		routeService: routeService,
+		tailer:       tailer,
+		parser:       parser,
+		retention:    retention,
+		subscribers:  make(map[chan model.Event]struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start follows the log and purges old events in the background
+func (s *EventService) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.tailer.Run(ctx, s.Ingest)
+	}()
+
+	if s.retention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+
+			s.purge()
+			for {
+				select {
+				case <-ticker.C:
+					s.purge()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(s.done)
+	}()
+}
+
+// Stop stops following the log and waits for it to finish
+func (s *EventService) Stop() {
+	s.once.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+	<-s.done
+}
+
+func (s *EventService) purge() {
+	count, err := s.repo.Purge(time.Now().Add(-s.retention))
+	if err != nil {
+		log.Printf("[Events] Purge failed: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[Events] Purged %d event(s) older than %s", count, s.retention)
+	}
+}
+
+// Ingest parses log lines and stores and publishes the events found in them
+func (s *EventService) Ingest(lines []string) {
+	now := time.Now().UTC()
+	events := make([]model.Event, 0)
+	for _, line := range lines {
+		entry, ok := s.parser.Parse(line)
+		if !ok {
+			continue
+		}
+
+		eventTime := entry.Time.UTC()
+		if entry.Time.IsZero() {
+			eventTime = now
+		}
+		events = append(events, model.Event{
+			Time:     eventTime,
+			Type:     entry.Type,
+			Cluster:  entry.Cluster,
+			Identity: entry.Identity,
+			Endpoint: entry.Endpoint,
+			Message:  entry.Message,
+		})
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	s.resolveClusters(events)
+	if err := s.repo.Append(events); err != nil {
+		log.Printf("[Events] Failed to store %d event(s): %v", len(events), err)
+		return
+	}
+	s.publish(events)
+}
+
+// resolveClusters fills in the cluster of events that only name a client.
+// rustun logs identities only, so the cluster is looked up in the routes;
+// identities used in several clusters are left unresolved.
+func (s *EventService) resolveClusters(events []model.Event) {
+	needed := false
+	for _, event := range events {
+		if event.Cluster == "" && event.Identity != "" {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return
+	}
+
+	clients, err := s.routeService.GetAllClients()
+	if err != nil {
+		log.Printf("[Events] Failed to look up clusters: %v", err)
+		return
+	}
+
+	clusters := make(map[string]string, len(clients))
+	for _, client := range clients {
+		if cluster, ok := clusters[client.Identity]; ok && cluster != client.Cluster {
+			clusters[client.Identity] = ""
+			continue
+		}
+		clusters[client.Identity] = client.Cluster
+	}
+
+	for i := range events {
+		if events[i].Cluster == "" && events[i].Identity != "" {
+			events[i].Cluster = clusters[events[i].Identity]
+		}
+	}
+}
+
+// List returns stored events matching the filter, newest first
+func (s *EventService) List(filter model.EventFilter) ([]model.Event, error) {
+	return s.repo.List(filter)
+}
+
+// Subscribe returns a channel receiving new events as they are ingested,
+// and a function to unsubscribe. Events are dropped for subscribers that
+// fall behind.
+func (s *EventService) Subscribe() (<-chan model.Event, func()) {
+	ch := make(chan model.Event, eventBuffer)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *EventService) publish(events []model.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Expiry actions supported by the scheduler
+const (
+	ExpiryActionDisable = "disable"
+	ExpiryActionDelete  = "delete"
+)
+
+// expirySchedulerActor is recorded as the actor of scheduler-driven changes
+const expirySchedulerActor = "system:expiry-scheduler"
+
+// ExpiryScheduler periodically deactivates clients whose expiry time has passed
+type ExpiryScheduler struct {
+	routeService *RouteService
+	auditService *AuditService
+	interval     time.Duration
+	action       string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewExpiryScheduler creates a scheduler that runs every interval and applies
+// the given action to expired clients
+func NewExpiryScheduler(routeService *RouteService, auditService *AuditService, interval time.Duration, action string) *ExpiryScheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &ExpiryScheduler{
+		routeService: routeService.WithActor(model.Actor{Name: expirySchedulerActor, Source: model.SourceSystem}),
+		auditService: auditService,
+		interval:     interval,
+		action:       action,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler loop in the background
+func (s *ExpiryScheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.runAndLog()
+		for {
+			select {
+			case <-ticker.C:
+				s.runAndLog()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates the scheduler loop and waits for it to exit
+func (s *ExpiryScheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *ExpiryScheduler) runAndLog() {
+	count, err := s.RunOnce(time.Now())
+	if err != nil {
+		log.Printf("[Expiry] Run failed: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[Expiry] Processed %d expired client(s) with action %q", count, s.action)
+	}
+}
+
+// RunOnce applies the expiry action to every client expired at now and
+// returns how many clients were processed
+func (s *ExpiryScheduler) RunOnce(now time.Time) (int, error) {
+	clients, err := s.routeService.GetAllClients()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list clients: %w", err)
+	}
+
+	processed := 0
+	var firstErr error
+	for _, client := range clients {
+		if !client.IsExpired(now) {
+			continue
+		}
+
+		detail := fmt.Sprintf("%s (expired at %s)", s.action, client.ExpiresAt.Format(time.RFC3339))
+		switch s.action {
+		case ExpiryActionDisable:
+			// Already disabled clients have nothing left to do
+			if !client.Enabled {
+				continue
+			}
+			_, err = s.routeService.DisableClient(client.Cluster, client.Identity)
+		case ExpiryActionDelete:
+			err = s.routeService.DeleteClient(client.Cluster, client.Identity)
+			if s.routeService.TrashEnabled() {
+				detail = fmt.Sprintf("%s (expired at %s, moved to trash)", s.action, client.ExpiresAt.Format(time.RFC3339))
+			} else {
+				detail = fmt.Sprintf("%s (expired at %s, ip %s released)", s.action, client.ExpiresAt.Format(time.RFC3339), client.PrivateIP)
+			}
+		default:
+			err = fmt.Errorf("unsupported expiry action: %s", s.action)
+		}
+
+		if err != nil {
+			log.Printf("[Expiry] Failed to %s %s/%s: %v", s.action, client.Cluster, client.Identity, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		s.auditService.Record(expirySchedulerActor, "client.expired", client.Cluster, client.Identity, detail)
+		processed++
+	}
+
+	return processed, firstErr
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// gitHistory returns the git history of the repository, if it keeps one
+func (s *RouteService) gitHistory() (repository.GitHistory, error) {
+	history, ok := s.repo.(repository.GitHistory)
+	if !ok {
+		return nil, fmt.Errorf("git storage is not enabled")
+	}
+	return history, nil
+}
+
+// GitLog returns the most recent commits of the routes configuration
+func (s *RouteService) GitLog(limit int) ([]model.GitCommit, error) {
+	history, err := s.gitHistory()
+	if err != nil {
+		return nil, err
+	}
+	return history.Log(limit)
+}
+
+// GitStatus reports the state of the git-backed routes configuration
+func (s *RouteService) GitStatus() (*model.GitStatus, error) {
+	history, err := s.gitHistory()
+	if err != nil {
+		return nil, err
+	}
+	return history.Status()
+}
+
+// GitPush pushes the routes configuration to the configured remote
+func (s *RouteService) GitPush() error {
+	history, err := s.gitHistory()
+	if err != nil {
+		return err
+	}
+	return history.Push()
+}
+
+// RevertGitCommit undoes a commit of the routes configuration with a new
+// commit. The inverse of the commit is applied like any other change: in
+// one transaction, through the IP bookkeeping and the trash, and recorded
+// as revisions. Clients changed since the commit, IP or identity conflicts
+// and new lint errors reject the revert.
+func (s *RouteService) RevertGitCommit(commit string) (*model.GitRevertResult, error) {
+	history, err := s.gitHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	before, after, err := history.CommitClients(commit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	revert := newSnapshotDiff(commit, snapshotCurrent, after, before)
+	if len(revert.Changes) == 0 {
+		return nil, fmt.Errorf("%w: commit %s does not change any clients", ErrValidation, commit)
+	}
+
+	scoped := *s
+	scoped.repo = history.WithSubject("Revert " + commit)
+	err = scoped.transact(func(tx repository.RouteRepository, state *batchState) error {
+		state.comment = "git revert " + commit
+
+		current, err := tx.GetAll()
+		if err != nil {
+			return err
+		}
+		for _, change := range revert.Changes {
+			if err := s.revertChange(tx, state, change); err != nil {
+				return fmt.Errorf("cannot revert %s of %s/%s: %w", change.Action, change.Cluster, change.Identity, err)
+			}
+		}
+
+		reverted, err := tx.GetAll()
+		if err != nil {
+			return err
+		}
+		return rejectNewLintErrors(current, reverted)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := history.Status()
+	if err != nil {
+		return nil, err
+	}
+	return &model.GitRevertResult{
+		Commit:  status.Head,
+		Summary: revert.Summary,
+		Changes: revert.Changes,
+	}, nil
+}
+
+// revertChange applies one change undoing a commit. The client must still
+// be as the commit left it; deleted clients are brought back from the trash
+// when they are there.
+func (s *RouteService) revertChange(tx repository.RouteRepository, state *batchState, change model.ClientChange) error {
+	current, err := tx.GetByClusterAndIdentity(change.Cluster, change.Identity)
+	if err != nil && err.Error() != "client not found" {
+		return err
+	}
+
+	if change.Action == model.ChangeCreate {
+		if current != nil {
+			return fmt.Errorf("%w: the client exists again", ErrValidation)
+		}
+		if entry := s.latestTrashEntry(change.Cluster, change.Identity); entry != nil {
+			_, err := restoreInTx(tx, state, *entry, *change.After)
+			return err
+		}
+		if err := state.reserve(change.After.Cluster, change.After.PrivateIP); err != nil {
+			return fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+		if err := tx.Create(*change.After); err != nil {
+			return err
+		}
+		state.created(change.After)
+		return nil
+	}
+
+	if current == nil {
+		return fmt.Errorf("%w: the client no longer exists", ErrValidation)
+	}
+	if len(model.DiffClients(current, change.Before)) > 0 {
+		return fmt.Errorf("%w: the client was changed since", ErrValidation)
+	}
+	if change.Action == model.ChangeDelete {
+		return deleteInTx(tx, state, change.Cluster, change.Identity)
+	}
+	if err := updateInTx(tx, state, *current, *change.After); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	return nil
+}
+
+// latestTrashEntry returns the most recent trash entry of a client, or nil
+func (s *RouteService) latestTrashEntry(cluster, identity string) *model.TrashEntry {
+	if s.trash == nil {
+		return nil
+	}
+
+	entries, err := s.trash.List(model.TrashFilter{Cluster: cluster, Identity: identity})
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	return &entries[0]
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// recordRevisions stores committed client changes as revisions attributed to
+// the service's actor
+func (s *RouteService) recordRevisions(changes []model.ClientChange, comment string) error {
+	if s.revisions == nil || len(changes) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	revisions := make([]model.Revision, 0, len(changes))
+	for _, change := range changes {
+		revisions = append(revisions, model.Revision{
+			Time:     now,
+			Actor:    s.actor.Name,
+			Source:   s.actor.Source,
+			Action:   change.Action,
+			Cluster:  change.Cluster,
+			Identity: change.Identity,
+			Comment:  comment,
+			Before:   change.Before,
+			After:    change.After,
+		})
+	}
+
+	if err := s.revisions.Append(revisions...); err != nil {
+		return fmt.Errorf("failed to record %d revisions: %w", len(revisions), err)
+	}
+	return nil
+}
+
+// GetClientHistory returns the revisions of a client, newest first, with
+// field-level changes filled in
+func (s *RouteService) GetClientHistory(clusterName, identity string, limit int) ([]model.Revision, error) {
+	if s.revisions == nil {
+		return nil, fmt.Errorf("revision history is not available")
+	}
+
+	revisions, err := s.revisions.List(model.RevisionFilter{
+		Cluster:  clusterName,
+		Identity: identity,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		revisions[i].Changes = model.DiffClients(revisions[i].Before, revisions[i].After)
+	}
+	return revisions, nil
+}
+
+// RollbackClient restores a client to the state recorded right after the
+// given revision. Deleted clients are recreated with their previous IP.
+func (s *RouteService) RollbackClient(clusterName, identity string, revisionID uint) (*model.Client, error) {
+	if s.revisions == nil {
+		return nil, fmt.Errorf("revision history is not available")
+	}
+
+	revision, err := s.revisions.Get(revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision.Cluster != clusterName || revision.Identity != identity {
+		return nil, fmt.Errorf("%w: revision %d belongs to %s/%s", ErrValidation, revisionID, revision.Cluster, revision.Identity)
+	}
+	if revision.After == nil {
+		return nil, fmt.Errorf("%w: revision %d deleted the client; roll back to an earlier revision", ErrValidation, revisionID)
+	}
+
+	// A deleted client may still be in the trash, holding its IP
+	var trashed []model.TrashEntry
+	if s.trash != nil {
+		if trashed, err = s.trash.List(model.TrashFilter{Cluster: clusterName, Identity: identity}); err != nil {
+			return nil, err
+		}
+	}
+
+	target := *revision.After
+	err = s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		state.comment = fmt.Sprintf("rollback to revision %d", revisionID)
+
+		current, err := tx.GetByClusterAndIdentity(clusterName, identity)
+		if err != nil {
+			if err.Error() != "client not found" {
+				return err
+			}
+			if len(trashed) > 0 {
+				_, err := restoreInTx(tx, state, trashed[0], target)
+				return err
+			}
+			_, err = createInTx(tx, state, target)
+			return err
+		}
+
+		if len(model.DiffClients(current, &target)) == 0 {
+			return nil
+		}
+		return updateInTx(tx, state, *current, target)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// AsOf returns a read-only view of the service showing the clients as they
+// were at the given time. The state is rebuilt by undoing, newest first,
+// every revision recorded after t, so it is exact back to the oldest
+// recorded revision.
+func (s *RouteService) AsOf(t time.Time) (*RouteService, error) {
+	clients, err := s.clientsAsOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	view := *s
+	view.repo = repository.NewMemoryRepository(clients)
+	view.revisions = nil
+	view.snapshots = nil
+	return &view, nil
+}
+
+func (s *RouteService) clientsAsOf(t time.Time) ([]model.Client, error) {
+	if s.revisions == nil {
+		return nil, fmt.Errorf("revision history is not available")
+	}
+
+	current, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	later, err := s.revisions.List(model.RevisionFilter{After: t.UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	key := func(cluster, identity string) string { return cluster + "/" + identity }
+	state := make(map[string]model.Client, len(current))
+	order := make([]string, 0, len(current))
+	for _, client := range current {
+		k := key(client.Cluster, client.Identity)
+		state[k] = client
+		order = append(order, k)
+	}
+
+	// Revisions are listed newest first, which is the order to undo them in
+	for _, revision := range later {
+		k := key(revision.Cluster, revision.Identity)
+		if revision.Before == nil {
+			delete(state, k)
+			continue
+		}
+		if _, exists := state[k]; !exists {
+			order = append(order, k)
+		}
+		state[k] = *revision.Before
+	}
+
+	clients := make([]model.Client, 0, len(state))
+	for _, k := range order {
+		if client, ok := state[k]; ok {
+			clients = append(clients, client)
+			delete(state, k)
+		}
+	}
+	return clients, nil
+}
+
+// DiffTimes lists the client changes between two points in time
+func (s *RouteService) DiffTimes(from, to time.Time) (*model.TimeDiff, error) {
+	before, err := s.clientsAsOf(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.clientsAsOf(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := newSnapshotDiff("", "", before, after)
+	return &model.TimeDiff{
+		From:    from,
+		To:      to,
+		Summary: diff.Summary,
+		Changes: diff.Changes,
+	}, nil
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ExportClients returns the clients to export, optionally limited to one
+// cluster, ordered by cluster and name
+func (s *RouteService) ExportClients(cluster string) ([]model.Client, error) {
+	var (
+		clients []model.Client
+		err     error
+	)
+	if cluster != "" {
+		clients, err = s.repo.GetByCluster(cluster)
+	} else {
+		clients, err = s.repo.GetAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(clients, func(i, j int) bool {
+		if clients[i].Cluster != clients[j].Cluster {
+			return clients[i].Cluster < clients[j].Cluster
+		}
+		return clients[i].Name < clients[j].Name
+	})
+	return clients, nil
+}
+
+// ImportClients validates the imported records and, unless opts.DryRun is
+// set, applies them in a single transaction. Rows without a private IP get
+// one allocated. If any row is invalid nothing is applied and the result
+// lists the problems alongside an ErrValidation error.
+func (s *RouteService) ImportClients(records []model.ImportRecord, opts model.ImportOptions) (*model.ImportResult, error) {
+	if opts.Policy == "" {
+		opts.Policy = model.ConflictFail
+	}
+	switch opts.Policy {
+	case model.ConflictSkip, model.ConflictOverwrite, model.ConflictFail:
+	default:
+		return nil, fmt.Errorf("%w: unknown conflict policy %q (expected skip, overwrite or fail)", ErrValidation, opts.Policy)
+	}
+
+	if opts.DryRun {
+		existing, err := s.repo.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		plan := s.planImport(existing, records, opts)
+		return plan.result, plan.err()
+	}
+
+	var plan *importPlan
+	err := s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		// Plan inside the transaction so conflicts are checked against what gets written
+		existing, err := tx.GetAll()
+		if err != nil {
+			return err
+		}
+
+		plan = s.planImport(existing, records, opts)
+		if err := plan.err(); err != nil {
+			return err
+		}
+
+		return applyImportChanges(tx, state, plan.result.Changes)
+	})
+	if err != nil {
+		if plan != nil {
+			return plan.result, err
+		}
+		return nil, err
+	}
+
+	// Report generated identities back on the rows that created them
+	for i, row := range plan.changeRows {
+		change := plan.result.Changes[i]
+		plan.result.Rows[row].Identity = change.Identity
+	}
+	plan.result.Applied = true
+	return plan.result, nil
+}
+
+// applyImportChanges applies the planned changes of an import. Addresses
+// that updated clients move away from are freed first, and updates run
+// before creates allocate, so clients can swap addresses and take over
+// addresses given up by other rows.
+func applyImportChanges(tx repository.RouteRepository, state *batchState, changes []model.ClientChange) error {
+	for _, change := range changes {
+		if change.Action == model.ChangeUpdate && change.After.PrivateIP != change.Before.PrivateIP {
+			state.free(change.Before.Cluster, change.Before.PrivateIP)
+		}
+	}
+
+	for _, updates := range []bool{true, false} {
+		for i := range changes {
+			if (changes[i].Action == model.ChangeUpdate) != updates {
+				continue
+			}
+			if err := applyChanges(tx, state, changes[i:i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importPlan is the outcome of validating an import against existing clients
+type importPlan struct {
+	result     *model.ImportResult
+	changeRows []int // Index into result.Rows for each entry of result.Changes
+}
+
+func (p *importPlan) err() error {
+	if p.result.Summary.Invalid > 0 {
+		return fmt.Errorf("%w: %d invalid rows", ErrValidation, p.result.Summary.Invalid)
+	}
+	return nil
+}
+
+// planImport turns imported records into client changes and per-row results
+func (s *RouteService) planImport(existing []model.Client, records []model.ImportRecord, opts model.ImportOptions) *importPlan {
+	byKey := make(map[string]model.Client, len(existing))
+	usedIPs := make(map[string]string, len(existing)) // cluster/ip -> identity
+	for _, client := range existing {
+		byKey[client.Cluster+"/"+client.Identity] = client
+		if client.PrivateIP != "" {
+			usedIPs[client.Cluster+"/"+client.PrivateIP] = client.Identity
+		}
+	}
+
+	plan := &importPlan{
+		result: &model.ImportResult{
+			Policy:  opts.Policy,
+			DryRun:  opts.DryRun,
+			Rows:    make([]model.ImportRowResult, 0, len(records)),
+			Changes: make([]model.ClientChange, 0),
+		},
+		changeRows: make([]int, 0),
+	}
+	result := plan.result
+	seen := make(map[string]int)
+
+	// Addresses that overwritten clients move away from may be taken by
+	// other rows, so clients can swap addresses in one import
+	vacated := make(map[string]string) // cluster/ip -> identity of the current owner
+	if opts.Policy == model.ConflictOverwrite {
+		for _, record := range records {
+			client := record.Client
+			if client.Cluster == "" {
+				client.Cluster = opts.Cluster
+			}
+			if opts.Cluster != "" && client.Cluster != opts.Cluster {
+				continue
+			}
+			before, exists := byKey[client.Cluster+"/"+client.Identity]
+			if record.Err == "" && exists && client.Identity != "" && client.PrivateIP != "" && client.PrivateIP != before.PrivateIP {
+				vacated[client.Cluster+"/"+before.PrivateIP] = before.Identity
+			}
+		}
+	}
+	ipOwner := func(cluster, ip string) (string, bool) {
+		owner, used := usedIPs[cluster+"/"+ip]
+		if used && vacated[cluster+"/"+ip] == owner {
+			return "", false
+		}
+		return owner, used
+	}
+
+	for _, record := range records {
+		client := record.Client
+		if client.Cluster == "" {
+			client.Cluster = opts.Cluster
+		}
+
+		row := model.ImportRowResult{
+			Row:      record.Row,
+			Cluster:  client.Cluster,
+			Identity: client.Identity,
+			Name:     client.Name,
+		}
+		invalid := func(format string, args ...interface{}) {
+			row.Action = model.ImportInvalid
+			row.Error = fmt.Sprintf(format, args...)
+			result.Summary.Invalid++
+			result.Rows = append(result.Rows, row)
+		}
+
+		if record.Err != "" {
+			invalid("%s", record.Err)
+			continue
+		}
+		if opts.Cluster != "" && client.Cluster != opts.Cluster {
+			row.Action = model.ImportSkip
+			row.Error = fmt.Sprintf("outside cluster %s", opts.Cluster)
+			result.Summary.Skip++
+			result.Rows = append(result.Rows, row)
+			continue
+		}
+		if err := validateClientFields(client); err != nil {
+			invalid("%v", err)
+			continue
+		}
+
+		key := client.Cluster + "/" + client.Identity
+		if client.Identity != "" {
+			if first, ok := seen[key]; ok {
+				invalid("duplicate identity %s (first seen in row %d)", client.Identity, first)
+				continue
+			}
+			seen[key] = record.Row
+		}
+
+		if client.Ciders == nil {
+			client.Ciders = []string{}
+		}
+		if client.PrivateIP != "" {
+			// Fill in the cluster's network settings for fixed addresses
+			config := s.ipManager.Config(client.Cluster)
+			if client.Mask == "" {
+				client.Mask = config.Mask
+			}
+			if client.Gateway == "" {
+				client.Gateway = config.Gateway
+			}
+		} else {
+			// Allocation provides the mask and gateway
+			client.Mask = ""
+			client.Gateway = ""
+		}
+
+		before, exists := byKey[key]
+		if exists && client.Identity != "" {
+			switch opts.Policy {
+			case model.ConflictSkip:
+				row.Action = model.ImportSkip
+				row.Error = "client already exists"
+				result.Summary.Skip++
+				result.Rows = append(result.Rows, row)
+				continue
+			case model.ConflictFail:
+				invalid("client %s already exists", key)
+				continue
+			}
+
+			// Overwrite keeps the existing address when the row has none
+			if client.PrivateIP == "" {
+				client.PrivateIP = before.PrivateIP
+				client.Mask = before.Mask
+				client.Gateway = before.Gateway
+			}
+			if client.PrivateIP != before.PrivateIP {
+				if owner, used := ipOwner(client.Cluster, client.PrivateIP); used {
+					invalid("IP %s is already used by %s", client.PrivateIP, owner)
+					continue
+				}
+				// Another row may already have taken over the old address
+				if usedIPs[client.Cluster+"/"+before.PrivateIP] == before.Identity {
+					delete(usedIPs, client.Cluster+"/"+before.PrivateIP)
+				}
+				usedIPs[client.Cluster+"/"+client.PrivateIP] = client.Identity
+			}
+
+			fields := model.DiffClients(&before, &client)
+			if len(fields) == 0 {
+				row.Action = model.ImportUnchanged
+				result.Summary.Unchanged++
+				result.Rows = append(result.Rows, row)
+				continue
+			}
+
+			row.Action = model.ImportUpdate
+			result.Summary.Update++
+			result.Rows = append(result.Rows, row)
+			after := client
+			result.Changes = append(result.Changes, model.ClientChange{
+				Action:   model.ChangeUpdate,
+				Cluster:  client.Cluster,
+				Identity: client.Identity,
+				Name:     client.Name,
+				Fields:   fields,
+				Before:   &before,
+				After:    &after,
+			})
+			plan.changeRows = append(plan.changeRows, len(result.Rows)-1)
+			continue
+		}
+
+		if client.PrivateIP != "" {
+			if owner, used := ipOwner(client.Cluster, client.PrivateIP); used {
+				invalid("IP %s is already used by %s", client.PrivateIP, owner)
+				continue
+			}
+			usedIPs[client.Cluster+"/"+client.PrivateIP] = client.Identity
+		}
+
+		row.Action = model.ImportCreate
+		result.Summary.Create++
+		result.Rows = append(result.Rows, row)
+		after := client
+		result.Changes = append(result.Changes, model.ClientChange{
+			Action:   model.ChangeCreate,
+			Cluster:  client.Cluster,
+			Identity: client.Identity,
+			Name:     client.Name,
+			After:    &after,
+		})
+		plan.changeRows = append(plan.changeRows, len(result.Rows)-1)
+	}
+
+	return plan
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+	"github.com/smartethnet/rustun-dashboard/internal/secret"
+)
+
+// ServerKeySetter changes the crypto key of the rustun server
+type ServerKeySetter interface {
+	SetCryptoKey(actor, cipher, key, reason string) (prevCipher, prevKey string, err error)
+}
+
+// KeyService generates, stores and rotates per-cluster crypto keys. Keys are
+// encrypted with the master key before they are stored.
+type KeyService struct {
+	repo         repository.KeyRepository
+	box          *secret.Box
+	auditService *AuditService
+	server       ServerKeySetter
+	mu           sync.Mutex // Serializes changes, so a cluster has at most one pending key
+}
+
+// NewKeyService creates a new key service. Activated keys are written to the
+// rustun server through server; as the server has a single key, only one
+// cluster can have an active key then. If server is nil the server key is
+// changed by hand and every activation must confirm that it was.
+func NewKeyService(repo repository.KeyRepository, box *secret.Box, auditService *AuditService, server ServerKeySetter) *KeyService {
+	return &KeyService{
+		repo:         repo,
+		box:          box,
+		auditService: auditService,
+		server:       server,
+	}
+}
+
+// ListKeys returns the keys of a cluster, newest first, without secrets
+func (s *KeyService) ListKeys(clusterName string) ([]model.ClusterKey, error) {
+	return s.repo.List(clusterName)
+}
+
+// GenerateKey creates a new random key for a cluster. The first key of a
+// cluster is activated and written to the server right away if the server
+// key is managed and not used by another cluster; other keys stay pending
+// until ActivateKey so they can be rolled out first.
+func (s *KeyService) GenerateKey(actor, clusterName, cipher string) (*model.ClusterKey, error) {
+	cipher, err := parseKeyCipher(cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.repo.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	status := model.KeyActive
+	for _, key := range keys {
+		switch key.Status {
+		case model.KeyPending:
+			return nil, fmt.Errorf("%w: cluster %s already has pending key %d; activate or discard it first", ErrValidation, clusterName, key.ID)
+		case model.KeyActive:
+			status = model.KeyPending
+		}
+	}
+	if status == model.KeyActive && s.server == nil {
+		status = model.KeyPending
+	}
+	if status == model.KeyActive {
+		other, err := s.otherActiveCluster(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		if other != "" {
+			status = model.KeyPending
+		}
+	}
+
+	plaintext, err := secret.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	key := &model.ClusterKey{
+		Cluster:      clusterName,
+		Cipher:       cipher,
+		Status:       status,
+		Fingerprint:  fingerprint(plaintext),
+		EncryptedKey: sealed,
+		CreatedAt:    now,
+		CreatedBy:    actor,
+	}
+	restore := func() {}
+	if status == model.KeyActive {
+		key.ActivatedAt = &now
+		restore, err = s.setServerKey(actor, key, plaintext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Create(key); err != nil {
+		restore()
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "key.generated", clusterName, "", fmt.Sprintf("key %d (%s, %s, fingerprint %s)", key.ID, cipher, status, key.Fingerprint))
+	return key, nil
+}
+
+// ActivateKey makes the pending key of a cluster active and retires the
+// previously active key. If the server key is managed, the key is written to
+// the rustun server first; otherwise serverUpdated must confirm that the
+// server already uses it.
+func (s *KeyService) ActivateKey(actor, clusterName string, serverUpdated bool) (*model.ClusterKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.repo.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, active := findKey(keys, model.KeyPending), findKey(keys, model.KeyActive)
+	if pending == nil {
+		return nil, fmt.Errorf("%w: cluster %s has no pending key", ErrValidation, clusterName)
+	}
+
+	restore := func() {}
+	if s.server != nil {
+		other, err := s.otherActiveCluster(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		if other != "" {
+			return nil, fmt.Errorf("%w: the rustun server has a single key, which is the active key of cluster %s", ErrValidation, other)
+		}
+		plaintext, err := s.box.Open(pending.EncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		restore, err = s.setServerKey(actor, pending, plaintext)
+		if err != nil {
+			return nil, err
+		}
+	} else if !serverUpdated {
+		return nil, fmt.Errorf("%w: the dashboard does not manage the rustun server key; set the server to key %d first and confirm with server_updated", ErrValidation, pending.ID)
+	}
+
+	now := time.Now().UTC()
+	pending.Status = model.KeyActive
+	pending.ActivatedAt = &now
+	updated := []model.ClusterKey{*pending}
+	detail := fmt.Sprintf("key %d (fingerprint %s) activated", pending.ID, pending.Fingerprint)
+	if active != nil {
+		active.Status = model.KeyRetired
+		active.RetiredAt = &now
+		updated = append(updated, *active)
+		detail += fmt.Sprintf(", key %d retired", active.ID)
+	}
+	if s.server == nil {
+		detail += ", server key changed by hand"
+	}
+
+	if err := s.repo.Update(updated...); err != nil {
+		restore()
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "key.activated", clusterName, "", detail)
+	return pending, nil
+}
+
+// DiscardPendingKey retires the pending key of a cluster without activating it
+func (s *KeyService) DiscardPendingKey(actor, clusterName string) (*model.ClusterKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.repo.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := findKey(keys, model.KeyPending)
+	if pending == nil {
+		return nil, fmt.Errorf("%w: cluster %s has no pending key", ErrValidation, clusterName)
+	}
+
+	now := time.Now().UTC()
+	pending.Status = model.KeyRetired
+	pending.RetiredAt = &now
+	if err := s.repo.Update(*pending); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "key.discarded", clusterName, "", fmt.Sprintf("pending key %d (fingerprint %s) discarded", pending.ID, pending.Fingerprint))
+	return pending, nil
+}
+
+// otherActiveCluster returns a cluster other than clusterName with an active
+// key, or an empty string
+func (s *KeyService) otherActiveCluster(clusterName string) (string, error) {
+	keys, err := s.repo.List("")
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.Status == model.KeyActive && key.Cluster != clusterName {
+			return key.Cluster, nil
+		}
+	}
+	return "", nil
+}
+
+// setServerKey writes a key to the rustun server and returns a function
+// putting the previous key back, for when the key cannot be stored
+func (s *KeyService) setServerKey(actor string, key *model.ClusterKey, plaintext string) (func(), error) {
+	reason := fmt.Sprintf("key of cluster %s (fingerprint %s) activated", key.Cluster, key.Fingerprint)
+	prevCipher, prevKey, err := s.server.SetCryptoKey(actor, key.Cipher, plaintext, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set the rustun server key: %w", err)
+	}
+
+	return func() {
+		if _, _, err := s.server.SetCryptoKey(actor, prevCipher, prevKey, "activation of cluster "+key.Cluster+" failed"); err != nil {
+			log.Printf("[Keys] Failed to restore the rustun server key after a failed activation in %s: %v", key.Cluster, err)
+		}
+	}, nil
+}
+
+// RevealKey decrypts a key of a cluster. Every reveal is audited.
+func (s *KeyService) RevealKey(actor, clusterName string, id uint) (*model.KeySecret, error) {
+	key, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.Cluster != clusterName {
+		return nil, fmt.Errorf("key not found")
+	}
+
+	plaintext, err := s.box.Open(key.EncryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "key.revealed", clusterName, "", fmt.Sprintf("key %d (fingerprint %s)", key.ID, key.Fingerprint))
+	return &model.KeySecret{
+		ClusterKey: *key,
+		Key:        plaintext,
+		Crypto:     clientCrypto(key.Cipher, plaintext),
+	}, nil
+}
+
+// ClusterCrypto returns the client -c values for the active and pending keys
+// of a cluster; either is empty if the cluster has no such key
+func (s *KeyService) ClusterCrypto(clusterName string) (active, pending string, err error) {
+	keys, err := s.repo.List(clusterName)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, key := range keys {
+		if key.Status != model.KeyActive && key.Status != model.KeyPending {
+			continue
+		}
+		plaintext, err := s.box.Open(key.EncryptedKey)
+		if err != nil {
+			return "", "", fmt.Errorf("key %d: %w", key.ID, err)
+		}
+		if key.Status == model.KeyActive {
+			active = clientCrypto(key.Cipher, plaintext)
+		} else {
+			pending = clientCrypto(key.Cipher, plaintext)
+		}
+	}
+	return active, pending, nil
+}
+
+func findKey(keys []model.ClusterKey, status string) *model.ClusterKey {
+	for i := range keys {
+		if keys[i].Status == status {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func parseKeyCipher(cipher string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(cipher)) {
+	case "", model.CryptoChaCha20, "chacha20poly1305":
+		return model.CryptoChaCha20, nil
+	case model.CryptoAES256GCM, "aes256":
+		return model.CryptoAES256GCM, nil
+	default:
+		return "", fmt.Errorf("%w: cipher must be chacha20 or aes256gcm", ErrValidation)
+	}
+}
+
+// clientCrypto formats a key as the value of the client's -c option
+func clientCrypto(cipher, key string) string {
+	if cipher == model.CryptoAES256GCM {
+		return "aes256:" + key
+	}
+	return "chacha20:" + key
+}
+
+// fingerprint returns a short hash identifying a key
+func fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+const (
+	maxHeartbeatEndpointLen = 255
+	maxHeartbeatVersionLen  = 64
+)
+
+// PresenceService records client heartbeats and derives whether clients are
+// online
+type PresenceService struct {
+	repo         repository.PresenceRepository
+	routeService *RouteService
+	onlineAfter  time.Duration
+	offlineAfter time.Duration
+}
+
+// NewPresenceService creates a new presence service. Clients are online if
+// their last heartbeat is at most onlineAfter old, stale up to offlineAfter
+// and offline after that.
+func NewPresenceService(repo repository.PresenceRepository, routeService *RouteService, onlineAfter, offlineAfter time.Duration) *PresenceService {
+	return &PresenceService{
+		repo:         repo,
+		routeService: routeService,
+		onlineAfter:  onlineAfter,
+		offlineAfter: offlineAfter,
+	}
+}
+
+// Heartbeat records a heartbeat of an existing client. The endpoint defaults
+// to remoteAddr, the address the heartbeat came from. authorize is called
+// with the client before anything is recorded and its error returned.
+func (s *PresenceService) Heartbeat(req model.HeartbeatRequest, remoteAddr string, authorize func(client *model.Client) error) (*model.ClientStatus, error) {
+	req.Cluster = strings.TrimSpace(req.Cluster)
+	req.Identity = strings.TrimSpace(req.Identity)
+	req.Endpoint = strings.TrimSpace(req.Endpoint)
+	req.Version = strings.TrimSpace(req.Version)
+	if req.Identity == "" {
+		return nil, fmt.Errorf("%w: identity is required", ErrValidation)
+	}
+	if req.Uptime < 0 {
+		return nil, fmt.Errorf("%w: uptime must not be negative", ErrValidation)
+	}
+	if len(req.Version) > maxHeartbeatVersionLen {
+		return nil, fmt.Errorf("%w: version must be at most %d characters", ErrValidation, maxHeartbeatVersionLen)
+	}
+	if req.Endpoint == "" {
+		req.Endpoint = remoteAddr
+	} else if err := validateEndpoint(req.Endpoint); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+
+	client, err := s.findClient(req.Cluster, req.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(client); err != nil {
+		return nil, err
+	}
+
+	presence := model.Presence{
+		Cluster:  client.Cluster,
+		Identity: client.Identity,
+		Endpoint: req.Endpoint,
+		Version:  req.Version,
+		Uptime:   req.Uptime,
+		LastSeen: time.Now().UTC(),
+	}
+	if err := s.repo.Save(presence); err != nil {
+		return nil, err
+	}
+
+	return s.status(&presence, presence.LastSeen), nil
+}
+
+// findClient looks a client up by identity, within a cluster if one is given
+func (s *PresenceService) findClient(clusterName, identity string) (*model.Client, error) {
+	if clusterName != "" {
+		return s.routeService.GetClient(clusterName, identity)
+	}
+
+	clients, err := s.routeService.GetAllClients()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *model.Client
+	for i := range clients {
+		if clients[i].Identity != identity {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: identity %s exists in several clusters, cluster is required", ErrValidation, identity)
+		}
+		found = &clients[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("client not found")
+	}
+	return found, nil
+}
+
+// WithStatus attaches the connection state to each client
+func (s *PresenceService) WithStatus(clients []model.Client) ([]model.ClientWithStatus, error) {
+	clusterName := ""
+	if len(clients) > 0 {
+		clusterName = clients[0].Cluster
+		for _, client := range clients {
+			if client.Cluster != clusterName {
+				clusterName = ""
+				break
+			}
+		}
+	}
+
+	statuses, err := s.statuses(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.ClientWithStatus, 0, len(clients))
+	for _, client := range clients {
+		status, ok := statuses[presenceKey(client.Cluster, client.Identity)]
+		if !ok {
+			status = &model.ClientStatus{State: model.PresenceOffline}
+		}
+		result = append(result, model.ClientWithStatus{Client: client, Status: status})
+	}
+	return result, nil
+}
+
+// statuses returns the connection states of the clients of a cluster (or of
+// all clusters if empty) that have sent a heartbeat
+func (s *PresenceService) statuses(clusterName string) (map[string]*model.ClientStatus, error) {
+	records, err := s.repo.List(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	statuses := make(map[string]*model.ClientStatus, len(records))
+	for i := range records {
+		statuses[presenceKey(records[i].Cluster, records[i].Identity)] = s.status(&records[i], now)
+	}
+	return statuses, nil
+}
+
+// status derives the connection state from a heartbeat at the given time
+func (s *PresenceService) status(presence *model.Presence, now time.Time) *model.ClientStatus {
+	age := now.Sub(presence.LastSeen)
+	state := model.PresenceOffline
+	switch {
+	case age <= s.onlineAfter:
+		state = model.PresenceOnline
+	case age <= s.offlineAfter:
+		state = model.PresenceStale
+	}
+
+	lastSeen := presence.LastSeen
+	return &model.ClientStatus{
+		State:    state,
+		LastSeen: &lastSeen,
+		Endpoint: presence.Endpoint,
+		Version:  presence.Version,
+		Uptime:   presence.Uptime,
+	}
+}
+
+func presenceKey(clusterName, identity string) string {
+	return clusterName + "/" + identity
+}
+
+// validateEndpoint accepts an IP address or a host:port pair
+func validateEndpoint(endpoint string) error {
+	if len(endpoint) > maxHeartbeatEndpointLen {
+		return fmt.Errorf("endpoint must be at most %d characters", maxHeartbeatEndpointLen)
+	}
+	if net.ParseIP(endpoint) != nil {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint %q: must be an IP address or host:port", endpoint)
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smartethnet/rustun-dashboard/internal/ipadm"
@@ -12,16 +15,71 @@ import (
 type RouteService struct {
 	repo      repository.RouteRepository
 	ipManager *ipadm.IPAdmManager
+	revisions repository.RevisionRepository
+	snapshots repository.SnapshotRepository
+	trash     repository.TrashRepository
+	actor     model.Actor
+	listeners *changeListeners
+	mu        *sync.Mutex // Serializes transactions; shared by all copies
 }
 
-// NewRouteService creates a new route service with the given repository and IP manager
-func NewRouteService(repo repository.RouteRepository, ipManager *ipadm.IPAdmManager) *RouteService {
+// changeListeners are shared by all copies of a service made by WithActor
+type changeListeners struct {
+	mu  sync.RWMutex
+	fns []func(changes []model.ClientChange)
+}
+
+// NewRouteService creates a new route service with the given repository and
+// IP manager. Mutations are recorded in revisions, destructive operations
+// are preceded by a snapshot and deleted clients are moved to the trash; any
+// of them may be nil to turn that off.
+func NewRouteService(repo repository.RouteRepository, ipManager *ipadm.IPAdmManager, revisions repository.RevisionRepository, snapshots repository.SnapshotRepository, trash repository.TrashRepository) *RouteService {
 	return &RouteService{
 		repo:      repo,
 		ipManager: ipManager,
+		revisions: revisions,
+		snapshots: snapshots,
+		trash:     trash,
+		actor:     model.Actor{Source: model.SourceAPI},
+		listeners: &changeListeners{},
+		mu:        &sync.Mutex{},
+	}
+}
+
+// OnChange registers a function called with the client changes of every
+// committed modification
+func (s *RouteService) OnChange(fn func(changes []model.ClientChange)) {
+	s.listeners.mu.Lock()
+	defer s.listeners.mu.Unlock()
+
+	s.listeners.fns = append(s.listeners.fns, fn)
+}
+
+// notifyChange passes committed client changes to the registered listeners
+func (s *RouteService) notifyChange(changes []model.ClientChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	s.listeners.mu.RLock()
+	defer s.listeners.mu.RUnlock()
+
+	for _, fn := range s.listeners.fns {
+		fn(changes)
 	}
 }
 
+// WithActor returns a copy of the service that attributes its changes to
+// the given actor
+func (s *RouteService) WithActor(actor model.Actor) *RouteService {
+	scoped := *s
+	scoped.actor = actor
+	if repo, ok := s.repo.(repository.ActorScoped); ok {
+		scoped.repo = repo.WithActor(actor)
+	}
+	return &scoped
+}
+
 // GetAllClusters returns all unique clusters with client counts
 func (s *RouteService) GetAllClusters() ([]model.Cluster, error) {
 	clusterMap, err := s.repo.GetAllClusters()
@@ -59,9 +117,28 @@ func (s *RouteService) GetCluster(clusterName string) (*model.Cluster, []model.C
 	return cluster, clients, nil
 }
 
-// DeleteCluster removes all clients in a cluster
+// DeleteCluster removes all clients in a cluster. They are moved to the
+// trash if it is enabled and their IPs released otherwise. A snapshot of the
+// whole configuration is taken first.
 func (s *RouteService) DeleteCluster(clusterName string) error {
-	return s.repo.DeleteCluster(clusterName)
+	if _, err := s.snapshotBefore("deleting cluster " + clusterName); err != nil {
+		return err
+	}
+
+	return s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		clients, err := tx.GetByCluster(clusterName)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteCluster(clusterName); err != nil {
+			return err
+		}
+
+		for i := range clients {
+			state.deleted(&clients[i])
+		}
+		return nil
+	})
 }
 
 // GetAllClients returns all clients
@@ -74,6 +151,29 @@ func (s *RouteService) GetClientsByCluster(clusterName string) ([]model.Client,
 	return s.repo.GetByCluster(clusterName)
 }
 
+// GetExpiringClients returns clients with an expiry time before now+within,
+// including already expired ones, soonest first
+func (s *RouteService) GetExpiringClients(within time.Duration) ([]model.Client, error) {
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(within)
+	expiring := make([]model.Client, 0)
+	for _, client := range clients {
+		if client.IsExpired(deadline) {
+			expiring = append(expiring, client)
+		}
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(*expiring[j].ExpiresAt)
+	})
+
+	return expiring, nil
+}
+
 // GetClient returns a specific client by cluster and identity
 func (s *RouteService) GetClient(clusterName, identity string) (*model.Client, error) {
 	return s.repo.GetByClusterAndIdentity(clusterName, identity)
@@ -83,45 +183,102 @@ func (s *RouteService) GetClient(clusterName, identity string) (*model.Client, e
 func (s *RouteService) CreateClient(client model.Client) (*model.Client, error) {
 	// Generate UUID as identity
 	client.Identity = uuid.New().String()
-
-	// Allocate IP address with network config
-	allocated, err := s.ipManager.AllocateIP(client.Cluster)
-	if err != nil {
-		return nil, fmt.Errorf("failed to allocate IP: %w", err)
+	client.PrivateIP = ""
+	client.Enabled = true
+	if err := validateClientFields(client); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
 	}
-	client.PrivateIP = allocated.IP
-	client.Gateway = allocated.Gateway
-	client.Mask = allocated.Mask
 
-	if err := s.repo.Create(client); err != nil {
-		// Release IP on failure
-		s.ipManager.ReleaseIP(client.Cluster, allocated.IP)
+	var created *model.Client
+	err := s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		var err error
+		created, err = createInTx(tx, state, client)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return &client, nil
+	return created, nil
 }
 
-// UpdateClient updates an existing client
+// UpdateClient updates an existing client. The enabled state is kept as is;
+// use EnableClient and DisableClient to change it. An empty private IP keeps
+// the current address and nil labels keep the current labels. A nil expiry
+// keeps the current one, while a zero expiry time removes it.
 func (s *RouteService) UpdateClient(clusterName, identity string, updatedClient model.Client) error {
-	return s.repo.Update(clusterName, identity, updatedClient)
+	return s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		existing, err := tx.GetByClusterAndIdentity(clusterName, identity)
+		if err != nil {
+			return err
+		}
+		updatedClient.Cluster = existing.Cluster
+		updatedClient.Identity = existing.Identity
+		updatedClient.Enabled = existing.Enabled
+		if updatedClient.Labels == nil {
+			updatedClient.Labels = existing.Labels
+		}
+		if updatedClient.ExpiresAt == nil {
+			updatedClient.ExpiresAt = existing.ExpiresAt
+		} else if updatedClient.ExpiresAt.IsZero() {
+			updatedClient.ExpiresAt = nil
+		}
+		if updatedClient.PrivateIP == "" {
+			updatedClient.PrivateIP = existing.PrivateIP
+			updatedClient.Mask = existing.Mask
+			updatedClient.Gateway = existing.Gateway
+		}
+		if err := validateClientFields(updatedClient); err != nil {
+			return fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+
+		return updateInTx(tx, state, *existing, updatedClient)
+	})
 }
 
-// DeleteClient removes a client and releases its IP
-func (s *RouteService) DeleteClient(clusterName, identity string) error {
-	// Get client to retrieve its IP
-	client, err := s.repo.GetByClusterAndIdentity(clusterName, identity)
-	if err != nil {
-		return err
-	}
+// EnableClient publishes a previously disabled client to rustun again
+func (s *RouteService) EnableClient(clusterName, identity string) (*model.Client, error) {
+	return s.setClientEnabled(clusterName, identity, true)
+}
 
-	// Delete client
-	if err := s.repo.Delete(clusterName, identity); err != nil {
-		return err
+// DisableClient removes a client from the routes published to rustun while
+// keeping its identity, IP reservation and routes
+func (s *RouteService) DisableClient(clusterName, identity string) (*model.Client, error) {
+	return s.setClientEnabled(clusterName, identity, false)
+}
+
+func (s *RouteService) setClientEnabled(clusterName, identity string, enabled bool) (*model.Client, error) {
+	var client *model.Client
+	err := s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		existing, err := tx.GetByClusterAndIdentity(clusterName, identity)
+		if err != nil {
+			return err
+		}
+
+		client = existing
+		if existing.Enabled == enabled {
+			return nil
+		}
+
+		updated := *existing
+		updated.Enabled = enabled
+		if err := updateInTx(tx, state, *existing, updated); err != nil {
+			return err
+		}
+		client = &updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Release IP address
-	s.ipManager.ReleaseIP(clusterName, client.PrivateIP)
+	return client, nil
+}
 
-	return nil
+// DeleteClient removes a client. It is moved to the trash if it is enabled
+// and its IP released otherwise.
+func (s *RouteService) DeleteClient(clusterName, identity string) error {
+	return s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		return deleteInTx(tx, state, clusterName, identity)
+	})
 }
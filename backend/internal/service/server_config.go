@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ServerConfigService reads and validates changes to the rustun server
+// configuration
+type ServerConfigService struct {
+	repo          repository.ServerConfigRepository
+	auditService  *AuditService
+	allowInsecure bool // Allow the xor and plain crypto modes (debug mode only)
+}
+
+// NewServerConfigService creates a new server config service. The xor and
+// plain crypto modes are refused unless allowInsecure is set.
+func NewServerConfigService(repo repository.ServerConfigRepository, auditService *AuditService, allowInsecure bool) *ServerConfigService {
+	return &ServerConfigService{
+		repo:          repo,
+		auditService:  auditService,
+		allowInsecure: allowInsecure,
+	}
+}
+
+// GetConfig returns the server configuration with the crypto key masked
+func (s *ServerConfigService) GetConfig() (*model.ServerConfig, error) {
+	cfg, err := s.repo.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CryptoKey != "" {
+		cfg.CryptoKey = model.MaskedSecret
+	}
+	return cfg, nil
+}
+
+// UpdateConfig validates and saves the server configuration on behalf of
+// actor. An empty or masked crypto key keeps the current key.
+func (s *ServerConfigService) UpdateConfig(actor string, cfg model.ServerConfig) (*model.ServerConfig, error) {
+	current, err := s.repo.Get()
+	if err != nil && err.Error() != "server config not found" {
+		return nil, err
+	}
+
+	cfg.Crypto = strings.ToLower(strings.TrimSpace(cfg.Crypto))
+	if cfg.Crypto == "chacha20poly1305" {
+		cfg.Crypto = model.CryptoChaCha20
+	}
+	if cfg.CryptoKey == "" || cfg.CryptoKey == model.MaskedSecret {
+		cfg.CryptoKey = ""
+		if current != nil && current.Crypto != model.CryptoPlain {
+			cfg.CryptoKey = current.CryptoKey
+		}
+	}
+	if cfg.Crypto == model.CryptoPlain {
+		cfg.CryptoKey = ""
+	}
+
+	if err := s.validate(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(cfg); err != nil {
+		return nil, err
+	}
+
+	detail := fmt.Sprintf("listen_addr=%s crypto=%s routes_file=%s", cfg.ListenAddr, cfg.Crypto, cfg.RoutesFile)
+	if current != nil && current.CryptoKey != cfg.CryptoKey {
+		detail += " (crypto key changed)"
+	}
+	s.auditService.Record(actor, "server_config.updated", "", "", detail)
+
+	return s.GetConfig()
+}
+
+// SetCryptoKey makes cipher and key the crypto settings of the rustun server
+// on behalf of actor, giving reason in the audit log. The previous settings
+// are returned so the change can be reverted.
+func (s *ServerConfigService) SetCryptoKey(actor, cipher, key, reason string) (prevCipher, prevKey string, err error) {
+	cfg, err := s.repo.Get()
+	if err != nil {
+		return "", "", err
+	}
+	prevCipher, prevKey = cfg.Crypto, cfg.CryptoKey
+
+	cfg.Crypto = cipher
+	cfg.CryptoKey = key
+	if err := s.validate(*cfg); err != nil {
+		return "", "", err
+	}
+	if err := s.repo.Save(*cfg); err != nil {
+		return "", "", err
+	}
+
+	s.auditService.Record(actor, "server_config.updated", "", "", fmt.Sprintf("crypto=%s (crypto key changed: %s)", cipher, reason))
+	return prevCipher, prevKey, nil
+}
+
+func (s *ServerConfigService) validate(cfg model.ServerConfig) error {
+	host, port, err := net.SplitHostPort(cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("%w: listen_addr %q must be host:port", ErrValidation, cfg.ListenAddr)
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		return fmt.Errorf("%w: listen_addr host %q must be an IP address", ErrValidation, host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w: listen_addr port %q must be between 1 and 65535", ErrValidation, port)
+	}
+
+	switch cfg.Crypto {
+	case model.CryptoChaCha20, model.CryptoAES256GCM:
+	case model.CryptoXOR, model.CryptoPlain:
+		if !s.allowInsecure {
+			return fmt.Errorf("%w: crypto %s is only allowed in debug mode; use chacha20 or aes256gcm", ErrValidation, cfg.Crypto)
+		}
+	default:
+		return fmt.Errorf("%w: crypto must be chacha20, aes256gcm, xor or plain", ErrValidation)
+	}
+	if cfg.Crypto != model.CryptoPlain && cfg.CryptoKey == "" {
+		return fmt.Errorf("%w: crypto_key is required for %s", ErrValidation, cfg.Crypto)
+	}
+
+	if cfg.RoutesFile == "" || !filepath.IsAbs(cfg.RoutesFile) {
+		return fmt.Errorf("%w: routes_file must be an absolute path", ErrValidation)
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// ErrInvalidSession is returned for bad, expired and revoked access and
+// refresh tokens and sessions of disabled accounts alike
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+const maxUserAgentLen = 256
+
+// accessTokenHeader is the encoded JWT header of all access tokens
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// accessClaims is the payload of an access token
+type accessClaims struct {
+	Subject   string `json:"sub"` // Username, for readers of the token
+	UserID    uint   `json:"uid"`
+	SessionID uint   `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SessionService logs users in to the web UI. A session issues access
+// tokens, HS256-signed JWTs checked against the session on every request,
+// and rotates its refresh token each time new tokens are requested.
+type SessionService struct {
+	repo         repository.SessionRepository
+	userService  *UserService
+	auditService *AuditService
+	signingKey   []byte
+	accessTTL    time.Duration
+	sessionTTL   time.Duration
+}
+
+// NewSessionService creates a new session service. Access tokens are signed
+// with signingKey and live for accessTTL; sessions end sessionTTL after
+// their last refresh.
+func NewSessionService(repo repository.SessionRepository, userService *UserService, auditService *AuditService, signingKey []byte, accessTTL, sessionTTL time.Duration) *SessionService {
+	return &SessionService{
+		repo:         repo,
+		userService:  userService,
+		auditService: auditService,
+		signingKey:   signingKey,
+		accessTTL:    accessTTL,
+		sessionTTL:   sessionTTL,
+	}
+}
+
+// Login checks a username and password and starts a session
+func (s *SessionService) Login(username, password, ip, userAgent string) (*model.LoginResponse, error) {
+	user, err := s.userService.Login(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.repo.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+
+	session := &model.Session{
+		UserID:      user.ID,
+		RefreshHash: hashRefreshToken(refreshToken),
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(s.sessionTTL),
+		IP:          ip,
+		UserAgent:   userAgent,
+	}
+	if err := s.repo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, session, refreshToken, now)
+}
+
+// Refresh trades a refresh token for a new access token and refresh token,
+// extending the session. Each refresh token can only be used once.
+func (s *SessionService) Refresh(refreshToken string) (*model.LoginResponse, error) {
+	oldHash := hashRefreshToken(refreshToken)
+	session, err := s.repo.GetByRefreshHash(oldHash)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	now := time.Now().UTC()
+	if !session.ExpiresAt.After(now) {
+		return nil, ErrInvalidSession
+	}
+	user, err := s.userService.GetUser(session.UserID)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidSession
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	session.RefreshHash = hashRefreshToken(newToken)
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(s.sessionTTL)
+	if err := s.repo.Rotate(session.ID, oldHash, session.RefreshHash, session.RefreshedAt, session.ExpiresAt); err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	return s.issue(user, session, newToken, now)
+}
+
+// Authenticate returns the user and session of a valid access token
+func (s *SessionService) Authenticate(accessToken string) (*model.User, *model.Session, error) {
+	claims, err := s.verify(accessToken)
+	if err != nil {
+		return nil, nil, ErrInvalidSession
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt <= now.Unix() {
+		return nil, nil, ErrInvalidSession
+	}
+
+	// A token issued before the session was created belongs to an earlier
+	// session that had the same ID
+	session, err := s.repo.Get(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID || claims.IssuedAt < session.CreatedAt.Unix() || !session.ExpiresAt.After(now) {
+		return nil, nil, ErrInvalidSession
+	}
+
+	user, err := s.userService.GetUser(session.UserID)
+	if err != nil || user.Disabled {
+		return nil, nil, ErrInvalidSession
+	}
+	return user, session, nil
+}
+
+// ListSessions returns the unexpired sessions of a user, newest first,
+// marking the one with ID current
+func (s *SessionService) ListSessions(userID, current uint) ([]model.Session, error) {
+	sessions, err := s.repo.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ExpiresAt.After(now) {
+			session.Current = session.ID == current
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
+// RevokeSession ends a session of the given user
+func (s *SessionService) RevokeSession(actor string, user *model.User, id uint) error {
+	session, err := s.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	// Do not reveal sessions of other users
+	if session.UserID != user.ID {
+		return fmt.Errorf("session not found")
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, "session.revoked", "", "", fmt.Sprintf("session %d of %s", id, user.Username))
+	return nil
+}
+
+// Logout ends the session a request was made with
+func (s *SessionService) Logout(actor string, session *model.Session) error {
+	if err := s.repo.Delete(session.ID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, "user.logout", "", "", fmt.Sprintf("session %d", session.ID))
+	return nil
+}
+
+// RevokeUserSessions ends all sessions of a user except the one with ID
+// except, e.g. after a password change
+func (s *SessionService) RevokeUserSessions(actor string, user *model.User, except uint) error {
+	removed, err := s.repo.DeleteByUser(user.ID, except)
+	if err != nil {
+		return err
+	}
+
+	if removed > 0 {
+		s.auditService.Record(actor, "session.revoked", "", "", fmt.Sprintf("%d sessions of %s", removed, user.Username))
+	}
+	return nil
+}
+
+// DeleteUserSessions removes all sessions of a user, e.g. when the account
+// is deleted
+func (s *SessionService) DeleteUserSessions(userID uint) error {
+	_, err := s.repo.DeleteByUser(userID, 0)
+	return err
+}
+
+// issue creates an access token for a session and returns it with the
+// session's refresh token
+func (s *SessionService) issue(user *model.User, session *model.Session, refreshToken string, now time.Time) (*model.LoginResponse, error) {
+	expiresAt := now.Add(s.accessTTL)
+	accessToken, err := s.sign(accessClaims{
+		Subject:   user.Username,
+		UserID:    user.ID,
+		SessionID: session.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResponse{
+		User:             user,
+		SessionID:        session.ID,
+		TokenType:        "Bearer",
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// sign encodes claims as an HS256 JWT
+func (s *SessionService) sign(claims accessClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(s.mac(unsigned)), nil
+}
+
+// verify checks the signature of an access token and returns its claims
+func (s *SessionService) verify(token string) (*accessClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != accessTokenHeader {
+		return nil, fmt.Errorf("malformed access token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.mac(parts[0]+"."+parts[1])) {
+		return nil, fmt.Errorf("bad access token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed access token")
+	}
+	var claims accessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed access token")
+	}
+	return &claims, nil
+}
+
+func (s *SessionService) mac(data string) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+func newTestSessionService(t *testing.T, accessTTL, sessionTTL time.Duration) (*SessionService, *UserService) {
+	t.Helper()
+	users, auditService := newTestUserService(t)
+	repo := repository.NewFileSessionRepository(filepath.Join(t.TempDir(), "sessions.json"))
+	return NewSessionService(repo, users, auditService, []byte("test-signing-key"), accessTTL, sessionTTL), users
+}
+
+func login(t *testing.T, sessions *SessionService, username string) *model.LoginResponse {
+	t.Helper()
+	response, err := sessions.Login(username, testPassword, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Login(%s): %v", username, err)
+	}
+	return response
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	sessions, users := newTestSessionService(t, time.Hour, 24*time.Hour)
+	user := createTestUser(t, users, "alice", model.RoleBinding{Role: model.RoleViewer})
+	response := login(t, sessions, "alice")
+
+	other := NewSessionService(sessions.repo, users, sessions.auditService, []byte("other-signing-key"), time.Hour, 24*time.Hour)
+	otherToken := login(t, other, "alice").AccessToken
+
+	header, payload, signature := splitToken(t, response.AccessToken)
+	forged := func(claims accessClaims) string {
+		token, err := sessions.sign(claims)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	now := time.Now().Unix()
+	escalated, err := json.Marshal(accessClaims{Subject: "admin", UserID: user.ID + 1, SessionID: response.SessionID, IssuedAt: now, ExpiresAt: now + 3600})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"issued token", response.AccessToken, true},
+		{"empty", "", false},
+		{"malformed", "not-a-token", false},
+		{"tampered payload", header + "." + base64.RawURLEncoding.EncodeToString(escalated) + "." + signature, false},
+		{"tampered signature", header + "." + payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), false},
+		{"unsigned", header + "." + payload + ".", false},
+		{"other header", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + payload + "." + signature, false},
+		{"other signing key", otherToken, false},
+		{"expired", forged(accessClaims{UserID: user.ID, SessionID: response.SessionID, IssuedAt: now - 120, ExpiresAt: now - 60}), false},
+		{"unknown session", forged(accessClaims{UserID: user.ID, SessionID: response.SessionID + 100, IssuedAt: now, ExpiresAt: now + 60}), false},
+		{"other user", forged(accessClaims{UserID: user.ID + 1, SessionID: response.SessionID, IssuedAt: now, ExpiresAt: now + 60}), false},
+		{"issued before the session", forged(accessClaims{UserID: user.ID, SessionID: response.SessionID, IssuedAt: now - 3600, ExpiresAt: now + 60}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticated, _, err := sessions.Authenticate(tt.token)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidSession) {
+					t.Errorf("got error %v, want ErrInvalidSession", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if authenticated.ID != user.ID {
+				t.Errorf("authenticated user %d, want %d", authenticated.ID, user.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateEndedSession(t *testing.T) {
+	disabled := true
+
+	tests := []struct {
+		name string
+		end  func(t *testing.T, sessions *SessionService, users *UserService, response *model.LoginResponse)
+	}{
+		{
+			name: "logout",
+			end: func(t *testing.T, sessions *SessionService, users *UserService, response *model.LoginResponse) {
+				if err := sessions.Logout("alice", &model.Session{ID: response.SessionID}); err != nil {
+					t.Fatalf("Logout: %v", err)
+				}
+			},
+		},
+		{
+			name: "revoked sessions",
+			end: func(t *testing.T, sessions *SessionService, users *UserService, response *model.LoginResponse) {
+				if err := sessions.RevokeUserSessions("root", response.User, 0); err != nil {
+					t.Fatalf("RevokeUserSessions: %v", err)
+				}
+			},
+		},
+		{
+			name: "disabled account",
+			end: func(t *testing.T, sessions *SessionService, users *UserService, response *model.LoginResponse) {
+				if _, err := users.UpdateUser("root", response.User.ID, model.UpdateUserRequest{Disabled: &disabled}); err != nil {
+					t.Fatalf("UpdateUser: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, users := newTestSessionService(t, time.Hour, 24*time.Hour)
+			createTestUser(t, users, "root", model.RoleBinding{Role: model.RoleAdmin})
+			createTestUser(t, users, "alice", model.RoleBinding{Role: model.RoleViewer})
+			response := login(t, sessions, "alice")
+
+			tt.end(t, sessions, users, response)
+
+			if _, _, err := sessions.Authenticate(response.AccessToken); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("Authenticate: got error %v, want ErrInvalidSession", err)
+			}
+			if _, err := sessions.Refresh(response.RefreshToken); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("Refresh: got error %v, want ErrInvalidSession", err)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		sessionTTL time.Duration
+		refresh    func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string
+		valid      bool
+	}{
+		{
+			name:       "fresh token",
+			sessionTTL: time.Hour,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				return response.RefreshToken
+			},
+			valid: true,
+		},
+		{
+			name:       "rotated token",
+			sessionTTL: time.Hour,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				refreshed, err := sessions.Refresh(response.RefreshToken)
+				if err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+				return refreshed.RefreshToken
+			},
+			valid: true,
+		},
+		{
+			name:       "reused token",
+			sessionTTL: time.Hour,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				if _, err := sessions.Refresh(response.RefreshToken); err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+				return response.RefreshToken
+			},
+		},
+		{
+			name:       "tampered token",
+			sessionTTL: time.Hour,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				return tamper(t, response.RefreshToken)
+			},
+		},
+		{
+			name:       "access token",
+			sessionTTL: time.Hour,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				return response.AccessToken
+			},
+		},
+		{
+			name:       "expired session",
+			sessionTTL: time.Millisecond,
+			refresh: func(t *testing.T, sessions *SessionService, response *model.LoginResponse) string {
+				time.Sleep(5 * time.Millisecond)
+				return response.RefreshToken
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, users := newTestSessionService(t, time.Hour, tt.sessionTTL)
+			createTestUser(t, users, "alice", model.RoleBinding{Role: model.RoleViewer})
+			response := login(t, sessions, "alice")
+
+			refreshed, err := sessions.Refresh(tt.refresh(t, sessions, response))
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidSession) {
+					t.Errorf("got error %v, want ErrInvalidSession", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if refreshed.SessionID != response.SessionID {
+				t.Errorf("refreshed session %d, want %d", refreshed.SessionID, response.SessionID)
+			}
+			if _, _, err := sessions.Authenticate(refreshed.AccessToken); err != nil {
+				t.Errorf("Authenticate with the refreshed access token: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginRejectsBadCredentials(t *testing.T) {
+	sessions, users := newTestSessionService(t, time.Hour, 24*time.Hour)
+	createTestUser(t, users, "alice", model.RoleBinding{Role: model.RoleViewer})
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "wrong-password"},
+		{"unknown user", "bob", testPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sessions.Login(tt.username, tt.password, "127.0.0.1", "test"); err == nil {
+				t.Error("login succeeded")
+			}
+		})
+	}
+}
+
+func splitToken(t *testing.T, token string) (header, payload, signature string) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("access token has %d parts", len(parts))
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// tamper changes the first character of a base64url string
+func tamper(t *testing.T, s string) string {
+	t.Helper()
+	if s == "" {
+		t.Fatal("nothing to tamper with")
+	}
+	replacement := "A"
+	if s[0] == 'A' {
+		replacement = "B"
+	}
+	return replacement + s[1:]
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// snapshotCurrent names the live configuration in snapshot diffs
+const snapshotCurrent = "current"
+
+// CreateSnapshot stores a copy of the clients of all clusters
+func (s *RouteService) CreateSnapshot(name, description string) (*model.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, fmt.Errorf("snapshots are not available")
+	}
+
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveSnapshot(name, description, model.SnapshotManual, clients)
+}
+
+// snapshotBefore records an automatic snapshot ahead of a destructive
+// operation. Without a snapshot repository it does nothing.
+func (s *RouteService) snapshotBefore(operation string) (*model.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, nil
+	}
+
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.saveSnapshot("before "+operation, "", model.SnapshotAutomatic, clients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot before %s: %w", operation, err)
+	}
+	return snapshot, nil
+}
+
+func (s *RouteService) saveSnapshot(name, description, trigger string, clients []model.Client) (*model.Snapshot, error) {
+	now := time.Now().UTC()
+	if name == "" {
+		name = "snapshot " + now.Format(time.RFC3339)
+	}
+
+	snapshot := &model.Snapshot{
+		Name:        name,
+		Description: description,
+		Trigger:     trigger,
+		Actor:       s.actor.Name,
+		CreatedAt:   now,
+		ClientCount: len(clients),
+		Clients:     clients,
+	}
+	if err := s.snapshots.Create(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns all snapshots without their clients, newest first
+func (s *RouteService) ListSnapshots() ([]model.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, fmt.Errorf("snapshots are not available")
+	}
+	return s.snapshots.List()
+}
+
+// GetSnapshot returns a snapshot including its clients
+func (s *RouteService) GetSnapshot(id uint) (*model.Snapshot, error) {
+	if s.snapshots == nil {
+		return nil, fmt.Errorf("snapshots are not available")
+	}
+	return s.snapshots.Get(id)
+}
+
+// DeleteSnapshot removes a snapshot
+func (s *RouteService) DeleteSnapshot(id uint) error {
+	if s.snapshots == nil {
+		return fmt.Errorf("snapshots are not available")
+	}
+	return s.snapshots.Delete(id)
+}
+
+// DiffSnapshot compares a snapshot with another snapshot or, when against is
+// 0, with the current configuration. Changes describe how to get from the
+// snapshot to the other side.
+func (s *RouteService) DiffSnapshot(id, against uint) (*model.SnapshotDiff, error) {
+	snapshot, err := s.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+
+	to := snapshotCurrent
+	var other []model.Client
+	if against != 0 {
+		otherSnapshot, err := s.GetSnapshot(against)
+		if err != nil {
+			return nil, err
+		}
+		to = strconv.FormatUint(uint64(against), 10)
+		other = otherSnapshot.Clients
+	} else {
+		other, err = s.repo.GetAll()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newSnapshotDiff(strconv.FormatUint(uint64(id), 10), to, snapshot.Clients, other), nil
+}
+
+// RestoreSnapshot replaces the configuration of all clusters with the
+// snapshot in a single transaction and re-seeds IP allocation from the
+// restored clients before the next transaction can start. Restores that
+// would give a client the address of a client in the trash are rejected.
+// The configuration before the restore is saved as an automatic snapshot
+// first.
+func (s *RouteService) RestoreSnapshot(id uint) (*model.SnapshotRestoreResult, error) {
+	snapshot, err := s.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := s.snapshotBefore(fmt.Sprintf("restoring snapshot %d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	var diff *model.SnapshotDiff
+	err = s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		state.comment = fmt.Sprintf("restore snapshot %d", id)
+		state.reseed = true
+
+		if err := s.checkTrashConflicts(snapshot.Clients); err != nil {
+			return err
+		}
+
+		current, err := tx.GetAll()
+		if err != nil {
+			return err
+		}
+
+		// IP bookkeeping is rebuilt from scratch on commit, so changes are
+		// written directly instead of through the allocating helpers
+		diff = newSnapshotDiff(snapshotCurrent, strconv.FormatUint(uint64(id), 10), current, snapshot.Clients)
+		for _, change := range diff.Changes {
+			switch change.Action {
+			case model.ChangeDelete:
+				if err := tx.Delete(change.Cluster, change.Identity); err != nil {
+					return err
+				}
+				state.changes = append(state.changes, change)
+			case model.ChangeUpdate:
+				if err := tx.Update(change.Cluster, change.Identity, *change.After); err != nil {
+					return err
+				}
+				state.changes = append(state.changes, change)
+			case model.ChangeCreate:
+				if err := tx.Create(*change.After); err != nil {
+					return err
+				}
+				state.changes = append(state.changes, change)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Clients = nil
+	return &model.SnapshotRestoreResult{
+		Restored: snapshot,
+		Backup:   backup,
+		Diff:     diff,
+	}, nil
+}
+
+// checkTrashConflicts rejects clients whose address is held by another
+// client in the trash
+func (s *RouteService) checkTrashConflicts(clients []model.Client) error {
+	held := make(map[string]model.Client)
+	for _, trashed := range s.trashedClients() {
+		held[trashed.Cluster+"/"+trashed.PrivateIP] = trashed
+	}
+
+	for _, client := range clients {
+		trashed, ok := held[client.Cluster+"/"+client.PrivateIP]
+		if ok && trashed.Identity != client.Identity {
+			return fmt.Errorf("%w: IP %s of %s/%s is held by deleted client %s/%s; purge it from the trash first",
+				ErrValidation, client.PrivateIP, client.Cluster, client.Identity, trashed.Cluster, trashed.Identity)
+		}
+	}
+	return nil
+}
+
+// reseedIPs rebuilds IP allocation state from the given clients and the
+// clients in the trash, whose IPs stay reserved
+func (s *RouteService) reseedIPs(clients []model.Client) {
+	trashed := s.trashedClients()
+	infos := make([]struct {
+		Cluster   string
+		PrivateIP string
+	}, 0, len(clients)+len(trashed))
+	for _, list := range [][]model.Client{clients, trashed} {
+		for _, client := range list {
+			infos = append(infos, struct {
+				Cluster   string
+				PrivateIP string
+			}{client.Cluster, client.PrivateIP})
+		}
+	}
+
+	s.ipManager.Reset()
+	s.ipManager.InitFromExistingClients(infos)
+}
+
+// newSnapshotDiff computes the create, update and delete changes that turn
+// the from client set into the to client set. Clients are matched by cluster
+// and identity.
+func newSnapshotDiff(fromName, toName string, from, to []model.Client) *model.SnapshotDiff {
+	diff := &model.SnapshotDiff{
+		From:    fromName,
+		To:      toName,
+		Changes: make([]model.ClientChange, 0),
+	}
+
+	key := func(c model.Client) string { return c.Cluster + "/" + c.Identity }
+	before := make(map[string]model.Client, len(from))
+	for _, client := range from {
+		before[key(client)] = client
+	}
+	after := make(map[string]model.Client, len(to))
+	for _, client := range to {
+		after[key(client)] = client
+	}
+
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+
+		switch {
+		case !inAfter:
+			diff.Changes = append(diff.Changes, model.ClientChange{
+				Action:   model.ChangeDelete,
+				Cluster:  b.Cluster,
+				Identity: b.Identity,
+				Name:     b.Name,
+				Before:   &b,
+			})
+			diff.Summary.Add(model.ChangeDelete)
+		case !inBefore:
+			diff.Changes = append(diff.Changes, model.ClientChange{
+				Action:   model.ChangeCreate,
+				Cluster:  a.Cluster,
+				Identity: a.Identity,
+				Name:     a.Name,
+				After:    &a,
+			})
+			diff.Summary.Add(model.ChangeCreate)
+		default:
+			fields := model.DiffClients(&b, &a)
+			if len(fields) == 0 {
+				diff.Summary.Unchanged++
+				continue
+			}
+			diff.Changes = append(diff.Changes, model.ClientChange{
+				Action:   model.ChangeUpdate,
+				Cluster:  b.Cluster,
+				Identity: b.Identity,
+				Name:     a.Name,
+				Fields:   fields,
+				Before:   &b,
+				After:    &a,
+			})
+			diff.Summary.Add(model.ChangeUpdate)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/topology"
+)
+
+// TopologyService computes the network graphs of clusters
+type TopologyService struct {
+	routeService    *RouteService
+	presenceService *PresenceService
+}
+
+// NewTopologyService creates a new topology service
+func NewTopologyService(routeService *RouteService, presenceService *PresenceService) *TopologyService {
+	return &TopologyService{
+		routeService:    routeService,
+		presenceService: presenceService,
+	}
+}
+
+// GetTopology returns the graph of a cluster's gateway, clients and routed
+// subnets, with the clients' connection status
+func (s *TopologyService) GetTopology(clusterName string) (*model.Topology, error) {
+	_, clients, err := s.routeService.GetCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	withStatus, err := s.presenceService.WithStatus(clients)
+	if err != nil {
+		return nil, err
+	}
+
+	return topology.Build(clusterName, withStatus), nil
+}
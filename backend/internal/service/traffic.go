@@ -0,0 +1,338 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+const (
+	// maxTrafficPoints caps the number of steps in a series
+	maxTrafficPoints = 10000
+
+	// trafficTargetPoints is about how many steps a series gets when no
+	// step is requested
+	trafficTargetPoints = 300
+
+	// maxTrafficClockSkew is how far in the future a reading may be dated
+	maxTrafficClockSkew = 5 * time.Minute
+)
+
+// trafficResolutions lists the stored resolutions, finest first, with their
+// bucket sizes. Raw samples keep the reading time to the second.
+var trafficResolutions = []struct {
+	name string
+	size time.Duration
+}{
+	{model.TrafficRaw, time.Second},
+	{model.TrafficFiveMinute, 5 * time.Minute},
+	{model.TrafficHourly, time.Hour},
+}
+
+// trafficSteps are the steps picked when none is requested
+var trafficSteps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// TrafficRetention is how long each resolution is kept; 0 keeps it forever
+type TrafficRetention struct {
+	Raw        time.Duration
+	FiveMinute time.Duration
+	Hourly     time.Duration
+}
+
+// TrafficService turns client byte counters into traffic time series
+type TrafficService struct {
+	repo         repository.TrafficRepository
+	routeService *RouteService
+	retention    TrafficRetention
+
+	mu sync.Mutex // Serializes ingestion, which reads and updates counters
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewTrafficService creates a new traffic service
+func NewTrafficService(repo repository.TrafficRepository, routeService *RouteService, retention TrafficRetention) *TrafficService {
+	return &TrafficService{
+		repo:         repo,
+		routeService: routeService,
+		retention:    retention,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start purges samples past their retention hourly in the background
+func (s *TrafficService) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		s.purge(time.Now())
+		for {
+			select {
+			case <-ticker.C:
+				s.purge(time.Now())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates the purge loop and waits for it to exit
+func (s *TrafficService) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *TrafficService) purge(now time.Time) {
+	for resolution, retention := range map[string]time.Duration{
+		model.TrafficRaw:        s.retention.Raw,
+		model.TrafficFiveMinute: s.retention.FiveMinute,
+		model.TrafficHourly:     s.retention.Hourly,
+	} {
+		if retention <= 0 {
+			continue
+		}
+		count, err := s.repo.Purge(resolution, now.Add(-retention))
+		if err != nil {
+			log.Printf("[Traffic] Purge of %s samples failed: %v", resolution, err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("[Traffic] Purged %d %s sample(s) older than %s", count, resolution, retention)
+		}
+	}
+}
+
+// Ingest applies counter readings. The traffic since a client's previous
+// reading is added to the raw, 5-minute and hourly series; the first
+// reading of a client only sets its baseline. Invalid readings are
+// rejected individually. With inCluster set, readings that do not name a
+// cluster are taken to be in it and readings of other clusters are rejected.
+func (s *TrafficService) Ingest(reports []model.TrafficReport, inCluster string) (*model.TrafficIngestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients, err := s.routeService.GetAllClients()
+	if err != nil {
+		return nil, err
+	}
+	clusters := make(map[string][]string, len(clients))
+	for _, client := range clients {
+		clusters[client.Identity] = append(clusters[client.Identity], client.Cluster)
+	}
+
+	stored, err := s.repo.Counters()
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string]model.TrafficCounter, len(stored))
+	for _, counter := range stored {
+		counters[presenceKey(counter.Cluster, counter.Identity)] = counter
+	}
+
+	now := time.Now().UTC()
+	result := &model.TrafficIngestResult{}
+	changed := make(map[string]bool)
+	samples := make([]model.TrafficSample, 0, len(reports)*len(trafficResolutions))
+	for i, report := range reports {
+		reject := func(format string, args ...interface{}) {
+			result.Rejected++
+			result.Errors = append(result.Errors, fmt.Sprintf("report %d: ", i+1)+fmt.Sprintf(format, args...))
+		}
+
+		if report.Identity == "" {
+			reject("identity is required")
+			continue
+		}
+		if report.RxBytes < 0 || report.TxBytes < 0 {
+			reject("counters must not be negative")
+			continue
+		}
+
+		if inCluster != "" {
+			if report.Cluster == "" {
+				report.Cluster = inCluster
+			} else if report.Cluster != inCluster {
+				reject("outside cluster %s", inCluster)
+				continue
+			}
+		}
+
+		candidates := clusters[report.Identity]
+		cluster := report.Cluster
+		if cluster == "" && len(candidates) == 1 {
+			cluster = candidates[0]
+		} else if cluster == "" && len(candidates) > 1 {
+			reject("identity %s exists in several clusters, cluster is required", report.Identity)
+			continue
+		}
+		found := false
+		for _, candidate := range candidates {
+			found = found || candidate == cluster
+		}
+		if !found {
+			reject("client %s not found", report.Identity)
+			continue
+		}
+
+		readAt := now
+		if report.Time != nil {
+			readAt = report.Time.UTC()
+		}
+		if readAt.After(now.Add(maxTrafficClockSkew)) {
+			reject("time %s is in the future", readAt.Format(time.RFC3339))
+			continue
+		}
+
+		key := presenceKey(cluster, report.Identity)
+		previous, seen := counters[key]
+		if seen && readAt.Before(previous.Time) {
+			reject("time %s is before the previous reading", readAt.Format(time.RFC3339))
+			continue
+		}
+
+		counters[key] = model.TrafficCounter{
+			Cluster:  cluster,
+			Identity: report.Identity,
+			RxBytes:  report.RxBytes,
+			TxBytes:  report.TxBytes,
+			Time:     readAt,
+		}
+		changed[key] = true
+		result.Accepted++
+		if !seen {
+			continue
+		}
+
+		// A counter going down means it was reset, e.g. by a reconnect,
+		// so all of the new reading is new traffic
+		rx, tx := report.RxBytes-previous.RxBytes, report.TxBytes-previous.TxBytes
+		if rx < 0 || tx < 0 {
+			rx, tx = report.RxBytes, report.TxBytes
+		}
+		if rx == 0 && tx == 0 {
+			continue
+		}
+		for _, resolution := range trafficResolutions {
+			samples = append(samples, model.TrafficSample{
+				Cluster:    cluster,
+				Identity:   report.Identity,
+				Resolution: resolution.name,
+				Time:       readAt.Truncate(resolution.size),
+				RxBytes:    rx,
+				TxBytes:    tx,
+			})
+		}
+	}
+
+	updated := make([]model.TrafficCounter, 0, len(changed))
+	for key := range changed {
+		updated = append(updated, counters[key])
+	}
+	if err := s.repo.Record(updated, samples); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ClientSeries returns the traffic of a client in [from, to). If step is 0
+// one is picked for about 300 points.
+func (s *TrafficService) ClientSeries(clusterName, identity string, from, to time.Time, step time.Duration) (*model.TrafficSeries, error) {
+	if _, err := s.routeService.GetClient(clusterName, identity); err != nil {
+		return nil, err
+	}
+	return s.series(clusterName, identity, from, to, step)
+}
+
+// ClusterSeries returns the traffic of all clients of a cluster in
+// [from, to). If step is 0 one is picked for about 300 points.
+func (s *TrafficService) ClusterSeries(clusterName string, from, to time.Time, step time.Duration) (*model.TrafficSeries, error) {
+	if _, _, err := s.routeService.GetCluster(clusterName); err != nil {
+		return nil, err
+	}
+	return s.series(clusterName, "", from, to, step)
+}
+
+func (s *TrafficService) series(clusterName, identity string, from, to time.Time, step time.Duration) (*model.TrafficSeries, error) {
+	from, to = from.UTC(), to.UTC()
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrValidation)
+	}
+	if step < 0 || step%time.Second != 0 {
+		return nil, fmt.Errorf("%w: step must be a positive number of seconds", ErrValidation)
+	}
+	if step == 0 {
+		step = trafficSteps[len(trafficSteps)-1]
+		for _, candidate := range trafficSteps {
+			if to.Sub(from)/candidate <= trafficTargetPoints {
+				step = candidate
+				break
+			}
+		}
+	}
+
+	// Align the steps so that series of adjacent ranges line up
+	from = from.Truncate(step)
+	count := int((to.Sub(from) + step - 1) / step)
+	if count > maxTrafficPoints {
+		return nil, fmt.Errorf("%w: %d points exceed the maximum of %d, use a larger step", ErrValidation, count, maxTrafficPoints)
+	}
+
+	// The coarsest resolution whose buckets fit the step evenly needs the
+	// fewest rows and is kept the longest
+	resolution := trafficResolutions[0].name
+	for _, candidate := range trafficResolutions {
+		if step%candidate.size == 0 {
+			resolution = candidate.name
+		}
+	}
+
+	samples, err := s.repo.Query(clusterName, identity, resolution, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &model.TrafficSeries{
+		Cluster:    clusterName,
+		Identity:   identity,
+		From:       from,
+		To:         to,
+		Step:       step.String(),
+		Resolution: resolution,
+		Points:     make([]model.TrafficPoint, count),
+	}
+	for i := range series.Points {
+		series.Points[i].Time = from.Add(time.Duration(i) * step)
+	}
+	for _, sample := range samples {
+		i := int(sample.Time.Sub(from) / step)
+		if i < 0 || i >= count {
+			continue
+		}
+		series.Points[i].RxBytes += sample.RxBytes
+		series.Points[i].TxBytes += sample.TxBytes
+		series.RxBytes += sample.RxBytes
+		series.TxBytes += sample.TxBytes
+	}
+	for i := range series.Points {
+		series.Points[i].RxRate = float64(series.Points[i].RxBytes) / step.Seconds()
+		series.Points[i].TxRate = float64(series.Points[i].TxBytes) / step.Seconds()
+	}
+
+	return series, nil
+}
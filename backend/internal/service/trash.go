@@ -0,0 +1,310 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+// TrashEnabled reports whether deleted clients are moved to the trash
+func (s *RouteService) TrashEnabled() bool {
+	return s.trash != nil
+}
+
+// InitIPAllocations seeds IP allocation state from the stored clients and
+// the clients in the trash, and returns how many clients were stored
+func (s *RouteService) InitIPAllocations() (int, error) {
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	s.reseedIPs(clients)
+	return len(clients), nil
+}
+
+// updateTrash moves committed deletions into the trash and drops restored
+// entries. On failure the trash is left as it was.
+func (s *RouteService) updateTrash(trashed []model.Client, untrashed []uint) error {
+	if s.trash == nil {
+		return nil
+	}
+
+	var added []uint
+	if len(trashed) > 0 {
+		now := time.Now().UTC()
+		entries := make([]model.TrashEntry, 0, len(trashed))
+		for _, client := range trashed {
+			entries = append(entries, model.TrashEntry{
+				Cluster:   client.Cluster,
+				Identity:  client.Identity,
+				Name:      client.Name,
+				DeletedAt: now,
+				DeletedBy: s.actor.Name,
+				Source:    s.actor.Source,
+				Client:    client,
+			})
+		}
+		if err := s.trash.Add(entries...); err != nil {
+			return fmt.Errorf("failed to move %d clients to trash: %w", len(entries), err)
+		}
+		for _, entry := range entries {
+			added = append(added, entry.ID)
+		}
+	}
+
+	if err := s.trash.Remove(untrashed...); err != nil {
+		if err := s.trash.Remove(added...); err != nil {
+			log.Printf("[Trash] Failed to remove %d entries added before a failure: %v", len(added), err)
+		}
+		return fmt.Errorf("failed to remove %d restored entries from trash: %w", len(untrashed), err)
+	}
+	return nil
+}
+
+// trashedClients returns the clients in the trash, whose IPs stay reserved
+func (s *RouteService) trashedClients() []model.Client {
+	if s.trash == nil {
+		return nil
+	}
+
+	entries, err := s.trash.List(model.TrashFilter{})
+	if err != nil {
+		log.Printf("[Trash] Failed to list trash: %v", err)
+		return nil
+	}
+
+	clients := make([]model.Client, 0, len(entries))
+	for _, entry := range entries {
+		clients = append(clients, entry.Client)
+	}
+	return clients
+}
+
+// ListTrash returns the deleted clients, most recently deleted first
+func (s *RouteService) ListTrash(clusterName string) ([]model.TrashEntry, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	return s.trash.List(model.TrashFilter{Cluster: clusterName})
+}
+
+// GetTrashEntry returns a single trash entry
+func (s *RouteService) GetTrashEntry(id uint) (*model.TrashEntry, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+	return s.trash.Get(id)
+}
+
+// RestoreFromTrash recreates a deleted client with its previous IP. An
+// expiry that has passed is removed, so the client is not expired again
+// right away.
+func (s *RouteService) RestoreFromTrash(id uint) (*model.Client, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	entry, err := s.trash.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored *model.Client
+	err = s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		state.comment = fmt.Sprintf("restore from trash entry %d", id)
+		var err error
+		restored, err = restoreInTx(tx, state, *entry, entry.Client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// RestoreClientFromTrash recreates the most recently deleted version of a
+// client
+func (s *RouteService) RestoreClientFromTrash(clusterName, identity string) (*model.Client, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	entries, err := s.trash.List(model.TrashFilter{Cluster: clusterName, Identity: identity})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("trash entry not found")
+	}
+
+	return s.RestoreFromTrash(entries[0].ID)
+}
+
+// RestoreClusterFromTrash recreates, in a single transaction, the most
+// recently deleted version of every client of a cluster in the trash
+func (s *RouteService) RestoreClusterFromTrash(clusterName string) ([]model.Client, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	entries, err := s.trash.List(model.TrashFilter{Cluster: clusterName})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("trash entry not found")
+	}
+
+	restored := make([]model.Client, 0, len(entries))
+	err = s.transact(func(tx repository.RouteRepository, state *batchState) error {
+		state.comment = fmt.Sprintf("restore cluster %s from trash", clusterName)
+
+		// Entries are listed newest first; older versions of a client stay
+		// in the trash
+		seen := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			if seen[entry.Identity] {
+				continue
+			}
+			seen[entry.Identity] = true
+
+			client, err := restoreInTx(tx, state, entry, entry.Client)
+			if err != nil {
+				return err
+			}
+			restored = append(restored, *client)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// PurgeTrash permanently removes a trash entry, releases its IP and returns
+// the removed entry
+func (s *RouteService) PurgeTrash(id uint) (*model.TrashEntry, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	entry, err := s.trash.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.purge([]model.TrashEntry{*entry}); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// PurgeTrashEntries permanently removes the trash entries of a cluster (or
+// of all clusters if empty) deleted before the given time (or at any time if
+// zero), releases their IPs and returns the removed entries
+func (s *RouteService) PurgeTrashEntries(clusterName string, deletedBefore time.Time) ([]model.TrashEntry, error) {
+	if s.trash == nil {
+		return nil, fmt.Errorf("trash is not enabled")
+	}
+
+	entries, err := s.trash.List(model.TrashFilter{Cluster: clusterName})
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make([]model.TrashEntry, 0, len(entries))
+	for _, entry := range entries {
+		if deletedBefore.IsZero() || entry.DeletedAt.Before(deletedBefore) {
+			purged = append(purged, entry)
+		}
+	}
+
+	if err := s.purge(purged); err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// purge removes trash entries and releases IPs that are no longer held by
+// a stored client or a remaining trash entry
+func (s *RouteService) purge(entries []model.TrashEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	if err := s.trash.Remove(ids...); err != nil {
+		return err
+	}
+
+	clients, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+	held := make(map[string]bool, len(clients))
+	for _, client := range append(clients, s.trashedClients()...) {
+		held[client.Cluster+"/"+client.PrivateIP] = true
+	}
+
+	for _, entry := range entries {
+		if !held[entry.Cluster+"/"+entry.Client.PrivateIP] {
+			s.ipManager.ReleaseIP(entry.Cluster, entry.Client.PrivateIP)
+		}
+	}
+	return nil
+}
+
+// restoreInTx recreates the client of a trash entry as client, which may
+// differ from the trashed version when rolling back to an older revision,
+// and returns the restored client. An expiry that has already passed is
+// removed. The entry is removed from the trash once the transaction commits.
+func restoreInTx(tx repository.RouteRepository, state *batchState, entry model.TrashEntry, client model.Client) (*model.Client, error) {
+	if _, err := tx.GetByClusterAndIdentity(client.Cluster, client.Identity); err == nil {
+		return nil, fmt.Errorf("%w: client %s/%s already exists", ErrValidation, client.Cluster, client.Identity)
+	} else if err.Error() != "client not found" {
+		return nil, err
+	}
+	if client.IsExpired(time.Now()) {
+		client.ExpiresAt = nil
+	}
+
+	// The trashed client still holds its IP; only a different address needs
+	// to be reserved
+	if client.PrivateIP != entry.Client.PrivateIP {
+		if err := state.reserve(client.Cluster, client.PrivateIP); err != nil {
+			return nil, err
+		}
+		state.release(entry.Client.Cluster, entry.Client.PrivateIP)
+	} else {
+		clients, err := tx.GetByCluster(client.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range clients {
+			if other.PrivateIP == client.PrivateIP {
+				return nil, fmt.Errorf("%w: IP %s is now used by %s/%s", ErrValidation, client.PrivateIP, other.Cluster, other.Identity)
+			}
+		}
+	}
+
+	if err := tx.Create(client); err != nil {
+		return nil, err
+	}
+	state.created(&client)
+	state.untrashed = append(state.untrashed, entry.ID)
+	return &client, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// trashPurgerActor is recorded as the actor of purges done by the purger
+const trashPurgerActor = "system:trash-purger"
+
+// TrashPurger periodically removes trash entries older than the retention
+// period and releases their IPs
+type TrashPurger struct {
+	routeService *RouteService
+	auditService *AuditService
+	interval     time.Duration
+	retention    time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewTrashPurger creates a purger that runs every interval and removes
+// entries deleted more than retention ago
+func NewTrashPurger(routeService *RouteService, auditService *AuditService, interval, retention time.Duration) *TrashPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &TrashPurger{
+		routeService: routeService.WithActor(model.Actor{Name: trashPurgerActor, Source: model.SourceSystem}),
+		auditService: auditService,
+		interval:     interval,
+		retention:    retention,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start runs the purger loop in the background
+func (p *TrashPurger) Start() {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		p.runAndLog()
+		for {
+			select {
+			case <-ticker.C:
+				p.runAndLog()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates the purger loop and waits for it to exit
+func (p *TrashPurger) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+func (p *TrashPurger) runAndLog() {
+	count, err := p.RunOnce(time.Now())
+	if err != nil {
+		log.Printf("[Trash] Purge failed: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[Trash] Purged %d client(s) deleted more than %s ago", count, p.retention)
+	}
+}
+
+// RunOnce purges every entry deleted more than the retention period before
+// now and returns how many entries were purged
+func (p *TrashPurger) RunOnce(now time.Time) (int, error) {
+	purged, err := p.routeService.PurgeTrashEntries("", now.Add(-p.retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	for _, entry := range purged {
+		detail := fmt.Sprintf("purged (deleted at %s, ip %s released)", entry.DeletedAt.Format(time.RFC3339), entry.Client.PrivateIP)
+		p.auditService.Record(trashPurgerActor, "client.purged", entry.Cluster, entry.Identity, detail)
+	}
+	return len(purged), nil
+}
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned for unknown users, wrong passwords and
+// disabled accounts alike, so callers cannot probe which accounts exist
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72 // bcrypt ignores anything longer
+
+	// credentialCacheTTL is how long a verified password is remembered, so
+	// Basic Auth requests do not all pay for a bcrypt comparison
+	credentialCacheTTL = time.Minute
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// UserService manages dashboard accounts and checks their passwords
+type UserService struct {
+	repo         repository.UserRepository
+	auditService *AuditService
+
+	// dummyHash is compared against for unknown users so they take as long
+	// to reject as wrong passwords
+	dummyHash []byte
+
+	mu       sync.Mutex
+	verified map[uint]verifiedCredential
+	onDelete []func(user model.User)
+}
+
+// verifiedCredential remembers a password that matched a user's hash
+type verifiedCredential struct {
+	hash   string // The hash the password matched; a new password invalidates the entry
+	digest [sha256.Size]byte
+	until  time.Time
+}
+
+// NewUserService creates a new user service
+func NewUserService(repo repository.UserRepository, auditService *AuditService) *UserService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("rustun-dashboard"), bcrypt.DefaultCost)
+	return &UserService{
+		repo:         repo,
+		auditService: auditService,
+		dummyHash:    dummyHash,
+		verified:     make(map[uint]verifiedCredential),
+	}
+}
+
+// OnDelete registers a function called with every deleted user, e.g. to
+// remove what belongs to the account
+func (s *UserService) OnDelete(fn func(user model.User)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onDelete = append(s.onDelete, fn)
+}
+
+// EnsureDefaultUser creates the initial account from the configured
+// credentials when there are no users yet. The account is a global admin
+// and has to change its password on first login. If accounts exist but
+// none of them is a global admin, e.g. after an upgrade from accounts
+// without roles, the configured account is made one.
+func (s *UserService) EnsureDefaultUser(username, password string) error {
+	count, err := s.repo.Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return s.ensureAdmin(username)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	user := &model.User{
+		Username:           username,
+		PasswordHash:       hash,
+		MustChangePassword: true,
+		Roles:              []model.RoleBinding{{Role: model.RoleAdmin}},
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.repo.Create(user); err != nil {
+		return err
+	}
+
+	log.Printf("[Users] Created default account %q; its password must be changed on first login", username)
+	s.auditService.Record("system", "user.created", "", "", fmt.Sprintf("user %s (default account)", username))
+	return nil
+}
+
+// ensureAdmin makes the given user a global admin unless an enabled global
+// admin exists
+func (s *UserService) ensureAdmin(username string) error {
+	users, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+	if hasGlobalAdmin(users) {
+		return nil
+	}
+
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		log.Printf("[Users] Warning: no account is a global admin and %q does not exist", username)
+		return nil
+	}
+	user.Roles = append(user.Roles, model.RoleBinding{Role: model.RoleAdmin})
+	user.Disabled = false
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(*user); err != nil {
+		return err
+	}
+
+	log.Printf("[Users] No account was a global admin; granted admin to %q", user.Username)
+	s.auditService.Record("system", "user.updated", "", "", fmt.Sprintf("user %s: granted global admin", user.Username))
+	return nil
+}
+
+// Authenticate checks a username and password and returns the account
+func (s *UserService) Authenticate(username, password string) (*model.User, error) {
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		if err.Error() == "user not found" {
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if !s.checkPassword(user, password) || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// checkPassword compares a password against the user's hash, consulting the
+// cache of recently verified passwords first
+func (s *UserService) checkPassword(user *model.User, password string) bool {
+	digest := sha256.Sum256([]byte(password))
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.verified[user.ID]
+	s.mu.Unlock()
+	if ok && cached.hash == user.PasswordHash && cached.digest == digest && now.Before(cached.until) {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	s.verified[user.ID] = verifiedCredential{hash: user.PasswordHash, digest: digest, until: now.Add(credentialCacheTTL)}
+	s.mu.Unlock()
+	return true
+}
+
+// Login authenticates an interactive login and records it
+func (s *UserService) Login(username, password string) (*model.User, error) {
+	user, err := s.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.auditService.Record(username, "user.login_failed", "", "", "invalid username or password")
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user.LastLoginAt = &now
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(user.Username, "user.login", "", "", "")
+	return user, nil
+}
+
+// ListUsers returns all users ordered by username
+func (s *UserService) ListUsers() ([]model.User, error) {
+	return s.repo.List()
+}
+
+// GetUser returns a single user
+func (s *UserService) GetUser(id uint) (*model.User, error) {
+	return s.repo.Get(id)
+}
+
+// GetUserByUsername returns the user with the given username
+func (s *UserService) GetUserByUsername(username string) (*model.User, error) {
+	return s.repo.GetByUsername(username)
+}
+
+// CreateUser creates an account. Unless the request says otherwise, the
+// user has to change the password on first login.
+func (s *UserService) CreateUser(actor string, req model.CreateUserRequest) (*model.User, error) {
+	if !usernamePattern.MatchString(req.Username) {
+		return nil, fmt.Errorf("%w: invalid username %q: use up to 64 letters, digits and . _ @ -, starting with a letter or digit", ErrValidation, req.Username)
+	}
+	if err := validatePassword(req.Username, req.Password); err != nil {
+		return nil, err
+	}
+	roles := req.Roles
+	if roles == nil {
+		roles = []model.RoleBinding{{Role: model.RoleViewer}}
+	}
+	if err := validateRoles(roles); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	user := &model.User{
+		Username:           req.Username,
+		DisplayName:        req.DisplayName,
+		PasswordHash:       hash,
+		MustChangePassword: true,
+		Roles:              roles,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if req.MustChangePassword != nil {
+		user.MustChangePassword = *req.MustChangePassword
+	}
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "user.created", "", "", fmt.Sprintf("user %s", user.Username))
+	return user, nil
+}
+
+// UpdateUser changes an account's display name, password or flags
+func (s *UserService) UpdateUser(actor string, id uint, req model.UpdateUserRequest) (*model.User, error) {
+	user, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]string, 0)
+	if req.DisplayName != nil && *req.DisplayName != user.DisplayName {
+		user.DisplayName = *req.DisplayName
+		changes = append(changes, "display name")
+	}
+	if req.Password != nil {
+		if err := validatePassword(user.Username, *req.Password); err != nil {
+			return nil, err
+		}
+		if err := setPassword(user, *req.Password); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "password reset")
+	}
+	if req.MustChangePassword != nil && *req.MustChangePassword != user.MustChangePassword {
+		user.MustChangePassword = *req.MustChangePassword
+		changes = append(changes, fmt.Sprintf("must_change_password=%t", user.MustChangePassword))
+	}
+	if req.Disabled != nil && *req.Disabled != user.Disabled {
+		if *req.Disabled && user.Username == actor {
+			return nil, fmt.Errorf("%w: you cannot disable your own account", ErrValidation)
+		}
+		user.Disabled = *req.Disabled
+		changes = append(changes, fmt.Sprintf("disabled=%t", user.Disabled))
+	}
+	if req.Roles != nil {
+		if err := validateRoles(*req.Roles); err != nil {
+			return nil, err
+		}
+		user.Roles = append([]model.RoleBinding{}, (*req.Roles)...)
+		changes = append(changes, "roles "+formatRoles(user.Roles))
+	}
+
+	if len(changes) == 0 {
+		return user, nil
+	}
+	if err := s.checkAdminRemains(*user); err != nil {
+		return nil, err
+	}
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, "user.updated", "", "", fmt.Sprintf("user %s: %s", user.Username, strings.Join(changes, ", ")))
+	return user, nil
+}
+
+// DeleteUser removes an account. Users cannot delete themselves.
+func (s *UserService) DeleteUser(actor string, id uint) error {
+	user, err := s.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	if user.Username == actor {
+		return fmt.Errorf("%w: you cannot delete your own account", ErrValidation)
+	}
+	user.Disabled = true
+	if err := s.checkAdminRemains(*user); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.verified, id)
+	listeners := s.onDelete
+	s.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(*user)
+	}
+
+	s.auditService.Record(actor, "user.deleted", "", "", fmt.Sprintf("user %s", user.Username))
+	return nil
+}
+
+// ChangePassword changes the password of the given user after checking the
+// current one, and lifts a pending forced password change
+func (s *UserService) ChangePassword(username string, req model.ChangePasswordRequest) (*model.User, error) {
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if !s.checkPassword(user, req.CurrentPassword) {
+		return nil, fmt.Errorf("%w: current password is incorrect", ErrValidation)
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return nil, fmt.Errorf("%w: the new password must differ from the current one", ErrValidation)
+	}
+	if err := validatePassword(user.Username, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if err := setPassword(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	user.MustChangePassword = false
+	user.UpdatedAt = *user.PasswordChangedAt
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(user.Username, "user.password_changed", "", "", "")
+	return user, nil
+}
+
+// checkAdminRemains fails if replacing a user with the changed version
+// would leave no enabled global admin
+func (s *UserService) checkAdminRemains(changed model.User) error {
+	users, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if users[i].ID == changed.ID {
+			users[i] = changed
+		}
+	}
+	if !hasGlobalAdmin(users) {
+		return fmt.Errorf("%w: at least one enabled user must remain a global admin", ErrValidation)
+	}
+	return nil
+}
+
+// hasGlobalAdmin reports whether an enabled user is an admin of all clusters
+func hasGlobalAdmin(users []model.User) bool {
+	for i := range users {
+		if !users[i].Disabled && users[i].Allows(model.RoleAdmin, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRoles checks role names and rejects repeated bindings
+func validateRoles(roles []model.RoleBinding) error {
+	seen := make(map[string]bool)
+	for _, binding := range roles {
+		if !model.ValidRole(binding.Role) {
+			return fmt.Errorf("%w: unknown role %q (expected viewer, operator or admin)", ErrValidation, binding.Role)
+		}
+		if seen[binding.Cluster] {
+			if binding.Cluster == "" {
+				return fmt.Errorf("%w: only one global role can be assigned", ErrValidation)
+			}
+			return fmt.Errorf("%w: only one role can be assigned per cluster, %s has several", ErrValidation, binding.Cluster)
+		}
+		seen[binding.Cluster] = true
+	}
+	return nil
+}
+
+// formatRoles describes role bindings for the audit log, e.g.
+// "[admin operator@production]"
+func formatRoles(roles []model.RoleBinding) string {
+	parts := make([]string, 0, len(roles))
+	for _, binding := range roles {
+		if binding.Cluster == "" {
+			parts = append(parts, binding.Role)
+		} else {
+			parts = append(parts, binding.Role+"@"+binding.Cluster)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// validatePassword enforces the password policy
+func validatePassword(username, password string) error {
+	if len(password) < minPasswordLen {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrValidation, minPasswordLen)
+	}
+	if len(password) > maxPasswordLen {
+		return fmt.Errorf("%w: password must be at most %d bytes", ErrValidation, maxPasswordLen)
+	}
+	if password == username {
+		return fmt.Errorf("%w: password must differ from the username", ErrValidation)
+	}
+	return nil
+}
+
+func setPassword(user *model.User, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
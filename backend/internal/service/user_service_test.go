@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+	"github.com/smartethnet/rustun-dashboard/internal/repository"
+)
+
+const testPassword = "correct-horse"
+
+func newTestUserService(t *testing.T) (*UserService, *AuditService) {
+	t.Helper()
+	dir := t.TempDir()
+	auditService := NewAuditService(repository.NewFileAuditRepository(filepath.Join(dir, "audit.json")))
+	return NewUserService(repository.NewFileUserRepository(filepath.Join(dir, "users.json")), auditService), auditService
+}
+
+// createTestUser creates an account with testPassword that need not change it
+func createTestUser(t *testing.T, users *UserService, username string, roles ...model.RoleBinding) *model.User {
+	t.Helper()
+	mustChange := false
+	user, err := users.CreateUser("root", model.CreateUserRequest{
+		Username:           username,
+		Password:           testPassword,
+		Roles:              roles,
+		MustChangePassword: &mustChange,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", username, err)
+	}
+	return user
+}
+
+func TestLastAdminRemains(t *testing.T) {
+	globalAdmin := model.RoleBinding{Role: model.RoleAdmin}
+	clusterAdmin := model.RoleBinding{Role: model.RoleAdmin, Cluster: "prod"}
+	disabled := true
+
+	tests := []struct {
+		name       string
+		otherRoles []model.RoleBinding // Roles of a second account; none if nil
+		change     func(users *UserService, admin *model.User) error
+		rejected   bool
+	}{
+		{
+			name: "demote the last admin",
+			change: func(users *UserService, admin *model.User) error {
+				_, err := users.UpdateUser("root", admin.ID, model.UpdateUserRequest{Roles: &[]model.RoleBinding{{Role: model.RoleOperator}}})
+				return err
+			},
+			rejected: true,
+		},
+		{
+			name: "limit the last admin to a cluster",
+			change: func(users *UserService, admin *model.User) error {
+				_, err := users.UpdateUser("root", admin.ID, model.UpdateUserRequest{Roles: &[]model.RoleBinding{clusterAdmin}})
+				return err
+			},
+			rejected: true,
+		},
+		{
+			name: "disable the last admin",
+			change: func(users *UserService, admin *model.User) error {
+				_, err := users.UpdateUser("root", admin.ID, model.UpdateUserRequest{Disabled: &disabled})
+				return err
+			},
+			rejected: true,
+		},
+		{
+			name: "delete the last admin",
+			change: func(users *UserService, admin *model.User) error {
+				return users.DeleteUser("root", admin.ID)
+			},
+			rejected: true,
+		},
+		{
+			name:       "delete the last global admin beside a cluster admin",
+			otherRoles: []model.RoleBinding{clusterAdmin},
+			change: func(users *UserService, admin *model.User) error {
+				return users.DeleteUser("root", admin.ID)
+			},
+			rejected: true,
+		},
+		{
+			name:       "delete an admin beside another",
+			otherRoles: []model.RoleBinding{globalAdmin},
+			change: func(users *UserService, admin *model.User) error {
+				return users.DeleteUser("root", admin.ID)
+			},
+		},
+		{
+			name:       "demote an admin beside another",
+			otherRoles: []model.RoleBinding{globalAdmin},
+			change: func(users *UserService, admin *model.User) error {
+				_, err := users.UpdateUser("root", admin.ID, model.UpdateUserRequest{Roles: &[]model.RoleBinding{{Role: model.RoleViewer}}})
+				return err
+			},
+		},
+		{
+			name: "rename the last admin",
+			change: func(users *UserService, admin *model.User) error {
+				name := "Administrator"
+				_, err := users.UpdateUser("root", admin.ID, model.UpdateUserRequest{DisplayName: &name})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, _ := newTestUserService(t)
+			admin := createTestUser(t, users, "admin", globalAdmin)
+			if tt.otherRoles != nil {
+				createTestUser(t, users, "other", tt.otherRoles...)
+			}
+
+			err := tt.change(users, admin)
+			if tt.rejected {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("got error %v, want ErrValidation", err)
+				}
+				stored, err := users.GetUser(admin.ID)
+				if err != nil {
+					t.Fatalf("the admin is gone: %v", err)
+				}
+				if stored.Disabled || !stored.Allows(model.RoleAdmin, "") {
+					t.Errorf("the rejected change was stored: %+v", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestChangePasswordLiftsForcedChange(t *testing.T) {
+	users, _ := newTestUserService(t)
+	if err := users.EnsureDefaultUser("admin", testPassword); err != nil {
+		t.Fatalf("EnsureDefaultUser: %v", err)
+	}
+	user, err := users.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if !user.MustChangePassword {
+		t.Fatal("the default user need not change its password")
+	}
+
+	tests := []struct {
+		name     string
+		current  string
+		new      string
+		rejected bool
+	}{
+		{"wrong current password", "wrong-password", "another-password", true},
+		{"same password", testPassword, testPassword, true},
+		{"too short", testPassword, "short", true},
+		{"new password", testPassword, "another-password", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := users.ChangePassword("admin", model.ChangePasswordRequest{CurrentPassword: tt.current, NewPassword: tt.new})
+			if tt.rejected {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("got error %v, want ErrValidation", err)
+				}
+				stored, _ := users.GetUserByUsername("admin")
+				if !stored.MustChangePassword {
+					t.Error("a rejected change lifted the forced password change")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user.MustChangePassword {
+				t.Error("the forced password change was not lifted")
+			}
+		})
+	}
+}
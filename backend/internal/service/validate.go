@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/smartethnet/rustun-dashboard/internal/lint"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// ValidateRoutes checks a routes document with the lint rules. An empty
+// document checks the current configuration instead, including disabled
+// clients since they keep their address reservations.
+func (s *RouteService) ValidateRoutes(data []byte) (*model.LintReport, error) {
+	if len(bytes.TrimSpace(data)) > 0 {
+		return lint.CheckDocument(data), nil
+	}
+
+	clients, err := s.ExportClients("")
+	if err != nil {
+		return nil, err
+	}
+	return lint.Check(clients), nil
+}
+
+// rejectNewLintErrors fails with ErrValidation if after has lint errors
+// that before did not have, so a change cannot break the configuration
+// while existing problems do not block unrelated changes
+func rejectNewLintErrors(before, after []model.Client) error {
+	key := func(f model.LintFinding) string {
+		return f.Rule + "|" + f.Cluster + "|" + f.Identity + "|" + f.Field
+	}
+
+	existing := make(map[string]bool)
+	for _, f := range lint.Check(before).Findings {
+		if f.Severity == model.LintError {
+			existing[key(f)] = true
+		}
+	}
+	for _, f := range lint.Check(after).Findings {
+		if f.Severity == model.LintError && !existing[key(f)] {
+			return fmt.Errorf("%w: %s/%s: %s", ErrValidation, f.Cluster, f.Identity, f.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/smartethnet/rustun-dashboard/internal/lint"
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// validateClientFields checks the cluster, name, addresses and routes of a
+// client. Empty address fields are allowed because they are filled in on
+// allocation.
+func validateClientFields(client model.Client) error {
+	problems := make([]string, 0)
+
+	if client.Cluster == "" {
+		problems = append(problems, "cluster is required")
+	} else if err := lint.ValidateClusterName(client.Cluster); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateClientName(client.Name); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if client.PrivateIP != "" && net.ParseIP(client.PrivateIP).To4() == nil {
+		problems = append(problems, fmt.Sprintf("invalid private_ip %q", client.PrivateIP))
+	}
+	if client.Gateway != "" && net.ParseIP(client.Gateway).To4() == nil {
+		problems = append(problems, fmt.Sprintf("invalid gateway %q", client.Gateway))
+	}
+	if client.Mask != "" && !lint.ValidMask(client.Mask) {
+		problems = append(problems, fmt.Sprintf("invalid mask %q", client.Mask))
+	}
+	for _, cidr := range client.Ciders {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid CIDR %q", cidr))
+		}
+	}
+	if err := lint.ValidateLabels(client.Labels); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateClientName rejects names with newlines and other control
+// characters, which would break out of the comments of generated scripts
+func validateClientName(name string) error {
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid name %q: must not contain control characters", name)
+	}
+	return nil
+}
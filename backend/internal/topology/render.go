@@ -0,0 +1,152 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Colors of client states and edge reachability in rendered graphs
+var (
+	statusColors = map[string]string{
+		model.PresenceOnline:  "#c8e6c9",
+		model.PresenceStale:   "#fff9c4",
+		model.PresenceOffline: "#eeeeee",
+	}
+	reachColors = map[string]string{
+		model.ReachUp:       "#2e7d32",
+		model.ReachDegraded: "#f9a825",
+		model.ReachDown:     "#9e9e9e",
+		model.ReachDisabled: "#bdbdbd",
+		model.ReachConflict: "#c62828",
+	}
+)
+
+// DOT renders a topology as an undirected Graphviz graph
+func DOT(topo *model.Topology) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "graph %s {\n", dotQuote(topo.Cluster))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [fontname=\"Helvetica\"];\n")
+
+	for _, node := range topo.Nodes {
+		var attrs string
+		switch node.Type {
+		case model.TopologyGateway:
+			attrs = fmt.Sprintf("label=%s, shape=doublecircle", dotQuote("gateway\n"+node.IP))
+		case model.TopologyClient:
+			fill := statusColors[node.Status]
+			if node.Enabled != nil && !*node.Enabled {
+				fill = statusColors[model.PresenceOffline]
+			}
+			attrs = fmt.Sprintf("label=%s, shape=box, style=\"rounded,filled\", fillcolor=%s", dotQuote(node.Label+"\n"+node.IP), dotQuote(fill))
+			if node.Enabled != nil && !*node.Enabled {
+				attrs += ", fontcolor=\"#9e9e9e\""
+			}
+		case model.TopologySubnet:
+			attrs = fmt.Sprintf("label=%s, shape=note", dotQuote(node.CIDR))
+			if node.Conflict {
+				attrs += fmt.Sprintf(", color=%s", dotQuote(reachColors[model.ReachConflict]))
+			}
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.ID), attrs)
+	}
+
+	for _, edge := range topo.Edges {
+		style := "solid"
+		if edge.Type == model.TopologyRoute {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %s -- %s [label=%s, style=%s, color=%s];\n",
+			dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Reachability), style, dotQuote(reachColors[edge.Reachability]))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders a topology as a Mermaid flowchart
+func Mermaid(topo *model.Topology) string {
+	// Mermaid node IDs cannot contain the colons and dots of topology IDs
+	ids := make(map[string]string, len(topo.Nodes))
+	for i, node := range topo.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	classes := make(map[string][]string)
+	for _, node := range topo.Nodes {
+		id := ids[node.ID]
+		switch node.Type {
+		case model.TopologyGateway:
+			fmt.Fprintf(&b, "  %s((%s))\n", id, mermaidQuote("gateway<br/>"+mermaidEscape(node.IP)))
+		case model.TopologyClient:
+			fmt.Fprintf(&b, "  %s(%s)\n", id, mermaidQuote(mermaidEscape(node.Label)+"<br/>"+mermaidEscape(node.IP)))
+			class := node.Status
+			if node.Enabled != nil && !*node.Enabled {
+				class = model.ReachDisabled
+			}
+			classes[class] = append(classes[class], id)
+		case model.TopologySubnet:
+			fmt.Fprintf(&b, "  %s{{%s}}\n", id, mermaidQuote(mermaidEscape(node.CIDR)))
+			if node.Conflict {
+				classes[model.ReachConflict] = append(classes[model.ReachConflict], id)
+			}
+		}
+	}
+
+	for i, edge := range topo.Edges {
+		link := "---"
+		if edge.Type == model.TopologyRoute {
+			link = "-.-"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[edge.From], link, edge.Reachability, ids[edge.To])
+		fmt.Fprintf(&b, "  linkStyle %d stroke:%s\n", i, reachColors[edge.Reachability])
+	}
+
+	for _, class := range []string{model.PresenceOnline, model.PresenceStale, model.PresenceOffline, model.ReachDisabled, model.ReachConflict} {
+		if len(classes[class]) == 0 {
+			continue
+		}
+		switch class {
+		case model.ReachDisabled:
+			fmt.Fprintf(&b, "  classDef %s fill:%s,color:#9e9e9e\n", class, statusColors[model.PresenceOffline])
+		case model.ReachConflict:
+			fmt.Fprintf(&b, "  classDef %s stroke:%s,stroke-width:2px\n", class, reachColors[model.ReachConflict])
+		default:
+			fmt.Fprintf(&b, "  classDef %s fill:%s\n", class, statusColors[class])
+		}
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[class], ","), class)
+	}
+
+	return b.String()
+}
+
+// dotQuote quotes a DOT ID, turning newlines into line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + s + `"`
+}
+
+// mermaidEscape replaces the characters Mermaid labels cannot contain with
+// entity codes, turns newlines into line breaks and drops other control
+// characters, which would end the label
+func mermaidEscape(s string) string {
+	s = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\r\n", "\n", "\r", "\n").Replace(s)
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
@@ -0,0 +1,311 @@
+// Package topology computes the network graph of a cluster: the gateway,
+// the clients tunnelled to it and the LAN subnets they route, annotated
+// with overlapping routes and reachability. Graphs can be rendered as
+// Graphviz DOT and Mermaid.
+package topology
+
+import (
+	"net"
+	"sort"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// route is a subnet advertised by a client
+type route struct {
+	cidr     string // Normalized, e.g. 192.168.1.0/24
+	network  *net.IPNet
+	identity string
+}
+
+// Build computes the topology of a cluster from its clients
+func Build(cluster string, clients []model.ClientWithStatus) *model.Topology {
+	clients = append([]model.ClientWithStatus(nil), clients...)
+	sort.SliceStable(clients, func(i, j int) bool {
+		return ipLess(clients[i].PrivateIP, clients[j].PrivateIP)
+	})
+
+	topo := &model.Topology{
+		Cluster:  cluster,
+		Networks: make([]string, 0),
+		Nodes:    make([]model.TopologyNode, 0),
+		Edges:    make([]model.TopologyEdge, 0),
+		Overlaps: make([]model.TopologyOverlap, 0),
+	}
+
+	// Gateways and tunnel networks
+	gateways := make(map[string]bool)
+	networks := make(map[string]*net.IPNet)
+	for _, client := range clients {
+		if client.Gateway != "" && !gateways[client.Gateway] {
+			gateways[client.Gateway] = true
+			topo.Nodes = append(topo.Nodes, model.TopologyNode{
+				ID:    gatewayID(client.Gateway),
+				Type:  model.TopologyGateway,
+				Label: "gateway " + client.Gateway,
+				IP:    client.Gateway,
+			})
+		}
+		if network := tunnelNetwork(client.PrivateIP, client.Mask); network != nil {
+			if _, ok := networks[network.String()]; !ok {
+				networks[network.String()] = network
+				topo.Networks = append(topo.Networks, network.String())
+			}
+		}
+	}
+
+	// Clients, their tunnels and the routes they advertise
+	reach := make(map[string]string)
+	var routes []route
+	known := make(map[string]bool)
+	var subnets []string
+	for _, client := range clients {
+		enabled := client.Enabled
+		state := model.PresenceOffline
+		if client.Status != nil {
+			state = client.Status.State
+		}
+
+		label := client.Name
+		if label == "" {
+			label = client.Identity
+		}
+		topo.Nodes = append(topo.Nodes, model.TopologyNode{
+			ID:       clientID(client.Identity),
+			Type:     model.TopologyClient,
+			Label:    label,
+			IP:       client.PrivateIP,
+			Identity: client.Identity,
+			Name:     client.Name,
+			Enabled:  &enabled,
+			Status:   state,
+		})
+
+		topo.Summary.Clients++
+		switch {
+		case !enabled:
+			topo.Summary.Disabled++
+		case state == model.PresenceOnline:
+			topo.Summary.Online++
+		case state == model.PresenceStale:
+			topo.Summary.Stale++
+		default:
+			topo.Summary.Offline++
+		}
+
+		tunnel := tunnelReach(enabled, state)
+		reach[client.Identity] = tunnel
+		if client.Gateway != "" {
+			topo.Edges = append(topo.Edges, model.TopologyEdge{
+				ID:           "tunnel:" + client.Identity,
+				Type:         model.TopologyTunnel,
+				From:         clientID(client.Identity),
+				To:           gatewayID(client.Gateway),
+				Reachability: tunnel,
+			})
+		}
+
+		seen := make(map[string]bool)
+		for _, raw := range client.Ciders {
+			cidr := raw
+			_, network, err := net.ParseCIDR(raw)
+			if err == nil {
+				cidr = network.String()
+			}
+			if seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+
+			if !known[cidr] {
+				known[cidr] = true
+				subnets = append(subnets, cidr)
+			}
+			if enabled && network != nil {
+				routes = append(routes, route{cidr: cidr, network: network, identity: client.Identity})
+			}
+		}
+	}
+
+	conflicts := findOverlaps(topo, routes, networks)
+
+	sort.Strings(subnets)
+	for _, cidr := range subnets {
+		topo.Nodes = append(topo.Nodes, model.TopologyNode{
+			ID:       subnetID(cidr),
+			Type:     model.TopologySubnet,
+			Label:    cidr,
+			CIDR:     cidr,
+			Conflict: conflicts[cidr] != nil,
+		})
+	}
+	topo.Summary.Subnets = len(subnets)
+
+	for _, client := range clients {
+		seen := make(map[string]bool)
+		for _, raw := range client.Ciders {
+			cidr := raw
+			if _, network, err := net.ParseCIDR(raw); err == nil {
+				cidr = network.String()
+			}
+			if seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+
+			reachability := reach[client.Identity]
+			if conflicts[cidr][client.Identity] && (reachability == model.ReachUp || reachability == model.ReachDegraded) {
+				reachability = model.ReachConflict
+			}
+			topo.Edges = append(topo.Edges, model.TopologyEdge{
+				ID:           "route:" + client.Identity + ":" + cidr,
+				Type:         model.TopologyRoute,
+				From:         clientID(client.Identity),
+				To:           subnetID(cidr),
+				Reachability: reachability,
+			})
+		}
+	}
+
+	return topo
+}
+
+// findOverlaps records overlapping routes of different clients and routes
+// overlapping a tunnel network. It returns, per subnet, the clients whose
+// route to it conflicts.
+func findOverlaps(topo *model.Topology, routes []route, networks map[string]*net.IPNet) map[string]map[string]bool {
+	conflicts := make(map[string]map[string]bool)
+	mark := func(r route) {
+		if conflicts[r.cidr] == nil {
+			conflicts[r.cidr] = make(map[string]bool)
+		}
+		conflicts[r.cidr][r.identity] = true
+	}
+
+	type pair struct{ cidr, other string }
+	overlaps := make(map[pair]*model.TopologyOverlap)
+	var order []pair
+	add := func(kind, cidr, other string, identities ...string) {
+		key := pair{cidr, other}
+		overlap, ok := overlaps[key]
+		if !ok {
+			overlap = &model.TopologyOverlap{Kind: kind, CIDR: cidr, Other: other}
+			overlaps[key] = overlap
+			order = append(order, key)
+		}
+		for _, identity := range identities {
+			if !contains(overlap.Identities, identity) {
+				overlap.Identities = append(overlap.Identities, identity)
+			}
+		}
+	}
+
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			a, b := routes[i], routes[j]
+			if a.identity == b.identity || !overlap(a.network, b.network) {
+				continue
+			}
+			mark(a)
+			mark(b)
+
+			switch {
+			case a.cidr == b.cidr:
+				add(model.OverlapDuplicate, a.cidr, b.cidr, a.identity, b.identity)
+			case prefixLen(a.network) <= prefixLen(b.network):
+				add(model.OverlapContains, a.cidr, b.cidr, a.identity, b.identity)
+			default:
+				add(model.OverlapContains, b.cidr, a.cidr, b.identity, a.identity)
+			}
+		}
+
+		for name, network := range networks {
+			if overlap(routes[i].network, network) {
+				mark(routes[i])
+				add(model.OverlapTunnel, routes[i].cidr, name, routes[i].identity)
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].cidr != order[j].cidr {
+			return order[i].cidr < order[j].cidr
+		}
+		return order[i].other < order[j].other
+	})
+	for _, key := range order {
+		topo.Overlaps = append(topo.Overlaps, *overlaps[key])
+	}
+	topo.Summary.Overlaps = len(topo.Overlaps)
+
+	return conflicts
+}
+
+// tunnelReach derives the reachability of a client's tunnel
+func tunnelReach(enabled bool, state string) string {
+	switch {
+	case !enabled:
+		return model.ReachDisabled
+	case state == model.PresenceOnline:
+		return model.ReachUp
+	case state == model.PresenceStale:
+		return model.ReachDegraded
+	default:
+		return model.ReachDown
+	}
+}
+
+// tunnelNetwork returns the network of a client's tunnel IP
+func tunnelNetwork(ip, mask string) *net.IPNet {
+	parsedIP := net.ParseIP(ip).To4()
+	parsedMask := net.ParseIP(mask).To4()
+	if parsedIP == nil || parsedMask == nil {
+		return nil
+	}
+	m := net.IPMask(parsedMask)
+	return &net.IPNet{IP: parsedIP.Mask(m), Mask: m}
+}
+
+func overlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func prefixLen(network *net.IPNet) int {
+	ones, _ := network.Mask.Size()
+	return ones
+}
+
+// ipLess orders IP addresses numerically, with unparsable ones last
+func ipLess(a, b string) bool {
+	ipA, ipB := net.ParseIP(a).To16(), net.ParseIP(b).To16()
+	if ipA == nil || ipB == nil {
+		return ipA != nil
+	}
+	for i := range ipA {
+		if ipA[i] != ipB[i] {
+			return ipA[i] < ipB[i]
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func gatewayID(ip string) string {
+	return "gateway:" + ip
+}
+
+func clientID(identity string) string {
+	return "client:" + identity
+}
+
+func subnetID(cidr string) string {
+	return "subnet:" + cidr
+}
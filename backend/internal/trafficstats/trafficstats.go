@@ -0,0 +1,194 @@
+// Package trafficstats parses per-client byte counters from the text the
+// rustun server prints about its connections, so they can be piped into the
+// dashboard without a custom exporter.
+package trafficstats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/smartethnet/rustun-dashboard/internal/model"
+)
+
+// Two layouts are understood:
+//
+//	identity=laptop-01 cluster=office rx_bytes=1048576 tx_bytes=2048
+//
+// with the pairs anywhere in the line, e.g. after a log prefix, and tables
+// with a header naming the columns:
+//
+//	IDENTITY    CLUSTER  RX        TX
+//	laptop-01   office   1.5 MiB   2 KiB
+//
+// Counters may carry a unit: B, K/KB/KiB, M/MB/MiB, G/GB/GiB or T/TB/TiB,
+// all taken as powers of 1024.
+
+var (
+	pairPattern  = regexp.MustCompile(`\b([A-Za-z_]+)=(\S+)`)
+	unitSpacing  = regexp.MustCompile(`(\d)\s+([KMGTkmgt]?i?[Bb])\b`)
+	counterValue = regexp.MustCompile(`^(\d+(?:\.\d+)?)([KMGTkmgt]?)(?:i?[Bb])?$`)
+)
+
+// Column and key names, lower-cased
+var (
+	identityNames = map[string]bool{"identity": true, "id": true, "client": true}
+	clusterNames  = map[string]bool{"cluster": true}
+	rxNames       = map[string]bool{"rx": true, "rx_bytes": true, "rxbytes": true, "recv": true, "received": true}
+	txNames       = map[string]bool{"tx": true, "tx_bytes": true, "txbytes": true, "sent": true}
+)
+
+// Parse reads counters from rustun stats output. Lines that are neither a
+// header nor carry an identity and both counters are skipped; malformed
+// counters are errors.
+func Parse(r io.Reader) ([]model.TrafficReport, error) {
+	reports := make([]model.TrafficReport, 0)
+	var columns map[string]int // Table column positions, once a header is seen
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(unitSpacing.ReplaceAllString(scanner.Text(), "$1$2"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if pairs := pairPattern.FindAllStringSubmatch(line, -1); len(pairs) > 0 {
+			report, ok, err := fromPairs(pairs)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if ok {
+				reports = append(reports, report)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if header := parseHeader(fields); header != nil {
+			columns = header
+			continue
+		}
+		if columns == nil {
+			continue
+		}
+
+		report, err := fromRow(fields, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		reports = append(reports, report)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func fromPairs(pairs [][]string) (model.TrafficReport, bool, error) {
+	var report model.TrafficReport
+	hasRx, hasTx := false, false
+	for _, pair := range pairs {
+		key, value := strings.ToLower(pair[1]), pair[2]
+		switch {
+		case identityNames[key]:
+			report.Identity = value
+		case clusterNames[key]:
+			report.Cluster = value
+		case rxNames[key]:
+			n, err := parseCounter(value)
+			if err != nil {
+				return report, false, err
+			}
+			report.RxBytes, hasRx = n, true
+		case txNames[key]:
+			n, err := parseCounter(value)
+			if err != nil {
+				return report, false, err
+			}
+			report.TxBytes, hasTx = n, true
+		}
+	}
+	return report, report.Identity != "" && hasRx && hasTx, nil
+}
+
+// parseHeader returns the column positions if fields form a table header
+func parseHeader(fields []string) map[string]int {
+	columns := make(map[string]int)
+	for i, field := range fields {
+		name := strings.ToLower(strings.Trim(field, "|:"))
+		switch {
+		case identityNames[name]:
+			columns["identity"] = i
+		case clusterNames[name]:
+			columns["cluster"] = i
+		case rxNames[name]:
+			columns["rx"] = i
+		case txNames[name]:
+			columns["tx"] = i
+		}
+	}
+
+	for _, required := range []string{"identity", "rx", "tx"} {
+		if _, ok := columns[required]; !ok {
+			return nil
+		}
+	}
+	return columns
+}
+
+func fromRow(fields []string, columns map[string]int) (model.TrafficReport, error) {
+	var report model.TrafficReport
+	for name, i := range columns {
+		if i >= len(fields) {
+			return report, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	report.Identity = fields[columns["identity"]]
+	if i, ok := columns["cluster"]; ok {
+		report.Cluster = fields[i]
+	}
+
+	var err error
+	if report.RxBytes, err = parseCounter(fields[columns["rx"]]); err != nil {
+		return report, err
+	}
+	if report.TxBytes, err = parseCounter(fields[columns["tx"]]); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// parseCounter parses a byte count with an optional unit
+func parseCounter(value string) (int64, error) {
+	match := counterValue.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid byte counter %q", value)
+	}
+
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte counter %q", value)
+	}
+	switch strings.ToUpper(match[2]) {
+	case "K":
+		number *= 1 << 10
+	case "M":
+		number *= 1 << 20
+	case "G":
+		number *= 1 << 30
+	case "T":
+		number *= 1 << 40
+	}
+	if number > math.MaxInt64 {
+		return 0, fmt.Errorf("byte counter %q is too large", value)
+	}
+	return int64(number), nil
+}
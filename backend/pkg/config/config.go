@@ -3,16 +3,27 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Auth    AuthConfig    `mapstructure:"auth"`
-	Agent   AgentConfig   `mapstructure:"agent"`
-	Storage StorageConfig `mapstructure:"storage"`
-	Rustun  RustunConfig  `mapstructure:"rustun"` // Legacy, for backward compatibility
+	Server   ServerConfig   `mapstructure:"server"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+	Agent    AgentConfig    `mapstructure:"agent"`
+	Storage  StorageConfig  `mapstructure:"storage"`
+	Expiry   ExpiryConfig   `mapstructure:"expiry"`
+	Trash    TrashConfig    `mapstructure:"trash"`
+	Client   ClientConfig   `mapstructure:"client_config"`
+	Keys     KeysConfig     `mapstructure:"keys"`
+	Enroll   EnrollConfig   `mapstructure:"enrollment"`
+	Deploy   DeployConfig   `mapstructure:"deploy"`
+	Presence PresenceConfig `mapstructure:"presence"`
+	Events   EventsConfig   `mapstructure:"events"`
+	Traffic  TrafficConfig  `mapstructure:"traffic"`
+	Rustun   RustunConfig   `mapstructure:"rustun"` // Legacy, for backward compatibility
 }
 
 type ServerConfig struct {
@@ -22,8 +33,10 @@ type ServerConfig struct {
 }
 
 type AuthConfig struct {
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Username       string        `mapstructure:"username"`
+	Password       string        `mapstructure:"password"`
+	AccessTokenTTL time.Duration `mapstructure:"access_token_ttl"` // Lifetime of the signed access tokens of login sessions
+	SessionTTL     time.Duration `mapstructure:"session_ttl"`      // How long a login session lasts unless refreshed
 }
 
 type AgentConfig struct {
@@ -43,8 +56,19 @@ type StorageConfig struct {
 }
 
 type FileConfig struct {
-	RoutesFile         string `mapstructure:"routes_file"`
-	RoutesFileFallback string `mapstructure:"routes_file_fallback"`
+	RoutesFile         string    `mapstructure:"routes_file"`
+	RoutesFileFallback string    `mapstructure:"routes_file_fallback"`
+	DataDir            string    `mapstructure:"data_dir"`      // Auxiliary data (audit log, ...), defaults to the routes file directory
+	ServerConfig       string    `mapstructure:"server_config"` // rustun server.toml, defaults to the routes file directory
+	Git                GitConfig `mapstructure:"git"`
+}
+
+type GitConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`      // Commit every change to a git repository in the routes file directory
+	Remote      string        `mapstructure:"remote"`       // Remote URL pushed to after every commit (optional)
+	Branch      string        `mapstructure:"branch"`       // Branch to commit and push to
+	EmailDomain string        `mapstructure:"email_domain"` // Domain of author emails built from usernames
+	Timeout     time.Duration `mapstructure:"timeout"`      // Limit for each git command, including pushes
 }
 
 type DatabaseConfig struct {
@@ -57,6 +81,103 @@ type DatabaseConfig struct {
 	Path     string `mapstructure:"path"` // For SQLite
 }
 
+type ExpiryConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"` // How often expired clients are checked
+	Action   string        `mapstructure:"action"`   // What happens to expired clients: disable or delete
+	Upcoming time.Duration `mapstructure:"upcoming"` // Default look-ahead window for upcoming expirations
+}
+
+type TrashConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`   // Move deleted clients to the trash instead of deleting them permanently
+	Retention time.Duration `mapstructure:"retention"` // How long deleted clients are kept, 0 keeps them until purged by hand
+	Interval  time.Duration `mapstructure:"interval"`  // How often old entries are purged
+}
+
+// ClientConfig holds the rustun server settings rendered into client
+// configurations, with optional per-cluster overrides
+type ClientConfig struct {
+	ClientConnectConfig `mapstructure:",squash"`
+	Clusters            map[string]ClientConnectConfig `mapstructure:"clusters"`
+	QR                  QRConfig                       `mapstructure:"qr"`
+}
+
+type QRConfig struct {
+	Size  int    `mapstructure:"size"`  // Default image width and height in pixels
+	Level string `mapstructure:"level"` // Default error correction level: L, M, Q or H
+}
+
+type ClientConnectConfig struct {
+	Server            string `mapstructure:"server"`             // Server address clients connect to (host:port)
+	Crypto            string `mapstructure:"crypto"`             // Client -c value, e.g. chacha20:key
+	EnableP2P         *bool  `mapstructure:"enable_p2p"`         // Pass --enable-p2p
+	KeepaliveInterval int    `mapstructure:"keepalive_interval"` // Seconds, 0 uses the client default
+	Binary            string `mapstructure:"binary"`             // Path of the client binary on the target host
+}
+
+type KeysConfig struct {
+	MasterKey     string `mapstructure:"master_key"`      // Base64 32-byte key encrypting cluster keys (or RUSTUN_MASTER_KEY)
+	MasterKeyFile string `mapstructure:"master_key_file"` // Used if master_key is empty; created if missing, defaults to master.key in the data directory
+	ServerConfig  bool   `mapstructure:"server_config"`   // Write activated keys to the rustun server config; if off, activations must confirm the server was changed by hand
+}
+
+type EnrollConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`     // Allow clients to register themselves with enrollment tokens
+	DefaultTTL time.Duration `mapstructure:"default_ttl"` // Lifetime of tokens created without a ttl
+	MaxTTL     time.Duration `mapstructure:"max_ttl"`     // Longest lifetime a token may be given, 0 for no limit
+	URL        string        `mapstructure:"url"`         // Public base URL of the dashboard, used to build enrollment URLs
+}
+
+type PresenceConfig struct {
+	OnlineThreshold  time.Duration `mapstructure:"online_threshold"`  // Clients are online if their last heartbeat is at most this old
+	OfflineThreshold time.Duration `mapstructure:"offline_threshold"` // Clients are stale until their last heartbeat is this old, then offline
+}
+
+type EventsConfig struct {
+	Enabled      bool                 `mapstructure:"enabled"`       // Derive events from the rustun server log
+	LogFile      string               `mapstructure:"log_file"`      // rustun server log to follow
+	FromStart    bool                 `mapstructure:"from_start"`    // Also ingest the lines already in the log at startup
+	PollInterval time.Duration        `mapstructure:"poll_interval"` // How often the log is checked for new lines
+	Retention    time.Duration        `mapstructure:"retention"`     // How long events are kept, 0 for forever
+	Patterns     []EventPatternConfig `mapstructure:"patterns"`      // Extra patterns, tried before the built-in ones
+}
+
+type EventPatternConfig struct {
+	Type    string `mapstructure:"type"`    // connect, disconnect, reload or error
+	Pattern string `mapstructure:"pattern"` // Regular expression; named groups identity, cluster and endpoint are extracted
+}
+
+type TrafficConfig struct {
+	Enabled   bool             `mapstructure:"enabled"` // Accept traffic counters and serve time series
+	Retention TrafficRetention `mapstructure:"retention"`
+}
+
+// TrafficRetention is how long each resolution of the traffic series is
+// kept, 0 for forever
+type TrafficRetention struct {
+	Raw        time.Duration `mapstructure:"raw"`
+	FiveMinute time.Duration `mapstructure:"five_minute"`
+	Hourly     time.Duration `mapstructure:"hourly"`
+}
+
+type DeployConfig struct {
+	Timeout       time.Duration        `mapstructure:"timeout"`        // Longest a single delivery may take
+	Retries       int                  `mapstructure:"retries"`        // Retries of a failed delivery before giving up until the next change
+	RetryInterval time.Duration        `mapstructure:"retry_interval"` // Delay before the first retry, doubled for every further retry
+	Targets       []DeployTargetConfig `mapstructure:"targets"`        // Where routes are published after every change
+}
+
+type DeployTargetConfig struct {
+	Name     string            `mapstructure:"name"`
+	Type     string            `mapstructure:"type"`     // dir, http or exec
+	Clusters []string          `mapstructure:"clusters"` // Only publish these clusters; all if empty
+	Path     string            `mapstructure:"path"`     // dir: directory the routes file is written to
+	Filename string            `mapstructure:"filename"` // dir: name of the routes file, defaults to routes.json
+	URL      string            `mapstructure:"url"`      // http: endpoint the routes are PUT to
+	Headers  map[string]string `mapstructure:"headers"`  // http: extra request headers
+	Command  []string          `mapstructure:"command"`  // exec: program and arguments; routes are passed on stdin
+}
+
 type RustunConfig struct {
 	RoutesFile         string `mapstructure:"routes_file"`
 	RoutesFileFallback string `mapstructure:"routes_file_fallback"`
@@ -74,15 +195,59 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("server.mode", "debug")
 	v.SetDefault("auth.username", "admin")
 	v.SetDefault("auth.password", "admin123")
+	v.SetDefault("auth.access_token_ttl", "15m")
+	v.SetDefault("auth.session_ttl", "168h")
 
 	v.SetDefault("storage.type", "file")
 	v.SetDefault("storage.file.routes_file", "/etc/rustun/routes.json")
 	v.SetDefault("storage.file.routes_file_fallback", "./routes.json")
 
+	v.SetDefault("storage.file.git.enabled", false)
+	v.SetDefault("storage.file.git.branch", "main")
+	v.SetDefault("storage.file.git.email_domain", "rustun-dashboard.local")
+	v.SetDefault("storage.file.git.timeout", "1m")
+
 	v.SetDefault("storage.database.type", "mysql")
 	v.SetDefault("storage.database.host", "localhost")
 	v.SetDefault("storage.database.port", 3306)
 
+	v.SetDefault("expiry.enabled", true)
+	v.SetDefault("expiry.interval", "1m")
+	v.SetDefault("expiry.action", "disable")
+	v.SetDefault("expiry.upcoming", "168h")
+
+	v.SetDefault("trash.enabled", true)
+	v.SetDefault("trash.retention", "720h")
+	v.SetDefault("trash.interval", "1h")
+
+	v.SetDefault("keys.server_config", true)
+
+	v.SetDefault("enrollment.enabled", true)
+	v.SetDefault("enrollment.default_ttl", "24h")
+	v.SetDefault("enrollment.max_ttl", "720h")
+
+	v.SetDefault("presence.online_threshold", "90s")
+	v.SetDefault("presence.offline_threshold", "10m")
+
+	v.SetDefault("events.enabled", false)
+	v.SetDefault("events.log_file", "/var/log/rustun/server.log")
+	v.SetDefault("events.poll_interval", "1s")
+	v.SetDefault("events.retention", "168h")
+
+	v.SetDefault("traffic.enabled", true)
+	v.SetDefault("traffic.retention.raw", "48h")
+	v.SetDefault("traffic.retention.five_minute", "720h")
+	v.SetDefault("traffic.retention.hourly", "8760h")
+
+	v.SetDefault("deploy.timeout", "30s")
+	v.SetDefault("deploy.retries", 5)
+	v.SetDefault("deploy.retry_interval", "10s")
+
+	v.SetDefault("client_config.enable_p2p", false)
+	v.SetDefault("client_config.binary", "/usr/local/bin/rustun-client")
+	v.SetDefault("client_config.qr.size", 256)
+	v.SetDefault("client_config.qr.level", "M")
+
 	v.SetDefault("agent.enabled", true)
 	v.SetDefault("agent.provider", "openai")
 	v.SetDefault("agent.model", "gpt-4o-mini")
@@ -113,9 +278,76 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	// Keep auxiliary data next to the routes file unless configured otherwise
+	if config.Storage.File.DataDir == "" {
+		config.Storage.File.DataDir = filepath.Dir(config.Storage.File.RoutesFile)
+	}
+	if config.Keys.MasterKey == "" {
+		config.Keys.MasterKey = os.Getenv("RUSTUN_MASTER_KEY")
+	}
+	if config.Keys.MasterKeyFile == "" {
+		config.Keys.MasterKeyFile = filepath.Join(config.Storage.File.DataDir, "master.key")
+	}
+
+	if config.Storage.File.ServerConfig == "" {
+		config.Storage.File.ServerConfig = filepath.Join(filepath.Dir(config.Storage.File.RoutesFile), "server.toml")
+	}
+
+	switch config.Expiry.Action {
+	case "disable", "delete":
+	default:
+		return nil, fmt.Errorf("invalid expiry.action %q: must be disable or delete", config.Expiry.Action)
+	}
+
+	if config.Auth.AccessTokenTTL <= 0 || config.Auth.SessionTTL < config.Auth.AccessTokenTTL {
+		return nil, fmt.Errorf("invalid auth lifetimes: access_token_ttl must be positive and at most session_ttl")
+	}
+
+	if config.Presence.OnlineThreshold <= 0 || config.Presence.OfflineThreshold < config.Presence.OnlineThreshold {
+		return nil, fmt.Errorf("invalid presence thresholds: online_threshold must be positive and at most offline_threshold")
+	}
+
+	names := make(map[string]bool, len(config.Deploy.Targets))
+	for i, target := range config.Deploy.Targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("deploy.targets[%d]: name is required", i)
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("deploy.targets[%d]: duplicate name %q", i, target.Name)
+		}
+		names[target.Name] = true
+	}
+
 	return &config, nil
 }
 
+// ForCluster returns the client settings of a cluster: the defaults with
+// the cluster's overrides applied
+func (c *ClientConfig) ForCluster(cluster string) ClientConnectConfig {
+	settings := c.ClientConnectConfig
+	override, ok := c.Clusters[cluster]
+	if !ok {
+		return settings
+	}
+
+	if override.Server != "" {
+		settings.Server = override.Server
+	}
+	if override.Crypto != "" {
+		settings.Crypto = override.Crypto
+	}
+	if override.EnableP2P != nil {
+		settings.EnableP2P = override.EnableP2P
+	}
+	if override.KeepaliveInterval != 0 {
+		settings.KeepaliveInterval = override.KeepaliveInterval
+	}
+	if override.Binary != "" {
+		settings.Binary = override.Binary
+	}
+	return settings
+}
+
 // Address returns the full server address
 func (c *ServerConfig) Address() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"github.com/smartethnet/rustun-dashboard/internal/lint"
+)
+
+// Lint tool to check routes files before they reach rustun, e.g. from a
+// pre-commit hook. Same as `dashboard lint`.
+func main() {
+	os.Exit(lint.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
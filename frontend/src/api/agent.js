@@ -1,21 +1,7 @@
-import axios from 'axios'
+import { createAuthAxios, getAccessToken, refreshSession, endSession } from './session'
 
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080'
 
-// Create axios instance with basic auth
-const createAuthAxios = () => {
-  const username = localStorage.getItem('username')
-  const password = localStorage.getItem('password')
-  
-  return axios.create({
-    baseURL: API_BASE_URL,
-    auth: {
-      username,
-      password
-    }
-  })
-}
-
 // Agent API
 export const agentApi = {
   // Send chat message
@@ -30,15 +16,11 @@ export const agentApi = {
 
   // Send chat message with streaming
   chatStream: async (message, history = [], onChunk) => {
-    const username = localStorage.getItem('username')
-    const password = localStorage.getItem('password')
-    const auth = 'Basic ' + btoa(username + ':' + password)
-
-    const response = await fetch(`${API_BASE_URL}/api/agent/chat/stream`, {
+    const send = () => fetch(`${API_BASE_URL}/api/agent/chat/stream`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
-        'Authorization': auth,
+        'Authorization': `Bearer ${getAccessToken()}`,
       },
       body: JSON.stringify({
         message,
@@ -46,6 +28,17 @@ export const agentApi = {
       }),
     })
 
+    let response = await send()
+    if (response.status === 401) {
+      try {
+        await refreshSession()
+      } catch {
+        endSession()
+        throw new Error(`HTTP error! status: ${response.status}`)
+      }
+      response = await send()
+    }
+
     if (!response.ok) {
       throw new Error(`HTTP error! status: ${response.status}`)
     }
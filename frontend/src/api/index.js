@@ -1,19 +1,44 @@
 import axios from 'axios'
+import { createAuthAxios as createSessionAxios, getAccessToken, clearSession } from './session'
+
+export { saveSession } from './session'
 
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080'
 
-// Create axios instance with basic auth
-const createAuthAxios = () => {
-  const username = localStorage.getItem('username')
-  const password = localStorage.getItem('password')
+// Create axios instance authenticated with the login session
+const createAuthAxios = () => createSessionAxios({
+  // Lets the backend attribute changes to the web UI in client history
+  'X-Rustun-Source': 'ui'
+})
+
+// Auth API
+export const authAPI = {
+  // Start a session; the account tells whether the password must be changed
+  // before the session is saved with saveSession
+  login: async (username, password) => {
+    return await axios.post(`${API_BASE_URL}/api/auth/login`, { username, password })
+  },
 
-  return axios.create({
-    baseURL: API_BASE_URL,
-    auth: {
-      username,
-      password
+  logout: async () => {
+    try {
+      await axios.post(`${API_BASE_URL}/api/auth/logout`, null, {
+        headers: { Authorization: `Bearer ${getAccessToken()}` }
+      })
+    } finally {
+      clearSession()
     }
-  })
+  },
+
+  // Defaults to the saved session; a session that must change its password
+  // passes its access token instead
+  changePassword: async (currentPassword, newPassword, accessToken = getAccessToken()) => {
+    return await axios.put(`${API_BASE_URL}/api/auth/password`, {
+      current_password: currentPassword,
+      new_password: newPassword
+    }, {
+      headers: { Authorization: `Bearer ${accessToken}` }
+    })
+  }
 }
 
 // Cluster API
@@ -0,0 +1,89 @@
+import axios from 'axios'
+
+const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080'
+
+// Only the tokens of the login session are kept; the password never is
+const ACCESS_TOKEN = 'accessToken'
+const REFRESH_TOKEN = 'refreshToken'
+const USERNAME = 'username'
+
+export const getAccessToken = () => localStorage.getItem(ACCESS_TOKEN)
+
+export const isLoggedIn = () => !!localStorage.getItem(REFRESH_TOKEN)
+
+// Store the tokens of a login or refresh response
+export const saveSession = (data) => {
+  localStorage.setItem(ACCESS_TOKEN, data.access_token)
+  localStorage.setItem(REFRESH_TOKEN, data.refresh_token)
+  localStorage.setItem(USERNAME, data.user.username)
+}
+
+export const clearSession = () => {
+  localStorage.removeItem(ACCESS_TOKEN)
+  localStorage.removeItem(REFRESH_TOKEN)
+  localStorage.removeItem(USERNAME)
+}
+
+let refreshing = null
+
+// Trade the refresh token for new tokens. Concurrent callers share one
+// request, since each refresh token can only be used once.
+export const refreshSession = () => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN)
+    refreshing = axios.post(`${API_BASE_URL}/api/auth/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        saveSession(response.data.data)
+      })
+      .catch((error) => {
+        // Another tab may have refreshed with the same token meanwhile
+        if (localStorage.getItem(REFRESH_TOKEN) === refreshToken) {
+          throw error
+        }
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// Leave the dashboard after the session ended
+export const endSession = () => {
+  clearSession()
+  if (window.location.pathname !== '/login') {
+    window.location.assign('/login')
+  }
+}
+
+// Create an axios instance sending the access token, refreshing it once
+// when the backend rejects it
+export const createAuthAxios = (headers = {}) => {
+  const api = axios.create({
+    baseURL: API_BASE_URL,
+    headers,
+  })
+
+  api.interceptors.request.use((config) => {
+    config.headers.Authorization = `Bearer ${getAccessToken()}`
+    return config
+  })
+
+  api.interceptors.response.use(undefined, async (error) => {
+    const config = error.config
+    if (error.response?.status !== 401 || !config || config._retried) {
+      throw error
+    }
+
+    try {
+      await refreshSession()
+    } catch {
+      endSession()
+      throw error
+    }
+    config._retried = true
+    return api(config)
+  })
+
+  return api
+}
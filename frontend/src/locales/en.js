@@ -48,11 +48,19 @@ export default {
     loggingIn: 'Logging in...',
     loginSuccess: 'Login successful',
     loginFailed: 'Invalid username or password',
-    defaultHint: 'Default: admin / admin123',
+    defaultHint: 'Default: admin / admin123 (change it on first login)',
     usernamePlaceholder: 'Username',
     passwordPlaceholder: 'Password',
     usernameRequired: 'Please input username',
     passwordRequired: 'Please input password',
+    mustChangePassword: 'Please choose a new password before continuing.',
+    newPasswordPlaceholder: 'New password',
+    confirmPasswordPlaceholder: 'Confirm new password',
+    changePasswordButton: 'Change Password',
+    passwordTooShort: 'Password must be at least 8 characters',
+    passwordMismatch: 'Passwords do not match',
+    passwordChanged: 'Password changed',
+    changePasswordFailed: 'Failed to change password',
   },
   
   topology: {
@@ -48,11 +48,19 @@ export default {
     loggingIn: '登录中...',
     loginSuccess: '登录成功',
     loginFailed: '用户名或密码错误',
-    defaultHint: '默认：admin / admin123',
+    defaultHint: '默认：admin / admin123（首次登录需修改）',
     usernamePlaceholder: '用户名',
     passwordPlaceholder: '密码',
     usernameRequired: '请输入用户名',
     passwordRequired: '请输入密码',
+    mustChangePassword: '继续之前请设置新密码。',
+    newPasswordPlaceholder: '新密码',
+    confirmPasswordPlaceholder: '确认新密码',
+    changePasswordButton: '修改密码',
+    passwordTooShort: '密码至少 8 个字符',
+    passwordMismatch: '两次输入的密码不一致',
+    passwordChanged: '密码已修改',
+    changePasswordFailed: '修改密码失败',
   },
   
   topology: {
@@ -1,4 +1,5 @@
 import { createRouter, createWebHistory } from 'vue-router'
+import { isLoggedIn } from '../api/session'
 
 const routes = [
   {
@@ -37,10 +38,8 @@ const router = createRouter({
 
 // Navigation guard
 router.beforeEach((to, from, next) => {
-  const username = localStorage.getItem('username')
-  const password = localStorage.getItem('password')
-  const isAuthenticated = !!(username && password)
-  
+  const isAuthenticated = isLoggedIn()
+
   if (to.meta.requiresAuth && !isAuthenticated) {
     next('/login')
   } else if (to.path === '/login' && isAuthenticated) {